@@ -0,0 +1,200 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package balancer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+const (
+	DefaultMaxFailures     = 3
+	DefaultEjectionTime    = 30 * time.Second
+	DefaultRefreshInterval = 30 * time.Second
+)
+
+type endpointState struct {
+	lastFailure         time.Time
+	consecutiveFailures int
+	ejectedUntil        time.Time
+}
+
+// Balancer picks endpoints for a single consumed resource and passively ejects endpoints that keep failing.
+// Endpoints are resolved from the config provider and refreshed periodically.
+type Balancer struct {
+	provider     providers.ConfigProvider
+	resourceName string
+	portType     string
+
+	picker          Picker
+	maxFailures     int
+	ejectionTime    time.Duration
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu          sync.Mutex
+	endpoints   []providers.Endpoint
+	refreshedAt time.Time
+	states      map[string]*endpointState
+}
+
+type Option func(*Balancer)
+
+// WithPicker sets the strategy used to choose between healthy endpoints. Defaults to round-robin.
+func WithPicker(picker Picker) Option {
+	return func(b *Balancer) {
+		b.picker = picker
+	}
+}
+
+// WithMaxFailures sets the number of consecutive failures before an endpoint is ejected
+func WithMaxFailures(maxFailures int) Option {
+	return func(b *Balancer) {
+		b.maxFailures = maxFailures
+	}
+}
+
+// WithEjectionTime sets for how long an ejected endpoint is skipped
+func WithEjectionTime(ejectionTime time.Duration) Option {
+	return func(b *Balancer) {
+		b.ejectionTime = ejectionTime
+	}
+}
+
+// WithRefreshInterval sets how often the endpoints are resolved from the provider
+func WithRefreshInterval(refreshInterval time.Duration) Option {
+	return func(b *Balancer) {
+		b.refreshInterval = refreshInterval
+	}
+}
+
+// New creates a balancer for the given resource name and port type
+func New(provider providers.ConfigProvider, resourceName, portType string, opts ...Option) *Balancer {
+	b := &Balancer{
+		provider:        provider,
+		resourceName:    resourceName,
+		portType:        portType,
+		picker:          NewRoundRobinPicker(),
+		maxFailures:     DefaultMaxFailures,
+		ejectionTime:    DefaultEjectionTime,
+		refreshInterval: DefaultRefreshInterval,
+		now:             time.Now,
+		states:          make(map[string]*endpointState),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Next returns the endpoint to use for the next call.
+// Ejected endpoints are skipped unless every endpoint is ejected, in which case all of them are candidates.
+// If refreshing the endpoints fails, the last resolved endpoints are used until the next refresh is due.
+func (b *Balancer) Next() (providers.Endpoint, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.endpoints == nil || now.Sub(b.refreshedAt) >= b.refreshInterval {
+		if err := b.refresh(now); err != nil {
+			if b.endpoints == nil {
+				return providers.Endpoint{}, err
+			}
+			cfg.Logger().Warn("failed to refresh endpoints, using the previous ones", "resourceName", b.resourceName, "portType", b.portType, "error", err)
+			b.refreshedAt = now
+		}
+	}
+
+	if len(b.endpoints) == 0 {
+		return providers.Endpoint{}, fmt.Errorf("no endpoints available for %s/%s", b.resourceName, b.portType)
+	}
+
+	candidates := make([]EndpointState, 0, len(b.endpoints))
+	for _, endpoint := range b.endpoints {
+		state := b.stateFor(endpoint)
+		if state.ejectedUntil.After(now) {
+			continue
+		}
+		candidates = append(candidates, b.view(endpoint, state))
+	}
+
+	if len(candidates) == 0 {
+		for _, endpoint := range b.endpoints {
+			candidates = append(candidates, b.view(endpoint, b.stateFor(endpoint)))
+		}
+	}
+
+	return candidates[b.picker.Pick(candidates)].Endpoint, nil
+}
+
+// Refresh resolves the endpoints from the provider immediately
+func (b *Balancer) Refresh() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.refresh(b.now())
+}
+
+// ReportFailure records a failed call to the endpoint.
+// The endpoint is ejected once it has failed the configured number of times in a row.
+func (b *Balancer) ReportFailure(endpoint providers.Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	state := b.stateFor(endpoint)
+	state.lastFailure = now
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= b.maxFailures {
+		state.ejectedUntil = now.Add(b.ejectionTime)
+		state.consecutiveFailures = 0
+	}
+}
+
+// ReportSuccess records a successful call to the endpoint, resetting its failure count
+func (b *Balancer) ReportSuccess(endpoint providers.Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.stateFor(endpoint)
+	state.consecutiveFailures = 0
+	state.ejectedUntil = time.Time{}
+}
+
+func (b *Balancer) refresh(now time.Time) error {
+	endpoints, err := b.provider.GetServiceEndpoints(b.resourceName, b.portType)
+	if err != nil {
+		return fmt.Errorf("failed to resolve endpoints for %s/%s: %w", b.resourceName, b.portType, err)
+	}
+
+	states := make(map[string]*endpointState, len(endpoints))
+	for _, endpoint := range endpoints {
+		states[endpoint.Address] = b.stateFor(endpoint)
+	}
+
+	b.endpoints = endpoints
+	b.states = states
+	b.refreshedAt = now
+	return nil
+}
+
+func (b *Balancer) stateFor(endpoint providers.Endpoint) *endpointState {
+	state, exists := b.states[endpoint.Address]
+	if !exists {
+		state = &endpointState{}
+		b.states[endpoint.Address] = state
+	}
+	return state
+}
+
+func (b *Balancer) view(endpoint providers.Endpoint, state *endpointState) EndpointState {
+	return EndpointState{
+		Endpoint:            endpoint,
+		LastFailure:         state.lastFailure,
+		ConsecutiveFailures: state.consecutiveFailures,
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func endpointsMock(endpoints []providers.Endpoint, calls *int) *config.ConfigProviderMock {
+	return &config.ConfigProviderMock{
+		GetServiceEndpointsFunc: func(serviceName string, portType string) ([]providers.Endpoint, error) {
+			*calls++
+			if serviceName != "users" || portType != "rest" {
+				return nil, fmt.Errorf("unknown service %s/%s", serviceName, portType)
+			}
+			return endpoints, nil
+		},
+	}
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	calls := 0
+	provider := endpointsMock([]providers.Endpoint{
+		{Address: "10.0.0.1:80", InstanceId: "a"},
+		{Address: "10.0.0.2:80", InstanceId: "b"},
+	}, &calls)
+
+	b := New(provider, "users", "rest")
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		endpoint, err := b.Next()
+		assert.NoError(t, err)
+		picked = append(picked, endpoint.InstanceId)
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, picked)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBalancerEjectsFailingEndpoint(t *testing.T) {
+	calls := 0
+	bad := providers.Endpoint{Address: "10.0.0.1:80"}
+	good := providers.Endpoint{Address: "10.0.0.2:80"}
+	provider := endpointsMock([]providers.Endpoint{bad, good}, &calls)
+
+	now := time.Unix(1000, 0)
+	b := New(provider, "users", "rest", WithMaxFailures(2), WithEjectionTime(time.Minute), WithRefreshInterval(time.Hour))
+	b.now = func() time.Time { return now }
+
+	b.ReportFailure(bad)
+	b.ReportFailure(bad)
+
+	for i := 0; i < 3; i++ {
+		endpoint, err := b.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, good, endpoint)
+	}
+
+	now = now.Add(2 * time.Minute)
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		endpoint, err := b.Next()
+		assert.NoError(t, err)
+		seen[endpoint.Address] = true
+	}
+	assert.True(t, seen[bad.Address], "endpoint should be back after the ejection time")
+}
+
+func TestBalancerAllEjected(t *testing.T) {
+	calls := 0
+	only := providers.Endpoint{Address: "10.0.0.1:80"}
+	b := New(endpointsMock([]providers.Endpoint{only}, &calls), "users", "rest", WithMaxFailures(1))
+
+	b.ReportFailure(only)
+	endpoint, err := b.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, only, endpoint)
+}
+
+func TestBalancerRefresh(t *testing.T) {
+	calls := 0
+	provider := endpointsMock([]providers.Endpoint{{Address: "10.0.0.1:80"}}, &calls)
+
+	now := time.Unix(1000, 0)
+	b := New(provider, "users", "rest", WithRefreshInterval(time.Second))
+	b.now = func() time.Time { return now }
+
+	_, err := b.Next()
+	assert.NoError(t, err)
+	_, err = b.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Second)
+	_, err = b.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	assert.NoError(t, b.Refresh())
+	assert.Equal(t, 3, calls)
+
+	_, err = New(provider, "unknown", "rest").Next()
+	assert.Error(t, err)
+}
+
+func TestLeastRecentlyFailedPicker(t *testing.T) {
+	picker := NewLeastRecentlyFailedPicker()
+	now := time.Now()
+	candidates := []EndpointState{
+		{Endpoint: providers.Endpoint{Address: "a"}, LastFailure: now},
+		{Endpoint: providers.Endpoint{Address: "b"}, LastFailure: now.Add(-time.Minute)},
+		{Endpoint: providers.Endpoint{Address: "c"}},
+		{Endpoint: providers.Endpoint{Address: "d"}},
+	}
+
+	assert.Equal(t, 2, picker.Pick(candidates))
+	assert.Equal(t, 3, picker.Pick(candidates))
+	assert.Equal(t, 2, picker.Pick(candidates))
+
+	assert.Equal(t, 1, picker.Pick(candidates[:2]))
+}
+
+func TestRandomPicker(t *testing.T) {
+	picker := NewRandomPicker()
+	candidates := []EndpointState{{}, {}, {}}
+	for i := 0; i < 20; i++ {
+		index := picker.Pick(candidates)
+		assert.GreaterOrEqual(t, index, 0)
+		assert.Less(t, index, len(candidates))
+	}
+}
+
+func TestBalancerRefreshFailure(t *testing.T) {
+	calls := 0
+	var failure error
+	provider := &config.ConfigProviderMock{
+		GetServiceEndpointsFunc: func(serviceName string, portType string) ([]providers.Endpoint, error) {
+			calls++
+			if failure != nil {
+				return nil, failure
+			}
+			return []providers.Endpoint{{Address: "10.0.0.1:80"}}, nil
+		},
+	}
+
+	now := time.Unix(1000, 0)
+	b := New(provider, "users", "rest", WithRefreshInterval(time.Second))
+	b.now = func() time.Time { return now }
+
+	_, err := b.Next()
+	assert.NoError(t, err)
+
+	failure = errors.New("cluster service unavailable")
+	now = now.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		endpoint, err := b.Next()
+		assert.NoError(t, err, "the last resolved endpoints are used while refreshing fails")
+		assert.Equal(t, "10.0.0.1:80", endpoint.Address)
+	}
+	assert.Equal(t, 2, calls, "a failed refresh is retried after the refresh interval")
+
+	now = now.Add(time.Second)
+	_, err = b.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Error(t, b.Refresh(), "an explicit refresh returns the error")
+
+	_, err = New(provider, "users", "rest").Next()
+	assert.Error(t, err, "the error is returned when no endpoints were ever resolved")
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package balancer
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// EndpointState is the view of an endpoint a Picker chooses from
+type EndpointState struct {
+	Endpoint            providers.Endpoint
+	LastFailure         time.Time
+	ConsecutiveFailures int
+}
+
+// Picker chooses one of the given candidates and returns its index.
+// Candidates are never empty.
+type Picker interface {
+	Pick(candidates []EndpointState) int
+}
+
+// PickerFunc adapts a function to the Picker interface
+type PickerFunc func(candidates []EndpointState) int
+
+func (f PickerFunc) Pick(candidates []EndpointState) int {
+	return f(candidates)
+}
+
+type roundRobinPicker struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobinPicker returns a Picker that cycles through the candidates in order
+func NewRoundRobinPicker() Picker {
+	return &roundRobinPicker{}
+}
+
+func (r *roundRobinPicker) Pick(candidates []EndpointState) int {
+	return int((r.next.Add(1) - 1) % uint64(len(candidates)))
+}
+
+// NewRandomPicker returns a Picker that chooses a random candidate
+func NewRandomPicker() Picker {
+	return PickerFunc(func(candidates []EndpointState) int {
+		return rand.Intn(len(candidates))
+	})
+}
+
+// NewLeastRecentlyFailedPicker returns a Picker that prefers candidates that have never failed,
+// and otherwise the candidate whose last failure is the oldest.
+// Ties are broken round-robin.
+func NewLeastRecentlyFailedPicker() Picker {
+	tieBreaker := &roundRobinPicker{}
+	return PickerFunc(func(candidates []EndpointState) int {
+		best := make([]int, 0, len(candidates))
+		for i, candidate := range candidates {
+			if len(best) == 0 {
+				best = append(best, i)
+				continue
+			}
+			bestFailure := candidates[best[0]].LastFailure
+			switch {
+			case candidate.LastFailure.Before(bestFailure):
+				best = append(best[:0], i)
+			case candidate.LastFailure.Equal(bestFailure):
+				best = append(best, i)
+			}
+		}
+		return best[int((tieBreaker.next.Add(1)-1)%uint64(len(best)))]
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package blockdef
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/ref"
+)
+
+// Port types that are served by another block. Every other port type is a resource provided by an operator, such as a database.
+var servicePortTypes = map[string]bool{
+	"rest": true,
+	"grpc": true,
+	"http": true,
+	"web":  true,
+}
+
+// Resource is a consumer or provider declared in kapeta.yml
+type Resource struct {
+	Kind     string
+	Name     string
+	PortType string
+}
+
+// ResourceType returns the kind without the kapeta:// prefix and version, e.g. kapeta/resource-type-mongodb
+func (r Resource) ResourceType() string {
+	if kind, err := ref.Parse(r.Kind); err == nil {
+		return kind.FullName()
+	}
+	resourceType := strings.TrimPrefix(r.Kind, "kapeta://")
+	if i := strings.LastIndex(resourceType, ":"); i > 0 {
+		resourceType = resourceType[:i]
+	}
+	return resourceType
+}
+
+// IsService returns true if the resource is served by another block rather than an operator
+func (r Resource) IsService() bool {
+	return servicePortTypes[strings.ToLower(r.PortType)]
+}
+
+// Block is the part of a block definition that decides which configuration the block needs
+type Block struct {
+	Kind      string
+	Name      string
+	Consumers []Resource
+	Providers []Resource
+}
+
+// Parse reads the consumers and providers from a block definition as loaded from kapeta.yml
+func Parse(blockDefinition map[string]interface{}) (*Block, error) {
+	data, err := json.Marshal(blockDefinition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode block definition: %w", err)
+	}
+
+	definition := &model.BlockDefinition{}
+	if err := json.Unmarshal(data, definition); err != nil {
+		return nil, fmt.Errorf("invalid block definition: %w", err)
+	}
+
+	return &Block{
+		Kind:      definition.Kind,
+		Name:      definition.Metadata.Name,
+		Consumers: toResources(definition.Spec.Consumers),
+		Providers: toResources(definition.Spec.Providers),
+	}, nil
+}
+
+// ProviderPortTypes returns the distinct port types the block serves, in sorted order
+func (b *Block) ProviderPortTypes() []string {
+	seen := map[string]bool{}
+	portTypes := make([]string, 0)
+	for _, provider := range b.Providers {
+		if provider.PortType == "" || seen[provider.PortType] {
+			continue
+		}
+		seen[provider.PortType] = true
+		portTypes = append(portTypes, provider.PortType)
+	}
+	sort.Strings(portTypes)
+	return portTypes
+}
+
+func toResources(elements []model.ConsumerElement) []Resource {
+	resources := make([]Resource, 0, len(elements))
+	for _, element := range elements {
+		resources = append(resources, Resource{
+			Kind:     element.Kind,
+			Name:     element.Metadata.Name,
+			PortType: portType(element.Spec),
+		})
+	}
+	return resources
+}
+
+func portType(spec map[string]interface{}) string {
+	port, _ := spec["port"].(map[string]interface{})
+	portType, _ := port["type"].(string)
+	return portType
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package blockdef
+
+import (
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParse(t *testing.T) {
+	definition := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal(testdata.BlockYml, &definition))
+
+	block, err := Parse(definition)
+	require.NoError(t, err)
+
+	assert.Equal(t, "kapeta://kapeta/block-type-service:0.0.2", block.Kind)
+	assert.Equal(t, "soren_mathiasen/sample-java-chat-messages-service", block.Name)
+	assert.Equal(t, []Resource{{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "messages", PortType: "rest"}}, block.Providers)
+	assert.Equal(t, []Resource{{Kind: "kapeta://kapeta/resource-type-mongodb:0.0.1", Name: "messages", PortType: "mongodb"}}, block.Consumers)
+	assert.Equal(t, []string{"rest"}, block.ProviderPortTypes())
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(map[string]interface{}{"spec": "not a spec"})
+	assert.Error(t, err)
+}
+
+func TestResource(t *testing.T) {
+	mongo := Resource{Kind: "kapeta://kapeta/resource-type-mongodb:0.0.1", Name: "messages", PortType: "mongodb"}
+	assert.Equal(t, "kapeta/resource-type-mongodb", mongo.ResourceType())
+	assert.False(t, mongo.IsService())
+
+	rest := Resource{Kind: "kapeta/resource-type-rest-api", Name: "users", PortType: "REST"}
+	assert.Equal(t, "kapeta/resource-type-rest-api", rest.ResourceType())
+	assert.True(t, rest.IsService())
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package clustertest
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Identity is the identity returned from /config/identity
+type Identity struct {
+	SystemID   string `json:"systemId" yaml:"systemId"`
+	InstanceID string `json:"instanceId" yaml:"instanceId"`
+}
+
+// Endpoint is a single endpoint returned from /config/consumes/{resource}/{portType}/endpoints
+type Endpoint struct {
+	Address    string `json:"address" yaml:"address"`
+	InstanceId string `json:"instanceId,omitempty" yaml:"instanceId,omitempty"`
+}
+
+// Service is a consumed service returned from /config/consumes/{resource}/{portType}
+type Service struct {
+	Name      string     `json:"name" yaml:"name"`
+	PortType  string     `json:"portType" yaml:"portType"`
+	Address   string     `json:"address" yaml:"address"`
+	Endpoints []Endpoint `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+}
+
+// Resource is a consumed resource returned from /config/consumes/resource/{type}/{portType}/{name}.
+// Info is encoded as JSON, so it can be a providers.ResourceInfo or a plain map.
+// An empty Type matches any resource type.
+type Resource struct {
+	Type     string `json:"type" yaml:"type"`
+	PortType string `json:"portType" yaml:"portType"`
+	Name     string `json:"name" yaml:"name"`
+	Info     any    `json:"info" yaml:"info"`
+}
+
+// Fixture is the state served by the fake cluster service.
+// Values typed as any are encoded as JSON in the responses.
+type Fixture struct {
+	Identity       Identity          `json:"identity" yaml:"identity"`
+	InstanceConfig map[string]any    `json:"instanceConfig,omitempty" yaml:"instanceConfig,omitempty"`
+	ProviderPorts  map[string]string `json:"providerPorts,omitempty" yaml:"providerPorts,omitempty"`
+	Services       []Service         `json:"services,omitempty" yaml:"services,omitempty"`
+	Resources      []Resource        `json:"resources,omitempty" yaml:"resources,omitempty"`
+	InstanceHosts  map[string]string `json:"instanceHosts,omitempty" yaml:"instanceHosts,omitempty"`
+	Operators      map[string]any    `json:"operators,omitempty" yaml:"operators,omitempty"`
+	Assets         map[string]any    `json:"assets,omitempty" yaml:"assets,omitempty"`
+	// ServerTLS is keyed by port type and ClientTLS by consumed resource name
+	ServerTLS map[string]any `json:"serverTls,omitempty" yaml:"serverTls,omitempty"`
+	ClientTLS map[string]any `json:"clientTls,omitempty" yaml:"clientTls,omitempty"`
+	// Failures makes requests to the paths fail with the status codes, e.g. "/config/consumes/users/rest": 500
+	Failures map[string]int `json:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+// ParseFixture parses a YAML (or JSON) fixture
+func ParseFixture(data []byte) (*Fixture, error) {
+	fixture := &Fixture{}
+	if err := yaml.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("error parsing cluster service fixture: %w", err)
+	}
+	return fixture, nil
+}
+
+// LoadFixture reads and parses a YAML (or JSON) fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cluster service fixture: %w", err)
+	}
+	return ParseFixture(data)
+}
@@ -0,0 +1,270 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package clustertest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const kapetaHeaderPrefix = "X-Kapeta-"
+
+// Request is a request received by the fake cluster service
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	// Header only contains the X-Kapeta-* headers
+	Header http.Header
+	Body   []byte
+}
+
+// Server is an in-process fake of the local cluster service
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixture  *Fixture
+	requests []Request
+}
+
+// NewServer starts a fake cluster service serving the given fixture
+func NewServer(fixture *Fixture) *Server {
+	if fixture == nil {
+		fixture = &Fixture{}
+	}
+	s := &Server{fixture: fixture}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Start starts a fake cluster service for the duration of the test.
+// The local provider is pointed at the server, see Setenv, and the server is closed on cleanup.
+func Start(t testing.TB, fixture *Fixture) *Server {
+	t.Helper()
+	s := NewServer(fixture)
+	t.Cleanup(s.Close)
+	s.Setenv(t)
+	return s
+}
+
+// Setenv points KAPETA_LOCAL_CLUSTER_HOST and KAPETA_LOCAL_CLUSTER_PORT at the server for the duration of the test.
+// Settings that would take precedence or point elsewhere are cleared, and KAPETA_HOME is an empty temporary
+// directory, so the cluster-service.yml, token and profile of the developer are never used.
+func (s *Server) Setenv(t testing.TB) {
+	t.Helper()
+	host, port := s.HostPort()
+	t.Setenv("KAPETA_HOME", t.TempDir())
+	t.Setenv("KAPETA_PROFILE", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_CA_FILE", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
+}
+
+// HostPort returns the host and port the server is listening on
+func (s *Server) HostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host, port
+}
+
+// Update changes the served fixture
+func (s *Server) Update(update func(fixture *Fixture)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s.fixture)
+}
+
+// Requests returns all requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the requests received for the given method and path
+func (s *Server) RequestsTo(method, path string) []Request {
+	var out []Request
+	for _, req := range s.Requests() {
+		if req.Method == method && req.Path == path {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// ResetRequests forgets all requests received so far
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	header := http.Header{}
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, kapetaHeaderPrefix) {
+			header[name] = values
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: header,
+		Body:   body,
+	})
+
+	if status, failing := s.fixture.Failures[r.URL.Path]; failing {
+		w.WriteHeader(status)
+		return
+	}
+
+	value, found := s.route(r)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if text, ok := value.(string); ok {
+		_, _ = w.Write([]byte(text))
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func (s *Server) route(r *http.Request) (any, bool) {
+	// Split the escaped path, since segments such as resource types may contain encoded slashes
+	segments := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.QueryUnescape(segment); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	switch {
+	case r.URL.Path == "/instances":
+		// Registration, readiness and de-registration of the instance
+		return map[string]any{}, r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete
+
+	case r.Method != http.MethodGet:
+		return nil, false
+
+	case r.URL.Path == "/assets/read":
+		return s.asset(r.URL.Query().Get("ref"))
+
+	case match(segments, "instances", "*", "*", "address", "public"):
+		return lookup(s.fixture.InstanceHosts, segments[2])
+
+	case len(segments) < 2 || segments[0] != "config":
+		return nil, false
+
+	case match(segments, "config", "identity"):
+		return s.fixture.Identity, true
+
+	case match(segments, "config", "instance"):
+		if s.fixture.InstanceConfig == nil {
+			return map[string]any{}, true
+		}
+		return s.fixture.InstanceConfig, true
+
+	case match(segments, "config", "provides", "*"):
+		return lookup(s.fixture.ProviderPorts, segments[2])
+
+	case match(segments, "config", "tls", "provides", "*"):
+		return lookup(s.fixture.ServerTLS, segments[3])
+
+	case match(segments, "config", "tls", "consumes", "*"):
+		return lookup(s.fixture.ClientTLS, segments[3])
+
+	case match(segments, "config", "operator", "*"):
+		return lookup(s.fixture.Operators, segments[2])
+
+	case match(segments, "config", "consumes", "resource", "*", "*", "*"):
+		for _, resource := range s.fixture.Resources {
+			if (resource.Type == "" || strings.EqualFold(resource.Type, segments[3])) &&
+				strings.EqualFold(resource.PortType, segments[4]) &&
+				strings.EqualFold(resource.Name, segments[5]) {
+				return resource.Info, true
+			}
+		}
+		return nil, false
+
+	case match(segments, "config", "consumes", "*", "*", "endpoints"):
+		service, found := s.service(segments[2], segments[3])
+		if !found || service.Endpoints == nil {
+			return nil, false
+		}
+		return service.Endpoints, true
+
+	case match(segments, "config", "consumes", "*", "*"):
+		service, found := s.service(segments[2], segments[3])
+		if !found {
+			return nil, false
+		}
+		return service.Address, true
+	}
+
+	return nil, false
+}
+
+func (s *Server) service(name, portType string) (Service, bool) {
+	for _, service := range s.fixture.Services {
+		if strings.EqualFold(service.Name, name) && strings.EqualFold(service.PortType, portType) {
+			return service, true
+		}
+	}
+	return Service{}, false
+}
+
+func (s *Server) asset(ref string) (any, bool) {
+	for assetRef, asset := range s.fixture.Assets {
+		if strings.EqualFold(assetRef, ref) {
+			return map[string]any{"data": asset}, true
+		}
+	}
+	return nil, false
+}
+
+// lookup finds a value by case-insensitive key since the local provider lower-cases path segments
+func lookup[T any](values map[string]T, key string) (any, bool) {
+	for k, v := range values {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func match(segments []string, pattern ...string) bool {
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package clustertest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+const fixtureYAML = `
+identity:
+  systemId: kapeta://kapeta/my-plan:local
+  instanceId: instance-id
+instanceConfig:
+  greeting: hello
+providerPorts:
+  rest: "40001"
+services:
+  - name: users
+    portType: rest
+    address: http://127.0.0.1:40002/
+    endpoints:
+      - address: http://127.0.0.1:40002/
+        instanceId: users-1
+      - address: http://127.0.0.1:40003/
+        instanceId: users-2
+resources:
+  - type: kapeta/resource-type-mongodb
+    portType: mongodb
+    name: messages
+    info:
+      host: 127.0.0.1
+      port: 27017
+      type: mongodb
+instanceHosts:
+  other-instance: 10.0.0.2
+`
+
+func TestServerWithLocalProvider(t *testing.T) {
+	fixture, err := clustertest.ParseFixture([]byte(fixtureYAML))
+	assert.NoError(t, err)
+
+	srv := clustertest.Start(t, fixture)
+
+	provider := providers.NewLocalConfigProvider("kapeta/my-block:local", "", "", map[string]interface{}{})
+	assert.Equal(t, "kapeta://kapeta/my-plan:local", provider.GetSystemId())
+	assert.Equal(t, "instance-id", provider.GetInstanceId())
+	assert.Equal(t, "hello", provider.Get("greeting"))
+
+	port, err := provider.GetServerPort("rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "40001", port)
+
+	address, err := provider.GetServiceAddress("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:40002/", address)
+
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, "users-2", endpoints[1].InstanceId)
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", info.Host)
+	assert.Equal(t, json.Number("27017"), info.Port)
+
+	host, err := provider.GetInstanceHost("other-instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", host)
+
+	registrations := srv.RequestsTo(http.MethodPut, "/instances")
+	assert.Len(t, registrations, 1)
+	assert.Equal(t, "kapeta/my-block:local", registrations[0].Header.Get("X-Kapeta-Block"))
+	assert.Equal(t, "instance-id", registrations[0].Header.Get("X-Kapeta-Instance"))
+	assert.Contains(t, string(registrations[0].Body), `"pid"`)
+}
+
+func TestServerGoFixture(t *testing.T) {
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity: clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		Operators: map[string]any{
+			"operator-id": providers.InstanceOperator{
+				Hostname: "db",
+				Ports:    map[string]providers.InstanceOperatorPort{"postgres": {Protocol: "tcp", Port: 5432}},
+			},
+		},
+		Assets: map[string]any{
+			"system-id": model.Plan{
+				Spec: model.PlanSpec{
+					Blocks: []model.BlockInstance{{Id: "provider-id", Block: model.AssetReference{Ref: "kapeta/provider:local"}}},
+					Connections: []model.Connection{{
+						Consumer: model.Endpoint{BlockId: "instance-id", ResourceName: "users"},
+						Provider: model.Endpoint{BlockId: "provider-id", ResourceName: "users"},
+					}},
+				},
+			},
+			"kapeta/provider:local": model.Kind{Kind: "kapeta/block-type-service", Metadata: model.Metadata{Name: "kapeta/provider"}},
+		},
+	})
+
+	provider := providers.NewLocalConfigProvider("kapeta/my-block:local", "", "", map[string]interface{}{})
+
+	operator, err := provider.GetInstanceOperator("operator-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "db", operator.Hostname)
+	assert.Equal(t, 5432, operator.Ports["postgres"].Port)
+
+	details, err := provider.GetInstanceForConsumer("users")
+	assert.NoError(t, err)
+	assert.Equal(t, "provider-id", details.InstanceId)
+	assert.Equal(t, "kapeta/provider", details.Block.Metadata.Name)
+
+	srv.Update(func(fixture *clustertest.Fixture) {
+		fixture.ProviderPorts = map[string]string{"grpc": "40010"}
+	})
+	port, err := provider.GetServerPort("grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, "40010", port)
+
+	srv.ResetRequests()
+	_, _ = provider.GetServiceAddress("missing", "rest")
+	requests := srv.Requests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "/config/consumes/missing/rest", requests[0].Path)
+	assert.Equal(t, "system-id", requests[0].Header.Get("X-Kapeta-System"))
+}
+
+func TestServerFailures(t *testing.T) {
+	clustertest.Start(t, &clustertest.Fixture{
+		Identity: clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		Services: []clustertest.Service{{Name: "users", PortType: "rest", Address: "http://127.0.0.1:40002/"}},
+		Failures: map[string]int{"/config/consumes/users/rest": http.StatusInternalServerError},
+	})
+
+	provider := providers.NewLocalConfigProvider("kapeta/my-block:local", "", "", map[string]interface{}{})
+
+	_, err := provider.GetServiceAddress("users", "rest")
+	assert.EqualError(t, err, "failed to send GET request: request failed - Status: 500")
+	assert.NotErrorIs(t, err, providers.ErrNotFound)
+}
+
+func TestServerSetenvIgnoresDeveloperSettings(t *testing.T) {
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "http://127.0.0.1:1")
+	t.Setenv("KAPETA_PROFILE", "feature")
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity:      clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		ProviderPorts: map[string]string{"rest": "40001"},
+	})
+
+	provider := providers.NewLocalConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
+	port, err := provider.GetServerPort("rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "40001", port)
+	assert.NotEmpty(t, srv.RequestsTo(http.MethodGet, "/config/identity"), "the fake cluster service is used")
+}
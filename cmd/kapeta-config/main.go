@@ -0,0 +1,157 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/doctor"
+	"github.com/kapetacom/sdk-go-config/envcontract"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+const usage = `Usage: kapeta-config <command> [flags]
+
+Commands:
+  doctor    Check that the configuration of a block can be resolved
+  env       List the environment variables a block needs on Kubernetes
+  export    Export the configuration a block resolves as Kubernetes environment variables
+
+Run 'kapeta-config <command> -h' for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "doctor":
+		return runDoctor(args[1:], stdout, stderr)
+	case "env":
+		return runEnv(args[1:], stdout, stderr)
+	case "export":
+		return runExport(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func runDoctor(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	blockDir := flags.String("dir", ".", "folder containing kapeta.yml")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	dir, err := filepath.Abs(*blockDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid block directory: %v\n", err)
+		return 2
+	}
+
+	report := doctor.Run(dir)
+	if err := report.WriteTable(stdout); err != nil {
+		fmt.Fprintf(stderr, "failed to write report: %v\n", err)
+		return 1
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func runEnv(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("env", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	blockDir := flags.String("dir", ".", "folder containing kapeta.yml")
+	format := flags.String("format", envcontract.FormatKubernetes, "output format: "+strings.Join(envcontract.Formats, ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	blockDefinition, err := config.LoadBlockDefinition(*blockDir)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	block, err := blockdef.Parse(blockDefinition)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := envcontract.Write(stdout, *format, envcontract.ForBlock(block)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
+
+func runExport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	blockDir := flags.String("dir", ".", "folder containing kapeta.yml")
+	format := flags.String("format", envcontract.FormatDotenv, "output format: "+strings.Join(envcontract.Formats, ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if !slices.Contains(envcontract.Formats, *format) {
+		fmt.Fprintf(stderr, "unknown format: %s, must be one of %s\n", *format, strings.Join(envcontract.Formats, ", "))
+		return 2
+	}
+
+	dir, err := filepath.Abs(*blockDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid block directory: %v\n", err)
+		return 2
+	}
+
+	provider, err := config.Init(dir)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if stopper, ok := providers.As[interface{ InstanceStopped() }](provider); ok {
+		// The instance is registered when the local provider is created, so it must be unregistered again
+		defer stopper.InstanceStopped()
+	}
+
+	blockDefinition, _ := provider.GetBlockDefinition().(map[string]interface{})
+	block, err := blockdef.Parse(blockDefinition)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	env, err := envcontract.ExportEnvironment(provider, block)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := envcontract.Write(stdout, *format, envcontract.WithValues(envcontract.ForBlock(block), env)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
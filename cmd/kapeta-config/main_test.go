@@ -0,0 +1,77 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 2, run(nil, stdout, stderr))
+	assert.Contains(t, stderr.String(), "Usage: kapeta-config")
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"help"}, stdout, stderr))
+	assert.Contains(t, stdout.String(), "doctor")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"unknown"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "unknown command: unknown")
+}
+
+func TestRunDoctor(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "../../testdata/static.yml")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 0, run([]string{"doctor", "-dir", "../../testdata/block"}, stdout, stderr), stdout.String())
+	assert.Contains(t, stdout.String(), "0 failed")
+
+	stdout.Reset()
+	assert.Equal(t, 1, run([]string{"doctor", "-dir", "../../testdata/invalid"}, stdout, stderr))
+	assert.Contains(t, stdout.String(), "FAIL")
+}
+
+func TestRunEnv(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 0, run([]string{"env", "-dir", "../../testdata/block", "-format", "dotenv"}, stdout, stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB=\n")
+
+	assert.Equal(t, 2, run([]string{"env", "-dir", "../../testdata/block", "-format", "xml"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "unknown format: xml")
+
+	assert.Equal(t, 1, run([]string{"env", "-dir", "../../testdata/invalid"}, stdout, stderr))
+}
+
+// TestRunExportInitError runs before TestRunExport, since Init keeps the first provider it creates
+func TestRunExportInitError(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "local")
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster: [invalid")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 1, run([]string{"export", "-dir", "../../testdata/block"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "failed to load cluster config")
+}
+
+func TestRunExport(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "../../testdata/static.yml")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 0, run([]string{"export", "-dir", "../../testdata/block", "-format", "json"}, stdout, stderr), stderr.String())
+
+	env := map[string]string{}
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &env))
+	assert.Equal(t, "40001", env["KAPETA_PROVIDER_PORT_REST"])
+	assert.JSONEq(t, `{"host":"127.0.0.1","port":27017,"type":"mongodb","protocol":"mongodb","options":null,"credentials":{"username":"kapeta","password":"secret"}}`,
+		env["KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB"])
+
+	assert.Equal(t, 2, run([]string{"export", "-format", "xml"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "unknown format: xml")
+}
@@ -4,10 +4,17 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/kapetacom/sdk-go-config/ref"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,19 +22,102 @@ const (
 	KAPETA_CLUSTER_SERVICE_CONFIG_FILE  = "cluster-service.yml"
 	KAPETA_CLUSTER_SERVICE_DEFAULT_PORT = "35100"
 	KAPETA_CLUSTER_SERVICE_DEFAULT_HOST = "127.0.0.1"
+	KAPETA_CLUSTER_SERVICE_TOKEN_FILE   = "cluster-service.token"
 )
 
+// ClusterConfig is the configuration of the local cluster service, read from ~/.kapeta/cluster-service.yml
 type ClusterConfig struct {
-	Cluster *Cluster `json:"cluster,omitempty"`
+	Cluster     *Cluster `json:"cluster,omitempty"`
+	Docker      *Docker  `yaml:"docker,omitempty"`
+	Environment string   `yaml:"environment,omitempty"`
+	// Services holds the ports assigned to instances, by plan reference, instance ID and port type
+	Services map[string]map[string]map[string]ServicePort `yaml:"services,omitempty"`
+	// Config holds the configuration of instances, by plan reference and instance ID
+	Config     map[string]map[string]map[string]interface{} `yaml:"config,omitempty"`
+	Instances  []ClusterInstance                            `yaml:"instances,omitempty"`
+	Filesystem *Filesystem                                  `yaml:"filesystem,omitempty"`
+	App        *App                                         `yaml:"app,omitempty"`
+	logger     *slog.Logger
+	// lazy is set for configs created with NewClusterConfig, which read cluster-service.yml when used
+	lazy bool
 }
 type Cluster struct {
 	Port string `yaml:"port"`
 	Host string `yaml:"host"`
+	// URL replaces host and port. It is http:// or https:// followed by the host and port, or unix://
+	// followed by the path of the socket the cluster service listens on.
+	URL string `yaml:"url,omitempty"`
+	// CAFile is the CA bundle to verify the cluster service with over https
+	CAFile string `yaml:"caFile,omitempty"`
+	// TokenFile holds the bearer token to authenticate with. It defaults to cluster-service.token in the Kapeta directory.
+	TokenFile string `yaml:"tokenFile,omitempty"`
 }
 
-// NewClusterConfig creates a new instance of ClusterConfig
+// ClusterConnection is how to reach the local cluster service
+type ClusterConnection struct {
+	// URL is http://host:port, https://host:port or unix:///path/to.sock
+	URL    string
+	CAFile string
+	// Token is sent as a bearer token, if not empty
+	Token string
+}
+
+// Docker is how the cluster service connects to Docker. Empty fields mean the Docker defaults.
+type Docker struct {
+	SocketPath string `yaml:"socketPath,omitempty"`
+	Protocol   string `yaml:"protocol,omitempty"`
+	Host       string `yaml:"host,omitempty"`
+	Port       string `yaml:"port,omitempty"`
+}
+
+// ServicePort is a port assigned to an instance
+type ServicePort struct {
+	Port int `yaml:"port"`
+}
+
+// ClusterInstance is an instance last known to the cluster service
+type ClusterInstance struct {
+	SystemID      string `yaml:"systemId"`
+	InstanceID    string `yaml:"instanceId"`
+	Ref           string `yaml:"ref"`
+	Name          string `yaml:"name"`
+	DesiredStatus string `yaml:"desiredStatus"`
+	Owner         string `yaml:"owner"`
+	Type          string `yaml:"type"`
+	Status        string `yaml:"status"`
+	// StartedAt is in milliseconds since the epoch
+	StartedAt    int64  `yaml:"startedAt"`
+	Address      string `yaml:"address"`
+	Health       string `yaml:"health"`
+	PID          string `yaml:"pid"`
+	PortType     string `yaml:"portType"`
+	ErrorMessage string `yaml:"errorMessage,omitempty"`
+}
+
+type Filesystem struct {
+	ProjectRoot string `yaml:"project_root"`
+}
+
+type App struct {
+	ReleaseChannel string `yaml:"release_channel"`
+}
+
+// NewClusterConfig creates a ClusterConfig that reads cluster-service.yml when it is used, see LoadClusterConfig.
+// The methods of a config returned by LoadClusterConfig or ParseClusterConfig only use the config itself.
 func NewClusterConfig() *ClusterConfig {
-	return &ClusterConfig{}
+	return &ClusterConfig{lazy: true}
+}
+
+// SetLogger sets the logger used when reading the cluster config, instead of the SDK-wide logger
+func (c *ClusterConfig) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+func (c *ClusterConfig) getLogger() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return Logger()
 }
 
 func (c *ClusterConfig) getClusterServicePort() string {
@@ -35,14 +125,14 @@ func (c *ClusterConfig) getClusterServicePort() string {
 		return envPort
 	}
 
-	return c.GetClusterConfig().Cluster.Port
+	return c.cluster().Port
 }
 
 func (c *ClusterConfig) getClusterServiceHost() string {
 	if envHost := os.Getenv("KAPETA_LOCAL_CLUSTER_HOST"); envHost != "" {
 		return envHost
 	}
-	return c.GetClusterConfig().Cluster.Host
+	return c.cluster().Host
 }
 
 func (c *ClusterConfig) getKapetaBasedir() string {
@@ -50,58 +140,325 @@ func (c *ClusterConfig) getKapetaBasedir() string {
 }
 
 func (c *ClusterConfig) getClusterConfigFile() string {
-	return filepath.Join(c.getKapetaBasedir(), KAPETA_CLUSTER_SERVICE_CONFIG_FILE)
+	return clusterConfigFile()
+}
+
+func clusterConfigFile() string {
+	return filepath.Join(GetProfileDir(), KAPETA_CLUSTER_SERVICE_CONFIG_FILE)
+}
+
+// GetProfile returns the profile selected with KAPETA_PROFILE, or an empty string for the default profile
+func GetProfile() string {
+	return os.Getenv("KAPETA_PROFILE")
 }
 
+// GetProfileDir returns the directory holding cluster-service.yml for the selected profile:
+// profiles/<name> in the Kapeta directory for a named profile, and the Kapeta directory itself otherwise
+func GetProfileDir() string {
+	if profile := GetProfile(); profile != "" {
+		return filepath.Join(getKapetaDir(), "profiles", profile)
+	}
+	return getKapetaDir()
+}
+
+func validateProfile(profile string) error {
+	if profile == "" {
+		return nil
+	}
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return fmt.Errorf("invalid KAPETA_PROFILE %q: must be a directory name", profile)
+	}
+	return nil
+}
+
+// GetClusterConfig returns the config itself, unless it was created with NewClusterConfig. Then cluster-service.yml
+// is loaded, see LoadClusterConfig, and if it cannot be, the error is logged and a config with only the defaults
+// is returned.
 func (c *ClusterConfig) GetClusterConfig() *ClusterConfig {
-	if os.Getenv("TEST_KAPETA_CLUSTER_CONFIG_FILE") != "" {
-		err := yaml.Unmarshal([]byte(os.Getenv("TEST_KAPETA_CLUSTER_CONFIG_FILE")), &c)
-		if err != nil {
-			fmt.Printf("Error unmarshalling cluster config from test config: %s\n", err)
-			return nil
-		}
-	} else {
-		if _, err := os.Stat(c.getClusterConfigFile()); err == nil {
-			rawYAML, err := os.ReadFile(c.getClusterConfigFile())
+	if !c.lazy {
+		return c
+	}
+	config, err := loadClusterConfig(c.getLogger(), false)
+	if err != nil {
+		c.getLogger().Error("error loading cluster config", "error", err)
+		return withClusterDefaults(&ClusterConfig{})
+	}
+	return config
+}
+
+// cluster returns the cluster section of the config with the defaults for the cluster service address
+func (c *ClusterConfig) cluster() *Cluster {
+	cluster := &Cluster{}
+	if config := c.GetClusterConfig(); config.Cluster != nil {
+		*cluster = *config.Cluster
+	}
+	if cluster.Port == "" {
+		cluster.Port = KAPETA_CLUSTER_SERVICE_DEFAULT_PORT
+	}
+	if cluster.Host == "" {
+		cluster.Host = KAPETA_CLUSTER_SERVICE_DEFAULT_HOST
+	}
+	return cluster
+}
+
+// clusterConfigCache holds the last cluster config loaded, and where it was loaded from
+var clusterConfigCache struct {
+	sync.Mutex
+	source  string
+	modTime time.Time
+	config  *ClusterConfig
+}
+
+// LoadClusterConfig reads cluster-service.yml from the Kapeta directory, with defaults for the cluster service
+// address. With KAPETA_PROFILE set, the file of the profile is read instead, see GetProfileDir.
+// A missing file is only an error for a named profile. The file is only read once: the config is cached until
+// ReloadClusterConfig is called, a watch started with WatchClusterConfig sees the file change, or KAPETA_HOME
+// or KAPETA_PROFILE change. If reading it again fails, the last config loaded is kept. The returned config is shared
+// and must not be modified.
+func LoadClusterConfig() (*ClusterConfig, error) {
+	return loadClusterConfig(Logger(), false)
+}
+
+// ReloadClusterConfig reads cluster-service.yml again, see LoadClusterConfig
+func ReloadClusterConfig() (*ClusterConfig, error) {
+	return loadClusterConfig(Logger(), true)
+}
+
+// WatchClusterConfig checks cluster-service.yml for changes every interval until the context is done.
+// When the file changes, it is reloaded and onChange, if not nil, is called with the new config or the error
+// reading it. It returns immediately.
+func WatchClusterConfig(ctx context.Context, interval time.Duration, onChange func(*ClusterConfig, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !clusterConfigChanged() {
+				continue
+			}
+			config, err := ReloadClusterConfig()
 			if err != nil {
-				fmt.Printf("Error reading cluster config file: %s\n", err)
-				return nil
+				Logger().Warn("error reloading cluster config", "path", clusterConfigFile(), "error", err)
+			}
+			if onChange != nil {
+				onChange(config, err)
 			}
+		}
+	}()
+}
 
-			err = yaml.Unmarshal(rawYAML, &c)
-			if err != nil {
-				fmt.Printf("Error unmarshalling cluster config: %s\n", err)
-				return nil
+func loadClusterConfig(logger *slog.Logger, reload bool) (*ClusterConfig, error) {
+	clusterConfigCache.Lock()
+	defer clusterConfigCache.Unlock()
+
+	source, testConfig := clusterConfigSource()
+	if !reload && clusterConfigCache.config != nil && clusterConfigCache.source == source {
+		return clusterConfigCache.config, nil
+	}
+
+	var data []byte
+	var modTime time.Time
+	name := source
+	if testConfig {
+		data = []byte(source)
+		name = "TEST_KAPETA_CLUSTER_CONFIG_FILE"
+	} else {
+		profile := GetProfile()
+		if err := validateProfile(profile); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(source)
+		switch {
+		case os.IsNotExist(err) && profile != "":
+			// Falling back to the default address would silently use the cluster service of another profile
+			return nil, fmt.Errorf("cluster config for profile %s not found: %s", profile, source)
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, fmt.Errorf("error reading cluster config file %s: %w", source, err)
+		default:
+			modTime = info.ModTime()
+			if data, err = os.ReadFile(source); err != nil {
+				return nil, fmt.Errorf("error reading cluster config file %s: %w", source, err)
 			}
 		}
 	}
-	if c == nil {
-		c = &ClusterConfig{}
+
+	config, err := ParseClusterConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	logger.Debug("read cluster config from file", "path", name)
+
+	clusterConfigCache.source = source
+	clusterConfigCache.modTime = modTime
+	clusterConfigCache.config = withClusterDefaults(config)
+	return clusterConfigCache.config, nil
+}
+
+// clusterConfigSource returns the path of cluster-service.yml, or the content of TEST_KAPETA_CLUSTER_CONFIG_FILE
+func clusterConfigSource() (string, bool) {
+	if testConfig := os.Getenv("TEST_KAPETA_CLUSTER_CONFIG_FILE"); testConfig != "" {
+		return testConfig, true
 	}
+	return clusterConfigFile(), false
+}
+
+// clusterConfigChanged reports whether cluster-service.yml was changed, created or removed since it was loaded
+func clusterConfigChanged() bool {
+	clusterConfigCache.Lock()
+	defer clusterConfigCache.Unlock()
 
+	source, testConfig := clusterConfigSource()
+	if source != clusterConfigCache.source {
+		return true
+	}
+	if testConfig {
+		return false
+	}
+	var modTime time.Time
+	if info, err := os.Stat(source); err == nil {
+		modTime = info.ModTime()
+	}
+	return !modTime.Equal(clusterConfigCache.modTime)
+}
+
+func withClusterDefaults(c *ClusterConfig) *ClusterConfig {
 	if c.Cluster == nil {
 		c.Cluster = &Cluster{}
 	}
-
 	if c.Cluster.Port == "" {
 		c.Cluster.Port = KAPETA_CLUSTER_SERVICE_DEFAULT_PORT
 	}
-
 	if c.Cluster.Host == "" {
 		c.Cluster.Host = KAPETA_CLUSTER_SERVICE_DEFAULT_HOST
 	}
+	return c
+}
 
-	fmt.Printf("Read cluster config from file: %s\n", c.getClusterConfigFile())
+// ParseClusterConfig parses the content of a cluster-service.yml file, without applying defaults
+func ParseClusterConfig(data []byte) (*ClusterConfig, error) {
+	c := &ClusterConfig{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error unmarshalling cluster config: %w", err)
+	}
+	return c, nil
+}
 
-	return c
+// ServicePort returns the port assigned to the port type of the instance in the plan
+func (c *ClusterConfig) ServicePort(planRef, instanceID, portType string) (int, bool) {
+	instances, exists := lookupPlan(c.GetClusterConfig().Services, planRef)
+	if !exists {
+		return 0, false
+	}
+	port, exists := instances[instanceID][portType]
+	return port.Port, exists
+}
+
+// InstanceConfig returns the configuration of the instance in the plan
+func (c *ClusterConfig) InstanceConfig(planRef, instanceID string) (map[string]interface{}, bool) {
+	instances, exists := lookupPlan(c.GetClusterConfig().Config, planRef)
+	if !exists {
+		return nil, false
+	}
+	config, exists := instances[instanceID]
+	return config, exists
 }
 
+// Instance returns the instance in the plan
+func (c *ClusterConfig) Instance(planRef, instanceID string) (*ClusterInstance, bool) {
+	instances := c.GetClusterConfig().Instances
+	for i, instance := range instances {
+		if instance.InstanceID == instanceID && sameRef(instance.SystemID, planRef) {
+			return &instances[i], true
+		}
+	}
+	return nil, false
+}
+
+// InstancesForBlock returns the instances of the block in every plan
+func (c *ClusterConfig) InstancesForBlock(blockRef string) []ClusterInstance {
+	var instances []ClusterInstance
+	for _, instance := range c.GetClusterConfig().Instances {
+		if sameRef(instance.Ref, blockRef) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// lookupPlan returns the entry of the plan, which may be referenced with or without the kapeta:// scheme.
+// An exact match is preferred, and otherwise the first matching reference in sorted order is used.
+func lookupPlan[T any](plans map[string]T, planRef string) (T, bool) {
+	if value, exists := plans[planRef]; exists {
+		return value, true
+	}
+	refs := make([]string, 0, len(plans))
+	for ref := range plans {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		if sameRef(ref, planRef) {
+			return plans[ref], true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func sameRef(a, b string) bool {
+	refA, errA := ref.Parse(a)
+	refB, errB := ref.Parse(b)
+	if errA == nil && errB == nil {
+		return refA.Equal(refB)
+	}
+	return strings.TrimPrefix(a, "kapeta://") == strings.TrimPrefix(b, "kapeta://")
+}
+
+// GetClusterServiceAddress returns the URL of the cluster service, see GetClusterServiceConnection
 func (c *ClusterConfig) GetClusterServiceAddress() string {
+	if envURL := os.Getenv("KAPETA_LOCAL_CLUSTER_URL"); envURL != "" {
+		return strings.TrimSuffix(envURL, "/")
+	}
+	envHostOrPort := os.Getenv("KAPETA_LOCAL_CLUSTER_HOST") != "" || os.Getenv("KAPETA_LOCAL_CLUSTER_PORT") != ""
+	if clusterURL := c.cluster().URL; clusterURL != "" && !envHostOrPort {
+		return strings.TrimSuffix(clusterURL, "/")
+	}
 	clusterPort := c.getClusterServicePort()
 	host := c.getClusterServiceHost()
 	return fmt.Sprintf("http://%s:%s", host, clusterPort)
 }
 
+// GetClusterServiceConnection returns how to reach the cluster service. The URL is read from
+// KAPETA_LOCAL_CLUSTER_URL, then built from KAPETA_LOCAL_CLUSTER_HOST and KAPETA_LOCAL_CLUSTER_PORT,
+// and then read from cluster.url or built from cluster.host and cluster.port in cluster-service.yml.
+// The CA bundle is read from KAPETA_LOCAL_CLUSTER_CA_FILE or cluster.caFile, and the token from the token file.
+func (c *ClusterConfig) GetClusterServiceConnection() (*ClusterConnection, error) {
+	cluster := c.cluster()
+	connection := &ClusterConnection{
+		URL:    c.GetClusterServiceAddress(),
+		CAFile: cluster.CAFile,
+	}
+	if envCAFile := os.Getenv("KAPETA_LOCAL_CLUSTER_CA_FILE"); envCAFile != "" {
+		connection.CAFile = envCAFile
+	}
+
+	tokenFile := cluster.TokenFile
+	if tokenFile == "" {
+		tokenFile = filepath.Join(GetProfileDir(), KAPETA_CLUSTER_SERVICE_TOKEN_FILE)
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil && (!os.IsNotExist(err) || cluster.TokenFile != "") {
+		return nil, fmt.Errorf("error reading cluster service token: %w", err)
+	}
+	connection.Token = strings.TrimSpace(string(token))
+	return connection, nil
+}
+
 func getKapetaDir() string {
 	kapetaDir := os.Getenv("KAPETA_HOME")
 	if kapetaDir == "" {
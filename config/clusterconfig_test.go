@@ -4,11 +4,15 @@
 package config
 
 import (
+	"context"
 	_ "embed"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/kapetacom/sdk-go-config/testdata"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewClusterConfig(t *testing.T) {
@@ -54,3 +58,212 @@ func TestGetClusterServiceAddress(t *testing.T) {
 	c := NewClusterConfig()
 	assert.Equal(t, "http://127.0.0.1:35100", c.GetClusterServiceAddress())
 }
+
+func TestParseClusterConfig(t *testing.T) {
+	c, err := ParseClusterConfig(testdata.ClusterServiceYml)
+	require.NoError(t, err)
+
+	assert.Equal(t, &Cluster{Port: "35100", Host: "127.0.0.1"}, c.Cluster)
+	assert.Equal(t, &Docker{}, c.Docker)
+	assert.Equal(t, "production", c.Environment)
+	assert.Equal(t, "/home/smo/KapetaProjects", c.Filesystem.ProjectRoot)
+	assert.Equal(t, "beta", c.App.ReleaseChannel)
+
+	port, ok := c.ServicePort("kapeta://soren_mathiasen/go-go-gadget:local", "ce86b032-b899-4bac-a43c-448dd7f934f5", "http")
+	assert.True(t, ok)
+	assert.Equal(t, 40004, port)
+	port, ok = c.ServicePort("sorenmat/sample-java-chat-plan:local", "kapeta/resource-type-mongodb", "mongodb")
+	assert.True(t, ok, "plans can be referenced without the scheme")
+	assert.Equal(t, 40001, port)
+	_, ok = c.ServicePort("kapeta://sorenmat/go-sample:local", "b2a05a9a-8bcb-4afd-80b1-0416a8f26557", "grpc")
+	assert.False(t, ok)
+	_, ok = c.ServicePort("kapeta://sorenmat/unknown:local", "b2a05a9a-8bcb-4afd-80b1-0416a8f26557", "http")
+	assert.False(t, ok)
+
+	config, ok := c.InstanceConfig("kapeta://sorenmat/go-sample:local", "b2a05a9a-8bcb-4afd-80b1-0416a8f26557")
+	assert.True(t, ok)
+	assert.Empty(t, config)
+	_, ok = c.InstanceConfig("kapeta://sorenmat/go-sample:local", "unknown")
+	assert.False(t, ok)
+
+	require.Len(t, c.Instances, 5)
+	instance, ok := c.Instance("kapeta://soren_mathiasen/go-go-gadget:local", "bb713f13-8797-464f-a04a-f616f2c4cc0b")
+	require.True(t, ok)
+	assert.Equal(t, "kapeta://soren_mathiasen/go-backend:local", instance.Ref)
+	assert.Equal(t, "http://127.0.0.1:40003/", instance.Address)
+	assert.Equal(t, int64(1706105695211), instance.StartedAt)
+	assert.Empty(t, instance.PID)
+	assert.Equal(t, "Cannot read properties of undefined (reading 'startsWith')", instance.ErrorMessage)
+
+	_, err = ParseClusterConfig([]byte("services: [invalid"))
+	assert.Error(t, err)
+}
+
+func TestGetClusterConfigModel(t *testing.T) {
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", string(testdata.ClusterServiceYml))
+	c := NewClusterConfig().GetClusterConfig()
+	port, ok := c.ServicePort("kapeta://sorenmat/go-sample:local", "b2a05a9a-8bcb-4afd-80b1-0416a8f26557", "http")
+	assert.True(t, ok)
+	assert.Equal(t, 40006, port)
+}
+
+func TestLoadClusterConfigCaches(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	path := home + "/cluster-service.yml"
+
+	c, err := LoadClusterConfig()
+	require.NoError(t, err, "a missing file is not an error")
+	assert.Equal(t, &Cluster{Port: KAPETA_CLUSTER_SERVICE_DEFAULT_PORT, Host: KAPETA_CLUSTER_SERVICE_DEFAULT_HOST}, c.Cluster)
+
+	require.NoError(t, os.WriteFile(path, []byte("cluster:\n  port: \"1234\"\n"), 0o600))
+	c, err = LoadClusterConfig()
+	require.NoError(t, err)
+	assert.Equal(t, KAPETA_CLUSTER_SERVICE_DEFAULT_PORT, c.Cluster.Port, "the file is only read once")
+
+	c, err = ReloadClusterConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "1234", c.Cluster.Port)
+	again, err := LoadClusterConfig()
+	require.NoError(t, err)
+	assert.Same(t, c, again)
+
+	require.NoError(t, os.WriteFile(path, []byte("cluster: [invalid"), 0o600))
+	_, err = ReloadClusterConfig()
+	assert.ErrorContains(t, err, path)
+	assert.Equal(t, "http://127.0.0.1:1234", NewClusterConfig().GetClusterServiceAddress(), "the last config loaded is kept")
+
+	home = t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	require.NoError(t, os.WriteFile(home+"/cluster-service.yml", []byte("cluster: [invalid"), 0o600))
+	_, err = LoadClusterConfig()
+	assert.Error(t, err, "the config is loaded again when KAPETA_HOME changes")
+	assert.Equal(t, "http://127.0.0.1:35100", NewClusterConfig().GetClusterServiceAddress(), "without a config loaded, errors fall back to the defaults")
+}
+
+func TestWatchClusterConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	path := home + "/cluster-service.yml"
+	require.NoError(t, os.WriteFile(path, []byte("cluster:\n  port: \"1234\"\n"), 0o600))
+	_, err := ReloadClusterConfig()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *ClusterConfig, 10)
+	WatchClusterConfig(ctx, 10*time.Millisecond, func(c *ClusterConfig, err error) {
+		assert.NoError(t, err)
+		changes <- c
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("cluster:\n  port: \"5678\"\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	select {
+	case c := <-changes:
+		assert.Equal(t, "5678", c.Cluster.Port)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change was not seen")
+	}
+	c, err := LoadClusterConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "5678", c.Cluster.Port)
+}
+
+func TestGetClusterServiceConnection(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster:\n  url: unix:///var/run/kapeta.sock\n  caFile: /etc/kapeta/ca.crt\n")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", "")
+
+	connection, err := NewClusterConfig().GetClusterServiceConnection()
+	require.NoError(t, err)
+	assert.Equal(t, &ClusterConnection{URL: "unix:///var/run/kapeta.sock", CAFile: "/etc/kapeta/ca.crt"}, connection)
+
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", "8080")
+	assert.Equal(t, "http://127.0.0.1:8080", NewClusterConfig().GetClusterServiceAddress(), "host and port in the environment take precedence over the file")
+
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "https://cluster.local:35100/")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_CA_FILE", "/tmp/ca.crt")
+	require.NoError(t, os.WriteFile(home+"/cluster-service.token", []byte(" token\n"), 0o600))
+	connection, err = NewClusterConfig().GetClusterServiceConnection()
+	require.NoError(t, err)
+	assert.Equal(t, &ClusterConnection{URL: "https://cluster.local:35100", CAFile: "/tmp/ca.crt", Token: "token"}, connection)
+
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster:\n  tokenFile: /missing/token\n")
+	_, err = NewClusterConfig().GetClusterServiceConnection()
+	assert.ErrorContains(t, err, "error reading cluster service token", "a configured token file must exist")
+}
+
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "")
+	require.NoError(t, os.WriteFile(home+"/cluster-service.yml", []byte("cluster:\n  port: \"35100\"\n"), 0o600))
+	require.NoError(t, os.MkdirAll(home+"/profiles/feature", 0o700))
+	require.NoError(t, os.WriteFile(home+"/profiles/feature/cluster-service.yml", []byte("cluster:\n  port: \"35200\"\n"), 0o600))
+
+	assert.Equal(t, "", GetProfile())
+	assert.Equal(t, home, GetProfileDir())
+	assert.Equal(t, "http://127.0.0.1:35100", NewClusterConfig().GetClusterServiceAddress())
+
+	t.Setenv("KAPETA_PROFILE", "feature")
+	assert.Equal(t, "feature", GetProfile())
+	assert.Equal(t, home+"/profiles/feature", GetProfileDir())
+	assert.Equal(t, "http://127.0.0.1:35200", NewClusterConfig().GetClusterServiceAddress(), "the profile is loaded when KAPETA_PROFILE changes")
+
+	t.Setenv("KAPETA_PROFILE", "unknown")
+	_, err := LoadClusterConfig()
+	assert.EqualError(t, err, "cluster config for profile unknown not found: "+home+"/profiles/unknown/cluster-service.yml")
+
+	t.Setenv("KAPETA_PROFILE", "../feature")
+	_, err = LoadClusterConfig()
+	assert.EqualError(t, err, `invalid KAPETA_PROFILE "../feature": must be a directory name`)
+}
+
+func TestLoadedClusterConfigUsesItself(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "")
+	require.NoError(t, os.WriteFile(home+"/cluster-service.yml", []byte("cluster:\n  port: \"1234\"\n"), 0o600))
+	loaded, err := LoadClusterConfig()
+	require.NoError(t, err)
+
+	home = t.TempDir()
+	t.Setenv("KAPETA_HOME", home)
+	require.NoError(t, os.WriteFile(home+"/cluster-service.yml", []byte("cluster:\n  port: \"5678\"\n"), 0o600))
+	assert.Equal(t, "http://127.0.0.1:1234", loaded.GetClusterServiceAddress(), "a loaded config is not changed by KAPETA_HOME")
+	assert.Same(t, loaded, loaded.GetClusterConfig())
+	assert.Equal(t, "http://127.0.0.1:5678", NewClusterConfig().GetClusterServiceAddress())
+
+	parsed, err := ParseClusterConfig([]byte("environment: production\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:35100", parsed.GetClusterServiceAddress(), "a parsed config without a cluster section uses the defaults")
+}
+
+func TestLookupPlanIsDeterministic(t *testing.T) {
+	c, err := ParseClusterConfig([]byte(`
+services:
+  kapeta://acme/plan:local:
+    instance:
+      rest:
+        port: 1
+  acme/plan:local:
+    instance:
+      rest:
+        port: 2
+`))
+	require.NoError(t, err)
+
+	port, _ := c.ServicePort("kapeta://acme/plan:local", "instance", "rest")
+	assert.Equal(t, 1, port, "an exact match is preferred")
+	for i := 0; i < 20; i++ {
+		port, _ = c.ServicePort("kapeta://ACME/plan:local", "instance", "rest")
+		assert.Equal(t, 2, port, "the first matching reference in sorted order is used")
+	}
+}
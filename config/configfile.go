@@ -5,32 +5,41 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
-// ReadConfigFile reads the environment configuration file and returns the map
+// ReadConfigFile reads the environment configuration file and returns the map.
+// It panics if the file cannot be read, see LoadConfigFile.
 func ReadConfigFile() map[string]string {
+	out, err := LoadConfigFile()
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// LoadConfigFile reads the JSON file KAPETA_CONFIG_PATH points at, if set, and returns its values
+func LoadConfigFile() (map[string]string, error) {
 	out := make(map[string]string)
 	kapetaConfigPath := os.Getenv("KAPETA_CONFIG_PATH")
 
 	if kapetaConfigPath == "" {
-		return out
+		return out, nil
 	}
 
 	// Open the JSON file
 	file, err := os.Open(kapetaConfigPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
 
 	decoder := json.NewDecoder(file)
 
-	err = decoder.Decode(&out)
-
-	if err != nil {
-		panic(err)
+	if err := decoder.Decode(&out); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", kapetaConfigPath, err)
 	}
 
-	return out
+	return out, nil
 }
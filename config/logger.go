@@ -0,0 +1,29 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	// Quiet by default: only warnings and errors are written
+	logger.Store(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+}
+
+// SetLogger replaces the logger used by the SDK. A nil logger is ignored.
+func SetLogger(l *slog.Logger) {
+	if l != nil {
+		logger.Store(l)
+	}
+}
+
+// Logger returns the logger used by the SDK
+func Logger() *slog.Logger {
+	return logger.Load()
+}
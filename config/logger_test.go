@@ -0,0 +1,32 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultLoggerIsQuiet(t *testing.T) {
+	assert.False(t, Logger().Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, Logger().Enabled(context.Background(), slog.LevelWarn))
+}
+
+func TestSetLogger(t *testing.T) {
+	original := Logger()
+	defer SetLogger(original)
+
+	buf := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	SetLogger(nil)
+
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster:\n  port: \"1234\"\n")
+	c := NewClusterConfig()
+	assert.Equal(t, "1234", c.GetClusterConfig().Cluster.Port)
+	assert.Contains(t, buf.String(), "read cluster config from file")
+}
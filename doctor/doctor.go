@@ -0,0 +1,149 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusWarn Status = "WARN"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Check is the outcome of a single diagnostic. Hint tells the user how to fix a failing or warning check.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string
+}
+
+// Report holds every check made for a block
+type Report struct {
+	SystemType string
+	BlockRef   string
+	Checks     []Check
+}
+
+// Failed returns true if any check failed. Warnings do not count as failures.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(check Check) {
+	r.Checks = append(r.Checks, check)
+}
+
+func (r *Report) pass(name, detail string) {
+	r.add(Check{Name: name, Status: StatusPass, Detail: detail})
+}
+
+func (r *Report) warn(name, detail, hint string) {
+	r.add(Check{Name: name, Status: StatusWarn, Detail: detail, Hint: hint})
+}
+
+func (r *Report) fail(name, detail, hint string) {
+	r.add(Check{Name: name, Status: StatusFail, Detail: detail, Hint: hint})
+}
+
+func (r *Report) skip(name, detail string) {
+	r.add(Check{Name: name, Status: StatusSkip, Detail: detail})
+}
+
+// WriteTable writes the report as a table with a remediation hint below every check that did not pass
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Block:\t%s\n", r.BlockRef)
+	fmt.Fprintf(tw, "Provider:\t%s\n\n", r.SystemType)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	counts := map[Status]int{}
+	fmt.Fprintln(tw, "STATUS\tCHECK\tDETAIL")
+	for _, check := range r.Checks {
+		counts[check.Status]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+		if check.Hint != "" {
+			fmt.Fprintf(tw, "\t\t-> %s\n", check.Hint)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[StatusPass], counts[StatusWarn], counts[StatusFail], counts[StatusSkip])
+	return tw.Flush()
+}
+
+// Run loads kapeta.yml from the block directory the way Init does, detects the provider from KAPETA_SYSTEM_TYPE
+// and checks that everything the block declares can be resolved
+func Run(blockDir string) *Report {
+	report := &Report{}
+
+	env, err := config.LoadEnvironment(blockDir)
+	if err != nil {
+		report.fail("block definition", err.Error(), "run from the block directory or pass the folder containing kapeta.yml")
+		return report
+	}
+	report.SystemType = env.SystemType
+	report.BlockRef = env.BlockRef
+
+	block, err := blockdef.Parse(env.BlockDefinition)
+	if err != nil {
+		report.fail("block definition", err.Error(), "fix kapeta.yml so it matches the block definition schema")
+		return report
+	}
+	report.pass("block definition", fmt.Sprintf("%d providers, %d consumers", len(block.Providers), len(block.Consumers)))
+
+	// The local, Kubernetes and docker providers read the environment and the optional config file,
+	// and fail to start if the file cannot be read
+	envConfig, err := cfg.LoadConfigFile()
+	if err != nil {
+		report.fail("environment config file", err.Error(), "point KAPETA_CONFIG_PATH at a JSON file of environment variables, or unset it")
+		return report
+	}
+	if path := os.Getenv("KAPETA_CONFIG_PATH"); path != "" {
+		report.pass("environment config file", path)
+	}
+	lookup := &providers.AbstractConfigProvider{EnvironmentConfiguration: envConfig}
+
+	switch env.SystemType {
+	case "k8s", "kubernetes":
+		checkKubernetes(report, env, block, lookup)
+	case "development", "dev", "local":
+		checkLocal(report, env, block, lookup)
+	case "static":
+		checkStatic(report, env, block)
+	case "docker", "docker-compose":
+		checkDocker(report, env, block)
+	default:
+		report.fail("provider", fmt.Sprintf("unknown KAPETA_SYSTEM_TYPE %q", env.SystemType), "set KAPETA_SYSTEM_TYPE to local, kubernetes, docker or static")
+	}
+
+	return report
+}
+
+func consumerName(consumer blockdef.Resource) string {
+	return fmt.Sprintf("consumer %s (%s)", consumer.Name, consumer.PortType)
+}
+
+func providerPortName(portType string) string {
+	return fmt.Sprintf("provider port %s", portType)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package doctor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const blockDir = "../testdata/block"
+
+func findCheck(t *testing.T, report *Report, name string) Check {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	require.Failf(t, "check not found", "no check named %q in %+v", name, report.Checks)
+	return Check{}
+}
+
+func TestRunLocal(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "local")
+	server := clustertest.Start(t, &clustertest.Fixture{
+		Identity:       clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		InstanceConfig: map[string]any{"greeting": "hello"},
+		ProviderPorts:  map[string]string{"rest": "40001"},
+		Resources: []clustertest.Resource{{
+			Type:     "kapeta/resource-type-mongodb",
+			PortType: "mongodb",
+			Name:     "messages",
+			Info:     map[string]any{"host": "127.0.0.1", "port": 27017},
+		}},
+	})
+
+	report := Run(blockDir)
+	assert.False(t, report.Failed(), "%+v", report.Checks)
+	assert.Equal(t, "local", report.SystemType)
+	assert.Equal(t, "soren_mathiasen/sample-java-chat-messages-service:local", report.BlockRef)
+	assert.Equal(t, "system system-id, instance instance-id", findCheck(t, report, "cluster service identity").Detail)
+	assert.Equal(t, "1 values", findCheck(t, report, "instance configuration").Detail)
+	assert.Equal(t, "40001", findCheck(t, report, "provider port rest").Detail)
+	assert.Equal(t, "127.0.0.1:27017", findCheck(t, report, "consumer messages (mongodb)").Detail)
+
+	assert.Empty(t, server.RequestsTo("PUT", "/instances"), "doctor must not register the instance")
+	identityRequests := server.RequestsTo("GET", "/config/identity")
+	if assert.Len(t, identityRequests, 1) {
+		assert.Equal(t, report.BlockRef, identityRequests[0].Header.Get("X-Kapeta-Block"))
+	}
+}
+
+func TestRunLocalUnconnectedConsumer(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "local")
+	t.Setenv("KAPETA_LOCAL_SERVER_PORT_REST", "50000")
+	clustertest.Start(t, &clustertest.Fixture{
+		Identity: clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+	})
+
+	report := Run(blockDir)
+	assert.True(t, report.Failed())
+	assert.Equal(t, "50000 from KAPETA_LOCAL_SERVER_PORT_REST", findCheck(t, report, "provider port rest").Detail)
+
+	consumer := findCheck(t, report, "consumer messages (mongodb)")
+	assert.Equal(t, StatusFail, consumer.Status)
+	assert.Equal(t, "not found", consumer.Detail)
+	assert.Contains(t, consumer.Hint, "kapeta/resource-type-mongodb operator")
+}
+
+func TestRunLocalClusterServiceNotRunning(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "local")
+	server := clustertest.Start(t, &clustertest.Fixture{})
+	server.Close()
+
+	report := Run(blockDir)
+	assert.True(t, report.Failed())
+
+	identity := findCheck(t, report, "cluster service identity")
+	assert.Equal(t, StatusFail, identity.Status)
+	assert.Contains(t, identity.Detail, "cluster service is not reachable")
+	assert.Contains(t, identity.Hint, "KAPETA_LOCAL_CLUSTER_HOST")
+	assert.Equal(t, StatusSkip, findCheck(t, report, "consumer messages (mongodb)").Status)
+}
+
+func TestRunKubernetes(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "kubernetes")
+	t.Setenv("KAPETA_SYSTEM_ID", "system-id")
+	t.Setenv("KAPETA_INSTANCE_ID", "instance-id")
+	t.Setenv("KAPETA_PROVIDER_PORT_REST", "8080")
+
+	report := Run(blockDir)
+	assert.True(t, report.Failed())
+	assert.Equal(t, StatusPass, findCheck(t, report, "identity").Status)
+	assert.Equal(t, StatusWarn, findCheck(t, report, "instance configuration").Status)
+	assert.Equal(t, "8080 from KAPETA_PROVIDER_PORT_REST", findCheck(t, report, "provider port rest").Detail)
+
+	consumer := findCheck(t, report, "consumer messages (mongodb)")
+	assert.Equal(t, StatusFail, consumer.Status)
+	assert.Equal(t, "missing KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB", consumer.Detail)
+
+	t.Setenv("KAPETA_INSTANCE_CONFIG", `{"greeting":"hello"}`)
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB", `{"host":"mongo","port":"27017"}`)
+
+	report = Run(blockDir)
+	assert.False(t, report.Failed(), "%+v", report.Checks)
+	assert.Equal(t, "mongo:27017 from KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB", findCheck(t, report, "consumer messages (mongodb)").Detail)
+}
+
+func TestRunStatic(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "../testdata/static.yml")
+
+	report := Run(blockDir)
+	assert.False(t, report.Failed(), "%+v", report.Checks)
+	assert.Equal(t, "40001", findCheck(t, report, "provider port rest").Detail)
+
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "../testdata/missing.yml")
+	report = Run(blockDir)
+	assert.Equal(t, StatusFail, findCheck(t, report, "static configuration").Status)
+}
+
+func TestRunDocker(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "docker")
+	t.Setenv("KAPETA_DOCKER_ENV_FILE", "../testdata/kapeta-env.json")
+
+	report := Run(blockDir)
+	assert.False(t, report.Failed(), "%+v", report.Checks)
+	assert.Equal(t, "8080", findCheck(t, report, "provider port rest").Detail)
+	assert.Equal(t, "mongo:27017", findCheck(t, report, "consumer messages (mongodb)").Detail)
+
+	emptyEnvFile := filepath.Join(t.TempDir(), "kapeta-env.json")
+	require.NoError(t, os.WriteFile(emptyEnvFile, []byte("{}"), 0o644))
+	t.Setenv("KAPETA_DOCKER_ENV_FILE", emptyEnvFile)
+	report = Run(blockDir)
+	consumer := findCheck(t, report, "consumer messages (mongodb)")
+	assert.Equal(t, StatusFail, consumer.Status)
+	assert.Contains(t, consumer.Hint, "KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB")
+}
+
+func TestRunInvalid(t *testing.T) {
+	report := Run("../testdata/invalid")
+	assert.True(t, report.Failed())
+	assert.Equal(t, StatusFail, findCheck(t, report, "block definition").Status)
+
+	t.Setenv("KAPETA_SYSTEM_TYPE", "mars")
+	report = Run(blockDir)
+	assert.Equal(t, `unknown KAPETA_SYSTEM_TYPE "mars"`, findCheck(t, report, "provider").Detail)
+}
+
+func TestRunInvalidConfigFile(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "kubernetes")
+	t.Setenv("KAPETA_CONFIG_PATH", filepath.Join(t.TempDir(), "missing.json"))
+
+	report := Run(blockDir)
+	assert.True(t, report.Failed())
+	check := findCheck(t, report, "environment config file")
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Detail, "missing.json")
+	assert.Contains(t, check.Hint, "KAPETA_CONFIG_PATH")
+
+	invalid := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(invalid, []byte("{invalid"), 0o644))
+	t.Setenv("KAPETA_CONFIG_PATH", invalid)
+	t.Setenv("KAPETA_SYSTEM_TYPE", "docker")
+	report = Run(blockDir)
+	assert.Equal(t, StatusFail, findCheck(t, report, "environment config file").Status)
+
+	valid := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"KAPETA_PROVIDER_PORT_REST":"8080"}`), 0o644))
+	t.Setenv("KAPETA_CONFIG_PATH", valid)
+	t.Setenv("KAPETA_SYSTEM_TYPE", "kubernetes")
+	report = Run(blockDir)
+	assert.Equal(t, StatusPass, findCheck(t, report, "environment config file").Status)
+	assert.Equal(t, StatusPass, findCheck(t, report, "provider port rest").Status)
+}
+
+func TestWriteTable(t *testing.T) {
+	report := &Report{SystemType: "kubernetes", BlockRef: "kapeta/block:local"}
+	report.pass("identity", "system a, instance b")
+	report.fail("consumer users (rest)", "missing KAPETA_CONSUMER_SERVICE_USERS_REST", "set KAPETA_CONSUMER_SERVICE_USERS_REST")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.WriteTable(buf))
+	assert.Equal(t, `Block:     kapeta/block:local
+Provider:  kubernetes
+
+STATUS  CHECK                  DETAIL
+PASS    identity               system a, instance b
+FAIL    consumer users (rest)  missing KAPETA_CONSUMER_SERVICE_USERS_REST
+                               -> set KAPETA_CONSUMER_SERVICE_USERS_REST
+
+1 passed, 0 warnings, 1 failed, 0 skipped
+`, buf.String())
+}
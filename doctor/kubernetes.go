@@ -0,0 +1,76 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+const injectedHint = "it is injected by the Kapeta deployment target"
+
+func checkKubernetes(report *Report, env *config.Environment, block *blockdef.Block, lookup *providers.AbstractConfigProvider) {
+	if env.SystemID == "" || env.InstanceID == "" {
+		report.warn("identity", fmt.Sprintf("system %q, instance %q", env.SystemID, env.InstanceID),
+			"set KAPETA_SYSTEM_ID and KAPETA_INSTANCE_ID; "+injectedHint)
+	} else {
+		report.pass("identity", fmt.Sprintf("system %s, instance %s", env.SystemID, env.InstanceID))
+	}
+
+	if value, ok := lookup.LookupEnv(providers.EnvInstanceConfig); !ok {
+		report.warn("instance configuration", "missing "+providers.EnvInstanceConfig,
+			"defaults are used for every configuration value; set "+providers.EnvInstanceConfig+" to a JSON object to configure the instance")
+	} else {
+		configuration := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(value), &configuration); err != nil {
+			report.fail("instance configuration", "invalid JSON in "+providers.EnvInstanceConfig, "set "+providers.EnvInstanceConfig+" to a JSON object")
+		} else {
+			report.pass("instance configuration", fmt.Sprintf("%d values", len(configuration)))
+		}
+	}
+
+	for _, portType := range block.ProviderPortTypes() {
+		envVar := providers.ServerPortEnvVar(portType)
+		if port, ok := lookup.LookupEnv(envVar); ok {
+			report.pass(providerPortName(portType), fmt.Sprintf("%s from %s", port, envVar))
+		} else {
+			report.warn(providerPortName(portType), "missing "+envVar+", defaulting to 80", "set "+envVar+" if the container listens on another port")
+		}
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.PortType == "" {
+			report.fail(consumerName(consumer), "no port type declared", "declare spec.port.type for the consumer in kapeta.yml")
+			continue
+		}
+		if consumer.IsService() {
+			envVar := providers.ServiceEnvVar(consumer.Name, consumer.PortType)
+			if address, ok := lookup.LookupEnv(envVar); ok {
+				report.pass(consumerName(consumer), address)
+			} else {
+				report.fail(consumerName(consumer), "missing "+envVar,
+					fmt.Sprintf("set %s to the address of the service; %s when the consumer is connected in the plan", envVar, injectedHint))
+			}
+			continue
+		}
+
+		envVar := providers.ResourceEnvVar(consumer.Name, consumer.PortType)
+		value, ok := lookup.LookupEnv(envVar)
+		if !ok {
+			report.fail(consumerName(consumer), "missing "+envVar,
+				fmt.Sprintf("set %s to the JSON encoded resource info; %s when the consumer is connected in the plan", envVar, injectedHint))
+			continue
+		}
+		info := &providers.ResourceInfo{}
+		if err := json.Unmarshal([]byte(value), info); err != nil {
+			report.fail(consumerName(consumer), "invalid JSON in "+envVar, "set "+envVar+" to a JSON object with host, port and credentials")
+			continue
+		}
+		report.pass(consumerName(consumer), fmt.Sprintf("%s:%s from %s", info.Host, info.Port, envVar))
+	}
+}
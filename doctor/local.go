@@ -0,0 +1,185 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+const localRequestTimeout = 5 * time.Second
+
+// localProbe talks to the cluster service the same way the local provider does,
+// but without registering the instance
+type localProbe struct {
+	// address is the cluster service URL as configured, baseURL the one requests are sent to
+	address string
+	baseURL string
+	headers map[string]string
+	client  *http.Client
+}
+
+func checkLocal(report *Report, env *config.Environment, block *blockdef.Block, lookup *providers.AbstractConfigProvider) {
+	clusterConfig, err := cfg.LoadClusterConfig()
+	if err != nil {
+		// Continue with the defaults to check whether the cluster service can be reached anyway
+		clusterConfig = cfg.NewClusterConfig()
+		report.fail("cluster config", err.Error(), "fix cluster-service.yml in the Kapeta directory, or remove it to use the default cluster service address")
+	}
+	probe := &localProbe{
+		headers: providers.ClusterServiceHeaders(env.BlockRef, env.SystemID, env.InstanceID),
+	}
+	if err := probe.connect(clusterConfig); err != nil {
+		report.fail("cluster service connection", err.Error(),
+			"check KAPETA_LOCAL_CLUSTER_URL and KAPETA_LOCAL_CLUSTER_CA_FILE, or cluster.url, cluster.caFile and cluster.tokenFile in cluster-service.yml")
+		probe.skipRemaining(report, block)
+		return
+	}
+
+	identity := &providers.Identity{}
+	if !probe.getJSON(report, "cluster service identity", identity, "/config/identity",
+		fmt.Sprintf("add the block %s to a plan in Kapeta, or set KAPETA_BLOCK_REF to the block reference used in the plan", env.BlockRef)) {
+		probe.skipRemaining(report, block)
+		return
+	}
+	report.pass("cluster service identity", fmt.Sprintf("system %s, instance %s", identity.SystemID, identity.InstanceID))
+	probe.headers[providers.HEADER_KAPETA_SYSTEM] = identity.SystemID
+	probe.headers[providers.HEADER_KAPETA_INSTANCE] = identity.InstanceID
+
+	configuration := map[string]interface{}{}
+	if probe.getJSON(report, "instance configuration", &configuration, "/config/instance",
+		"start the plan in Kapeta so the instance configuration is available") {
+		report.pass("instance configuration", fmt.Sprintf("%d values", len(configuration)))
+	}
+
+	for _, portType := range block.ProviderPortTypes() {
+		envVar := "KAPETA_LOCAL_SERVER_PORT_" + strings.ToUpper(portType)
+		if port, ok := lookup.LookupEnv(envVar); ok {
+			report.pass(providerPortName(portType), fmt.Sprintf("%s from %s", port, envVar))
+			continue
+		}
+		if port, ok := probe.get(report, providerPortName(portType), "/config/provides/"+encode(portType),
+			fmt.Sprintf("start the plan in Kapeta so a %s port is assigned, or set %s", portType, envVar)); ok {
+			report.pass(providerPortName(portType), string(port))
+		}
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.PortType == "" {
+			report.fail(consumerName(consumer), "no port type declared", "declare spec.port.type for the consumer in kapeta.yml")
+			continue
+		}
+		if consumer.IsService() {
+			if address, ok := probe.get(report, consumerName(consumer),
+				fmt.Sprintf("/config/consumes/%s/%s", encode(consumer.Name), encode(consumer.PortType)),
+				fmt.Sprintf("connect the %s consumer to a provider in the plan", consumer.Name)); ok {
+				report.pass(consumerName(consumer), string(address))
+			}
+			continue
+		}
+
+		info := &providers.ResourceInfo{}
+		if probe.getJSON(report, consumerName(consumer), info,
+			fmt.Sprintf("/config/consumes/resource/%s/%s/%s", encode(consumer.ResourceType()), encode(consumer.PortType), encode(consumer.Name)),
+			fmt.Sprintf("add a %s operator to the plan and connect the %s consumer to it", consumer.ResourceType(), consumer.Name)) {
+			report.pass(consumerName(consumer), fmt.Sprintf("%s:%s", info.Host, info.Port))
+		}
+	}
+}
+
+// connect sets up the client the same way the local provider does
+func (p *localProbe) connect(clusterConfig *cfg.ClusterConfig) error {
+	connection, err := clusterConfig.GetClusterServiceConnection()
+	if err != nil {
+		return err
+	}
+	baseURL, transport, err := providers.ClusterServiceTransport(connection)
+	if err != nil {
+		return err
+	}
+	p.address = connection.URL
+	p.baseURL = baseURL
+	p.client = &http.Client{Transport: transport, Timeout: localRequestTimeout}
+	if connection.Token != "" {
+		p.headers["Authorization"] = "Bearer " + connection.Token
+	}
+	return nil
+}
+
+// getJSON requests the path and decodes the JSON response into value.
+// A failed check is added to the report if the request or decoding fails.
+func (p *localProbe) getJSON(report *Report, name string, value any, path, notFoundHint string) bool {
+	body, ok := p.get(report, name, path, notFoundHint)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(body, value); err != nil {
+		report.fail(name, fmt.Sprintf("invalid response: %v", err), "make sure the cluster service is up to date")
+		return false
+	}
+	return true
+}
+
+// get requests the path from the cluster service. A failed check is added to the report if the request fails.
+func (p *localProbe) get(report *Report, name, path, notFoundHint string) ([]byte, bool) {
+	requestURL := p.baseURL + path
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		report.fail(name, err.Error(), "")
+		return nil, false
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		report.fail(name, fmt.Sprintf("cluster service is not reachable at %s", p.address),
+			"start Kapeta Desktop or the local cluster service, or point KAPETA_LOCAL_CLUSTER_URL, or KAPETA_LOCAL_CLUSTER_HOST and KAPETA_LOCAL_CLUSTER_PORT, at it")
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		report.fail(name, "not found", notFoundHint)
+		return nil, false
+	}
+	if resp.StatusCode > 399 {
+		report.fail(name, fmt.Sprintf("cluster service responded with status %d for %s", resp.StatusCode, path), "check the cluster service logs")
+		return nil, false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		report.fail(name, fmt.Sprintf("failed to read response: %v", err), "")
+		return nil, false
+	}
+	return body, true
+}
+
+func (p *localProbe) skipRemaining(report *Report, block *blockdef.Block) {
+	const detail = "identity could not be resolved"
+	report.skip("instance configuration", detail)
+	for _, portType := range block.ProviderPortTypes() {
+		report.skip(providerPortName(portType), detail)
+	}
+	for _, consumer := range block.Consumers {
+		report.skip(consumerName(consumer), detail)
+	}
+}
+
+// encode matches how the local provider encodes path segments
+func encode(text string) string {
+	return url.QueryEscape(strings.ToLower(text))
+}
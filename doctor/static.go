@@ -0,0 +1,72 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package doctor
+
+import (
+	"fmt"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+func checkStatic(report *Report, env *config.Environment, block *blockdef.Block) {
+	fixture, err := providers.LoadStaticFixture(env.StaticConfigFile)
+	if err != nil {
+		report.fail("static configuration", err.Error(),
+			fmt.Sprintf("create %s or point KAPETA_STATIC_CONFIG_FILE at the fixture", env.StaticConfigFile))
+		return
+	}
+	report.pass("static configuration", env.StaticConfigFile)
+
+	provider := providers.NewStaticConfigProvider(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, fixture)
+	checkProvider(report, provider, block, "serverPorts", func(section, envVar string) string {
+		return fmt.Sprintf("add it to %s in %s", section, env.StaticConfigFile)
+	})
+}
+
+func checkDocker(report *Report, env *config.Environment, block *blockdef.Block) {
+	dockerEnv, err := config.LoadDockerEnv(env.DockerEnvFile)
+	if err != nil {
+		report.fail("docker env file", err.Error(),
+			fmt.Sprintf("mount a valid kapeta-env.json at %s or point KAPETA_DOCKER_ENV_FILE at it", env.DockerEnvFile))
+		return
+	}
+	report.pass("docker env file", env.DockerEnvFile)
+
+	provider := providers.NewDockerComposeConfigProvider(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, dockerEnv)
+	checkProvider(report, provider, block, "ports", func(section, envVar string) string {
+		return fmt.Sprintf("add it to %s in %s or set %s", section, env.DockerEnvFile, envVar)
+	})
+}
+
+// checkProvider resolves every port and consumer of the block through a provider that does not need any network access.
+// portsSection is the section of the configuration file holding the server ports, and hint returns how to configure
+// a missing value, given the section of the configuration file and the environment variable.
+func checkProvider(report *Report, provider providers.ConfigProvider, block *blockdef.Block, portsSection string, hint func(section, envVar string) string) {
+	for _, portType := range block.ProviderPortTypes() {
+		if port, err := provider.GetServerPort(portType); err != nil {
+			report.fail(providerPortName(portType), err.Error(), hint(portsSection, providers.ServerPortEnvVar(portType)))
+		} else {
+			report.pass(providerPortName(portType), port)
+		}
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.IsService() {
+			if address, err := provider.GetServiceAddress(consumer.Name, consumer.PortType); err != nil {
+				report.fail(consumerName(consumer), err.Error(), hint("services", providers.ServiceEnvVar(consumer.Name, consumer.PortType)))
+			} else {
+				report.pass(consumerName(consumer), address)
+			}
+			continue
+		}
+
+		if info, err := provider.GetResourceInfo(consumer.ResourceType(), consumer.PortType, consumer.Name); err != nil {
+			report.fail(consumerName(consumer), err.Error(), hint("resources", providers.ResourceEnvVar(consumer.Name, consumer.PortType)))
+		} else {
+			report.pass(consumerName(consumer), fmt.Sprintf("%s:%s", info.Host, info.Port))
+		}
+	}
+}
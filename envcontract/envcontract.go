@@ -0,0 +1,124 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// Variable is an environment variable read by the Kubernetes provider
+type Variable struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Required    bool            `json:"required"`
+	Default     string          `json:"default,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	// Value is written to the env stubs. ForBlock sets it to the default unless a fixed value is needed.
+	Value string `json:"value,omitempty"`
+	// Pattern is set for a family of variables named after a value only known when deploying, such as an instance ID.
+	// Name then contains a placeholder, e.g. KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>, and the variable is documented
+	// but not written to env stubs.
+	Pattern bool `json:"pattern,omitempty"`
+}
+
+// IsJSON returns true if the value of the variable is JSON encoded rather than a plain string
+func (v Variable) IsJSON() bool {
+	return len(v.Schema) > 0 && !bytes.Equal(v.Schema, stringSchema) && !bytes.Equal(v.Schema, portSchema)
+}
+
+// ForBlock returns every environment variable the block reads when running on Kubernetes, in a stable order.
+// Operators are looked up by instance ID, which is not known from the block definition, so KAPETA_INSTANCE_OPERATOR_*
+// is included as a pattern.
+func ForBlock(block *blockdef.Block) []Variable {
+	c := &contract{seen: map[string]bool{}}
+
+	c.add(Variable{Name: "KAPETA_SYSTEM_TYPE", Description: "Selects the Kubernetes provider", Required: true, Value: "kubernetes", Schema: stringSchema})
+	c.add(Variable{Name: "KAPETA_SYSTEM_ID", Description: "Reference of the plan the block is running in", Required: true, Schema: stringSchema})
+	c.add(Variable{Name: "KAPETA_INSTANCE_ID", Description: "ID of the block instance in the plan", Required: true, Schema: stringSchema})
+	c.add(Variable{Name: "KAPETA_BLOCK_REF", Description: "Reference of the block", Default: block.Name + ":local", Schema: stringSchema})
+	c.add(Variable{Name: providers.EnvProviderHost, Description: "Host to listen on", Default: "0.0.0.0", Schema: stringSchema})
+	c.add(Variable{Name: providers.EnvInstanceConfig, Description: "Configuration of the instance. Defaults are used if not set.", Schema: instanceConfigSchema})
+	c.add(Variable{Name: providers.EnvBlockHosts, Description: "Hosts of the other block instances in the plan", Schema: blockHostsSchema})
+
+	for _, portType := range block.ProviderPortTypes() {
+		c.add(Variable{
+			Name:        providers.ServerPortEnvVar(portType),
+			Description: fmt.Sprintf("Port to listen on for %s traffic", portType),
+			Default:     "80",
+			Schema:      portSchema,
+		})
+	}
+
+	for _, provider := range block.Providers {
+		c.add(Variable{
+			Name:        providers.InstancesForProviderEnvVar(provider.Name),
+			Description: fmt.Sprintf("Block instances consuming the %s provider. No instances if not set.", provider.Name),
+			Schema:      blockInstancesSchema,
+		})
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.IsService() {
+			c.add(Variable{
+				Name:        providers.ServiceEnvVar(consumer.Name, consumer.PortType),
+				Description: fmt.Sprintf("Address of the %s service consumed as %s", consumer.PortType, consumer.Name),
+				Required:    true,
+				Schema:      stringSchema,
+			})
+			c.add(Variable{
+				Name:        providers.ServiceEndpointsEnvVar(consumer.Name, consumer.PortType),
+				Description: fmt.Sprintf("Every endpoint of the %s service consumed as %s. Falls back to the service address if not set.", consumer.PortType, consumer.Name),
+				Schema:      endpointsSchema,
+			})
+			c.add(Variable{
+				Name:        providers.InstanceForConsumerEnvVar(consumer.Name),
+				Description: fmt.Sprintf("Block instance providing the %s consumer", consumer.Name),
+				Schema:      blockInstanceSchema,
+			})
+			continue
+		}
+
+		c.add(Variable{
+			Name:        providers.ResourceEnvVar(consumer.Name, consumer.PortType),
+			Description: fmt.Sprintf("Connection info of the %s resource consumed as %s", consumer.ResourceType(), consumer.Name),
+			Required:    true,
+			Schema:      resourceInfoSchema,
+		})
+	}
+
+	if len(block.Consumers) > 0 {
+		c.add(Variable{
+			Name:        providers.InstanceOperatorEnvVar(instanceIDPlaceholder),
+			Description: "Operator of a block instance the block consumes from, one variable per instance ID",
+			Schema:      instanceOperatorSchema,
+			Pattern:     true,
+		})
+	}
+
+	return c.variables
+}
+
+// instanceIDPlaceholder stands for the instance ID in the names of pattern variables
+const instanceIDPlaceholder = "<INSTANCE ID>"
+
+type contract struct {
+	variables []Variable
+	seen      map[string]bool
+}
+
+func (c *contract) add(variable Variable) {
+	if c.seen[variable.Name] {
+		return
+	}
+	c.seen[variable.Name] = true
+	if variable.Value == "" {
+		variable.Value = variable.Default
+	}
+	c.variables = append(c.variables, variable)
+}
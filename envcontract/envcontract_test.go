@@ -0,0 +1,88 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBlock() *blockdef.Block {
+	return &blockdef.Block{
+		Name: "kapeta/messages",
+		Providers: []blockdef.Resource{
+			{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "messages", PortType: "rest"},
+			{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "admin", PortType: "rest"},
+		},
+		Consumers: []blockdef.Resource{
+			{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "users", PortType: "rest"},
+			{Kind: "kapeta://kapeta/resource-type-mongodb:0.0.1", Name: "messages-db", PortType: "mongodb"},
+		},
+	}
+}
+
+func names(variables []Variable) []string {
+	result := make([]string, 0, len(variables))
+	for _, variable := range variables {
+		result = append(result, variable.Name)
+	}
+	return result
+}
+
+func TestForBlock(t *testing.T) {
+	variables := ForBlock(testBlock())
+
+	assert.Equal(t, []string{
+		"KAPETA_SYSTEM_TYPE",
+		"KAPETA_SYSTEM_ID",
+		"KAPETA_INSTANCE_ID",
+		"KAPETA_BLOCK_REF",
+		"KAPETA_PROVIDER_HOST",
+		"KAPETA_INSTANCE_CONFIG",
+		"KAPETA_BLOCK_HOSTS",
+		"KAPETA_PROVIDER_PORT_REST",
+		"KAPETA_INSTANCES_FOR_PROVIDER_MESSAGES",
+		"KAPETA_INSTANCES_FOR_PROVIDER_ADMIN",
+		"KAPETA_CONSUMER_SERVICE_USERS_REST",
+		"KAPETA_CONSUMER_ENDPOINTS_USERS_REST",
+		"KAPETA_INSTANCE_FOR_CONSUMER_USERS",
+		"KAPETA_CONSUMER_RESOURCE_MESSAGES_DB_MONGODB",
+		"KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>",
+	}, names(variables))
+
+	for _, variable := range variables {
+		assert.NotEmpty(t, variable.Description, variable.Name)
+		assert.True(t, json.Valid(variable.Schema), variable.Name)
+	}
+
+	assert.Equal(t, "kubernetes", variables[0].Value)
+	assert.Equal(t, "kapeta/messages:local", variables[3].Default)
+	assert.Equal(t, "80", variables[7].Value)
+	assert.True(t, variables[10].Required)
+	assert.False(t, variables[10].IsJSON())
+	assert.True(t, variables[13].Required)
+	assert.True(t, variables[13].IsJSON())
+	assert.False(t, variables[13].Pattern)
+	assert.True(t, variables[14].Pattern)
+	assert.False(t, variables[14].Required)
+	assert.True(t, variables[14].IsJSON())
+}
+
+func TestForBlockWithoutConsumers(t *testing.T) {
+	block := testBlock()
+	block.Consumers = nil
+
+	assert.NotContains(t, names(ForBlock(block)), "KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>")
+}
+
+func TestForBlockDeduplicates(t *testing.T) {
+	block := testBlock()
+	block.Consumers = append(block.Consumers, block.Consumers[0])
+
+	variables := ForBlock(block)
+	assert.Len(t, variables, 15)
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/secrets"
+)
+
+// configurationProvider is implemented by the providers that can return the whole instance configuration
+type configurationProvider interface {
+	GetConfiguration() map[string]interface{}
+}
+
+// ExportEnvironment resolves everything the block declares through the provider and returns it as the environment
+// variables the KubernetesConfigProvider reads. Lookups that are not configured are left out, so they are reported
+// as not found by the Kubernetes provider as well. The server host is not exported, since a host bound locally
+// rarely makes sense in a container. If the provider resolves secret references, the environment is exported from
+// the provider below it, so the references are exported rather than the secrets they point at.
+func ExportEnvironment(provider providers.ConfigProvider, block *blockdef.Block) (map[string]string, error) {
+	if secretsProvider, ok := providers.As[*secrets.Provider](provider); ok {
+		provider = secretsProvider.Unwrap()
+	}
+	e := &exporter{
+		provider: provider,
+		env: map[string]string{
+			"KAPETA_SYSTEM_TYPE": "kubernetes",
+			"KAPETA_SYSTEM_ID":   provider.GetSystemId(),
+			"KAPETA_INSTANCE_ID": provider.GetInstanceId(),
+			"KAPETA_BLOCK_REF":   provider.GetBlockReference(),
+		},
+		hosts: map[string]string{},
+	}
+
+	if configProvider, ok := providers.As[configurationProvider](provider); ok {
+		if err := e.setJSON(providers.EnvInstanceConfig, configProvider.GetConfiguration()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, portType := range block.ProviderPortTypes() {
+		port, err := provider.GetServerPort(portType)
+		if err := e.set(providers.ServerPortEnvVar(portType), port, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range block.Providers {
+		instances, err := provider.GetInstancesForProvider(p.Name)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to export instances for provider %s: %w", p.Name, err)
+		}
+		if len(instances) == 0 {
+			continue
+		}
+		if err := e.setJSON(providers.InstancesForProviderEnvVar(p.Name), instances); err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			if err := e.addInstance(instance.InstanceId); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, consumer := range block.Consumers {
+		if err := e.exportConsumer(consumer); err != nil {
+			return nil, fmt.Errorf("failed to export consumer %s: %w", consumer.Name, err)
+		}
+	}
+
+	if len(e.hosts) > 0 {
+		if err := e.setJSON(providers.EnvBlockHosts, e.hosts); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.env, nil
+}
+
+type exporter struct {
+	provider providers.ConfigProvider
+	env      map[string]string
+	hosts    map[string]string
+}
+
+func (e *exporter) exportConsumer(consumer blockdef.Resource) error {
+	if consumer.IsService() {
+		if err := e.exportService(consumer); err != nil {
+			return err
+		}
+	} else {
+		info, err := e.provider.GetResourceInfo(consumer.ResourceType(), consumer.PortType, consumer.Name)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		if info != nil {
+			if err := e.setJSON(providers.ResourceEnvVar(consumer.Name, consumer.PortType), info); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Services are connected to a block instance and resources usually to an operator, which both may have an operator
+	instance, err := e.provider.GetInstanceForConsumer(consumer.Name)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := e.setJSON(providers.InstanceForConsumerEnvVar(consumer.Name), instance); err != nil {
+		return err
+	}
+
+	operator, err := e.provider.GetInstanceOperator(instance.InstanceId)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if operator != nil {
+		if err := e.setJSON(providers.InstanceOperatorEnvVar(instance.InstanceId), operator); err != nil {
+			return err
+		}
+	}
+
+	return e.addInstance(instance.InstanceId)
+}
+
+func (e *exporter) exportService(consumer blockdef.Resource) error {
+	address, err := e.provider.GetServiceAddress(consumer.Name, consumer.PortType)
+	if err := e.set(providers.ServiceEnvVar(consumer.Name, consumer.PortType), address, err); err != nil {
+		return err
+	}
+
+	endpoints, err := e.provider.GetServiceEndpoints(consumer.Name, consumer.PortType)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if len(endpoints) > 0 {
+		return e.setJSON(providers.ServiceEndpointsEnvVar(consumer.Name, consumer.PortType), endpoints)
+	}
+	return nil
+}
+
+// addInstance adds the host of a connected block instance to KAPETA_BLOCK_HOSTS
+func (e *exporter) addInstance(instanceID string) error {
+	if _, exists := e.hosts[instanceID]; exists {
+		return nil
+	}
+	host, err := e.provider.GetInstanceHost(instanceID)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export host of instance %s: %w", instanceID, err)
+	}
+	e.hosts[instanceID] = host
+	return nil
+}
+
+func (e *exporter) set(name, value string, err error) error {
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %w", name, err)
+	}
+	e.env[name] = value
+	return nil
+}
+
+func (e *exporter) setJSON(name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	e.env[name] = string(data)
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, providers.ErrNotFound)
+}
+
+// WithValues returns the variables of the contract with the given values, followed by any values that are not
+// part of the contract in sorted order. Values named after a pattern of the contract are described by the pattern.
+// Variables of the contract without a value are left out.
+func WithValues(variables []Variable, values map[string]string) []Variable {
+	result := make([]Variable, 0, len(values))
+	known := map[string]bool{}
+	patterns := make([]Variable, 0)
+	for _, variable := range variables {
+		if variable.Pattern {
+			patterns = append(patterns, variable)
+			continue
+		}
+		known[variable.Name] = true
+		if value, exists := values[variable.Name]; exists {
+			variable.Value = value
+			result = append(result, variable)
+		}
+	}
+
+	extra := make([]string, 0)
+	for name := range values {
+		if !known[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		variable := Variable{Name: name, Description: "Exported value"}
+		for _, pattern := range patterns {
+			if prefix, _, _ := strings.Cut(pattern.Name, "<"); strings.HasPrefix(name, prefix) {
+				variable = pattern
+				variable.Name = name
+				variable.Pattern = false
+				break
+			}
+		}
+		variable.Value = values[name]
+		result = append(result, variable)
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/providertest"
+	"github.com/kapetacom/sdk-go-config/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportBlock() *blockdef.Block {
+	return &blockdef.Block{
+		Name: "kapeta/messages",
+		Providers: []blockdef.Resource{
+			{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "messages-api", PortType: "rest"},
+		},
+		Consumers: []blockdef.Resource{
+			{Kind: "kapeta://kapeta/resource-type-rest-api:0.0.3", Name: "users", PortType: "rest"},
+			{Kind: "kapeta://kapeta/resource-type-mongodb:0.0.1", Name: "messages", PortType: "mongodb"},
+		},
+	}
+}
+
+// TestExportEnvironmentRoundTrip checks that a Kubernetes provider configured with the exported environment
+// resolves the same values as the provider the environment was exported from
+func TestExportEnvironmentRoundTrip(t *testing.T) {
+	fixture := providertest.Fixture()
+	fixture.InstanceHosts["gateway-instance"] = "10.0.0.3"
+	fixture.Consumers["messages"] = &providers.BlockInstanceDetails{
+		InstanceId: "mongo-operator",
+		Connections: []*model.Connection{{
+			Consumer: model.Endpoint{BlockId: providertest.InstanceID, ResourceName: "messages"},
+			Provider: model.Endpoint{BlockId: "mongo-operator", ResourceName: "mongodb"},
+		}},
+	}
+	source := providers.NewStaticConfigProvider("kapeta/messages:1.0.0", "", "", map[string]interface{}{}, fixture)
+
+	env, err := ExportEnvironment(source, exportBlock())
+	require.NoError(t, err)
+
+	assert.Equal(t, "kubernetes", env["KAPETA_SYSTEM_TYPE"])
+	assert.Equal(t, providertest.SystemID, env["KAPETA_SYSTEM_ID"])
+	assert.Equal(t, providertest.InstanceID, env["KAPETA_INSTANCE_ID"])
+	assert.Equal(t, "kapeta/messages:1.0.0", env["KAPETA_BLOCK_REF"])
+	assert.JSONEq(t, `{"users-instance":"10.0.0.2","gateway-instance":"10.0.0.3"}`, env["KAPETA_BLOCK_HOSTS"])
+	assert.NotContains(t, env, "KAPETA_PROVIDER_HOST")
+	assert.Contains(t, env, "KAPETA_INSTANCE_FOR_CONSUMER_MESSAGES", "resource consumers export the instance they are connected to")
+	assert.Contains(t, env, "KAPETA_INSTANCE_OPERATOR_MONGO_OPERATOR", "and its operator")
+	assert.NotContains(t, env, "KAPETA_INSTANCE_OPERATOR_USERS_INSTANCE", "instances without an operator are left out")
+
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	target := providers.NewKubernetesConfigProvider(env["KAPETA_BLOCK_REF"], env["KAPETA_SYSTEM_ID"], env["KAPETA_INSTANCE_ID"], map[string]interface{}{})
+
+	assert.Equal(t, source.Get("greeting"), target.Get("greeting"))
+
+	for _, portType := range []string{"rest"} {
+		expected, _ := source.GetServerPort(portType)
+		actual, err := target.GetServerPort(portType)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	expectedAddress, _ := source.GetServiceAddress("users", "rest")
+	address, err := target.GetServiceAddress("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedAddress, address)
+
+	expectedEndpoints, _ := source.GetServiceEndpoints("users", "rest")
+	endpoints, err := target.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedEndpoints, endpoints)
+
+	expectedInfo, _ := source.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	info, err := target.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInfo, info)
+
+	expectedInstance, _ := source.GetInstanceForConsumer("users")
+	instance, err := target.GetInstanceForConsumer("users")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInstance, instance)
+
+	expectedInstance, _ = source.GetInstanceForConsumer("messages")
+	instance, err = target.GetInstanceForConsumer("messages")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInstance, instance)
+
+	expectedOperator, _ := source.GetInstanceOperator("mongo-operator")
+	operator, err := target.GetInstanceOperator("mongo-operator")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOperator, operator)
+	assert.Equal(t, 27017, operator.Ports["mongodb"].Port)
+
+	expectedInstances, _ := source.GetInstancesForProvider("messages-api")
+	instances, err := target.GetInstancesForProvider("messages-api")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInstances, instances)
+
+	for _, instanceID := range []string{"users-instance", "gateway-instance"} {
+		expectedHost, _ := source.GetInstanceHost(instanceID)
+		host, err := target.GetInstanceHost(instanceID)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedHost, host)
+	}
+}
+
+func TestExportEnvironmentLocal(t *testing.T) {
+	clustertest.Start(t, &clustertest.Fixture{
+		Identity:       clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		InstanceConfig: map[string]any{"greeting": "hello"},
+		ProviderPorts:  map[string]string{"rest": "40001"},
+		Services:       []clustertest.Service{{Name: "users", PortType: "rest", Address: "http://127.0.0.1:40002/"}},
+	})
+	provider := providers.NewLocalConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{})
+
+	env, err := ExportEnvironment(provider, exportBlock())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"KAPETA_SYSTEM_TYPE":                   "kubernetes",
+		"KAPETA_SYSTEM_ID":                     "system-id",
+		"KAPETA_INSTANCE_ID":                   "instance-id",
+		"KAPETA_BLOCK_REF":                     "kapeta/messages:local",
+		"KAPETA_INSTANCE_CONFIG":               `{"greeting":"hello"}`,
+		"KAPETA_PROVIDER_PORT_REST":            "40001",
+		"KAPETA_CONSUMER_SERVICE_USERS_REST":   "http://127.0.0.1:40002/",
+		"KAPETA_CONSUMER_ENDPOINTS_USERS_REST": `[{"address":"http://127.0.0.1:40002/"}]`,
+	}, env, "lookups the cluster service does not know are left out")
+}
+
+// revealResolver stands in for a secret backend and replaces every reference with a recognizable value
+type revealResolver struct{}
+
+func (revealResolver) Resolve(_ context.Context, value string) (string, error) {
+	if secrets.IsRef(value) {
+		return "resolved-" + strings.TrimPrefix(value, secrets.RefPrefix), nil
+	}
+	return value, nil
+}
+
+func TestExportEnvironmentSecrets(t *testing.T) {
+	fixture := providertest.Fixture()
+	fixture.Configuration["token"] = "secret://api/token"
+	fixture.Resources["messages"]["mongodb"].Credentials = map[string]string{"password": "secret://mongo/password"}
+	fixture.Consumers["messages"] = &providers.BlockInstanceDetails{InstanceId: "mongo-operator"}
+	fixture.Operators["mongo-operator"].Credentials = map[string]interface{}{"password": "secret://mongo/password"}
+	source := providers.NewStaticConfigProvider("kapeta/messages:1.0.0", "", "", map[string]interface{}{}, fixture)
+	provider := providers.Chain(source, secrets.Middleware(revealResolver{}))
+	require.Equal(t, "resolved-api/token", provider.Get("token"))
+
+	env, err := ExportEnvironment(provider, exportBlock())
+	require.NoError(t, err)
+
+	for name, value := range env {
+		assert.NotContains(t, value, "resolved-", "%s contains a resolved secret", name)
+	}
+	assert.Contains(t, env["KAPETA_INSTANCE_CONFIG"], "secret://api/token")
+	assert.Contains(t, env["KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB"], "secret://mongo/password")
+	assert.Contains(t, env["KAPETA_INSTANCE_OPERATOR_MONGO_OPERATOR"], "secret://mongo/password")
+}
+
+func TestWithValues(t *testing.T) {
+	variables := WithValues(ForBlock(exportBlock()), map[string]string{
+		"KAPETA_PROVIDER_PORT_REST":          "40001",
+		"KAPETA_INSTANCE_OPERATOR_USERS":     `{"hostname":"users"}`,
+		"KAPETA_CONSUMER_SERVICE_USERS_REST": "http://users/",
+	})
+
+	assert.Equal(t, []string{"KAPETA_PROVIDER_PORT_REST", "KAPETA_CONSUMER_SERVICE_USERS_REST", "KAPETA_INSTANCE_OPERATOR_USERS"}, names(variables))
+	assert.Equal(t, "40001", variables[0].Value)
+	assert.Equal(t, "Port to listen on for rest traffic", variables[0].Description)
+	assert.Equal(t, `{"hostname":"users"}`, variables[2].Value)
+	assert.Equal(t, "Operator of a block instance the block consumes from, one variable per instance ID", variables[2].Description, "values named after a pattern are described by it")
+	assert.False(t, variables[2].Pattern)
+	assert.True(t, variables[2].IsJSON())
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats the variables can be written in
+const (
+	FormatKubernetes = "k8s"
+	FormatDotenv     = "dotenv"
+	FormatMarkdown   = "markdown"
+	FormatJSON       = "json"
+)
+
+// Formats lists every supported format
+var Formats = []string{FormatKubernetes, FormatDotenv, FormatMarkdown, FormatJSON}
+
+// Write writes the variables in the given format
+func Write(w io.Writer, format string, variables []Variable) error {
+	switch format {
+	case FormatKubernetes:
+		return WriteKubernetes(w, variables)
+	case FormatDotenv:
+		return WriteDotenv(w, variables)
+	case FormatMarkdown:
+		return WriteMarkdown(w, variables)
+	case FormatJSON:
+		return WriteJSON(w, variables)
+	default:
+		return fmt.Errorf("unknown format: %s, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteKubernetes writes the variables as the env section of a Kubernetes container
+func WriteKubernetes(w io.Writer, variables []Variable) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "env:")
+	for _, variable := range stubs(variables) {
+		fmt.Fprintf(bw, "  # %s\n", comment(variable))
+		fmt.Fprintf(bw, "  - name: %s\n", variable.Name)
+		fmt.Fprintf(bw, "    value: %s\n", quote(variable.Value))
+	}
+	return bw.Flush()
+}
+
+// WriteDotenv writes the variables as a dotenv file.
+// Values are written unquoted, so the file can be used with docker run --env-file.
+func WriteDotenv(w io.Writer, variables []Variable) error {
+	bw := bufio.NewWriter(w)
+	for i, variable := range stubs(variables) {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "# %s\n", comment(variable))
+		fmt.Fprintf(bw, "%s=%s\n", variable.Name, variable.Value)
+	}
+	return bw.Flush()
+}
+
+// WriteMarkdown writes the variables as a Markdown table followed by the JSON schema of every JSON encoded variable
+func WriteMarkdown(w io.Writer, variables []Variable) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "| Variable | Required | Default | Description |")
+	fmt.Fprintln(bw, "|----------|----------|---------|-------------|")
+	for _, variable := range variables {
+		required := "no"
+		if variable.Required {
+			required = "yes"
+		}
+		defaultValue := ""
+		if variable.Default != "" {
+			defaultValue = "`" + variable.Default + "`"
+		}
+		fmt.Fprintf(bw, "| `%s` | %s | %s | %s |\n", variable.Name, required, defaultValue, strings.ReplaceAll(variable.Description, "|", `\|`))
+	}
+
+	for _, variable := range variables {
+		if !variable.IsJSON() {
+			continue
+		}
+		schema, err := json.MarshalIndent(variable.Schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("invalid schema for %s: %w", variable.Name, err)
+		}
+		fmt.Fprintf(bw, "\n### %s\n\n```json\n%s\n```\n", variable.Name, schema)
+	}
+	return bw.Flush()
+}
+
+// WriteJSON writes the variables as a JSON object of names to values
+func WriteJSON(w io.Writer, variables []Variable) error {
+	values := make(map[string]string, len(variables))
+	for _, variable := range stubs(variables) {
+		values[variable.Name] = variable.Value
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+// stubs returns the variables that can be written to env stubs, leaving out patterns
+func stubs(variables []Variable) []Variable {
+	result := make([]Variable, 0, len(variables))
+	for _, variable := range variables {
+		if !variable.Pattern {
+			result = append(result, variable)
+		}
+	}
+	return result
+}
+
+func comment(variable Variable) string {
+	if variable.Required {
+		return variable.Description + " (required)"
+	}
+	return variable.Description
+}
+
+// quote writes the value as a double quoted YAML string, which uses the same escaping as JSON
+func quote(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
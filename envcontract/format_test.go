@@ -0,0 +1,110 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testVariables() []Variable {
+	return []Variable{
+		{Name: "KAPETA_PROVIDER_PORT_REST", Description: "Port to listen on for rest traffic", Default: "80", Value: "80", Schema: portSchema},
+		{Name: "KAPETA_CONSUMER_SERVICE_USERS_REST", Description: "Address of the users service", Required: true, Schema: stringSchema},
+		{Name: "KAPETA_CONSUMER_RESOURCE_DB_MONGODB", Description: "Connection info | db", Required: true, Value: `{"host":"mongo","port":27017}`, Schema: resourceInfoSchema},
+	}
+}
+
+func TestWriteKubernetes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatKubernetes, testVariables()))
+
+	container := struct {
+		Env []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+		} `yaml:"env"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &container), buf.String())
+	require.Len(t, container.Env, 3)
+	assert.Equal(t, "KAPETA_PROVIDER_PORT_REST", container.Env[0].Name)
+	assert.Equal(t, "80", container.Env[0].Value)
+	assert.Equal(t, "", container.Env[1].Value)
+	assert.Equal(t, `{"host":"mongo","port":27017}`, container.Env[2].Value)
+	assert.Contains(t, buf.String(), "  # Address of the users service (required)\n")
+}
+
+func TestWriteDotenv(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatDotenv, testVariables()))
+	assert.Equal(t, `# Port to listen on for rest traffic
+KAPETA_PROVIDER_PORT_REST=80
+
+# Address of the users service (required)
+KAPETA_CONSUMER_SERVICE_USERS_REST=
+
+# Connection info | db (required)
+KAPETA_CONSUMER_RESOURCE_DB_MONGODB={"host":"mongo","port":27017}
+`, buf.String())
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatMarkdown, testVariables()))
+
+	output := buf.String()
+	assert.Contains(t, output, "| `KAPETA_PROVIDER_PORT_REST` | no | `80` | Port to listen on for rest traffic |\n")
+	assert.Contains(t, output, "| `KAPETA_CONSUMER_RESOURCE_DB_MONGODB` | yes |  | Connection info \\| db |\n")
+	assert.Contains(t, output, "### KAPETA_CONSUMER_RESOURCE_DB_MONGODB\n\n```json\n{\n  \"type\": \"object\",")
+	assert.NotContains(t, output, "### KAPETA_CONSUMER_SERVICE_USERS_REST", "plain string variables have no schema section")
+
+	schema := map[string]any{}
+	start := bytes.Index(buf.Bytes(), []byte("```json\n")) + len("```json\n")
+	end := bytes.LastIndex(buf.Bytes(), []byte("\n```"))
+	require.NoError(t, json.Unmarshal(buf.Bytes()[start:end], &schema))
+	assert.Equal(t, []any{"host", "port"}, schema["required"])
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatJSON, testVariables()))
+
+	values := map[string]string{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &values))
+	assert.Equal(t, map[string]string{
+		"KAPETA_PROVIDER_PORT_REST":           "80",
+		"KAPETA_CONSUMER_SERVICE_USERS_REST":  "",
+		"KAPETA_CONSUMER_RESOURCE_DB_MONGODB": `{"host":"mongo","port":27017}`,
+	}, values)
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	err := Write(&bytes.Buffer{}, "xml", testVariables())
+	assert.EqualError(t, err, "unknown format: xml, must be one of k8s, dotenv, markdown, json")
+}
+
+func TestWritePatterns(t *testing.T) {
+	variables := append(testVariables(), Variable{
+		Name:        "KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>",
+		Description: "Operator of a block instance",
+		Schema:      instanceOperatorSchema,
+		Pattern:     true,
+	})
+
+	for _, format := range []string{FormatKubernetes, FormatDotenv, FormatJSON} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, Write(buf, format, variables))
+		assert.NotContains(t, buf.String(), "KAPETA_INSTANCE_OPERATOR_", "patterns are not written to %s stubs", format)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatMarkdown, variables))
+	assert.Contains(t, buf.String(), "| `KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>` | no |  | Operator of a block instance |\n")
+	assert.Contains(t, buf.String(), "### KAPETA_INSTANCE_OPERATOR_<INSTANCE ID>\n")
+}
@@ -0,0 +1,31 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package envcontract
+
+import (
+	"encoding/json"
+)
+
+// JSON schemas of the values read by the Kubernetes provider
+var (
+	stringSchema = json.RawMessage(`{"type":"string"}`)
+
+	portSchema = json.RawMessage(`{"type":"string","pattern":"^[0-9]+$"}`)
+
+	instanceConfigSchema = json.RawMessage(`{"type":"object","description":"Configuration values of the instance, keyed by path"}`)
+
+	blockHostsSchema = json.RawMessage(`{"type":"object","description":"Host of each block instance, keyed by instance ID","additionalProperties":{"type":"string"}}`)
+
+	endpointsSchema = json.RawMessage(`{"type":"array","items":{"type":"object","required":["address"],"properties":{"address":{"type":"string"},"instanceId":{"type":"string"}}}}`)
+
+	tlsSchema = json.RawMessage(`{"type":"object","description":"PEM or path to a PEM file for the CA bundle, certificate and key","properties":{"ca":{"type":"string"},"caFile":{"type":"string"},"cert":{"type":"string"},"certFile":{"type":"string"},"key":{"type":"string"},"keyFile":{"type":"string"},"serverName":{"type":"string"}}}`)
+
+	resourceInfoSchema = json.RawMessage(`{"type":"object","required":["host","port"],"properties":{"host":{"type":"string"},"port":{"type":["string","integer"]},"type":{"type":"string"},"protocol":{"type":"string"},"options":{"type":"object"},"credentials":{"type":"object","additionalProperties":{"type":"string"}},"tls":` + string(tlsSchema) + `}}`)
+
+	instanceOperatorSchema = json.RawMessage(`{"type":"object","required":["hostname","ports"],"properties":{"hostname":{"type":"string"},"ports":{"type":"object","description":"Ports keyed by port type","additionalProperties":{"type":"object","properties":{"protocol":{"type":"string"},"port":{"type":"integer"},"tls":` + string(tlsSchema) + `}}},"path":{"type":"string"},"query":{"type":"string"},"hash":{"type":"string"},"credentials":{"type":"object"},"options":{"type":"object"},"tls":` + string(tlsSchema) + `}}`)
+
+	blockInstanceSchema = json.RawMessage(`{"type":"object","required":["instanceId"],"properties":{"instanceId":{"type":"string"},"block":{"type":"object","description":"Block definition of the instance"},"connections":{"type":"array","items":{"type":"object"}}}}`)
+
+	blockInstancesSchema = json.RawMessage(`{"type":"array","items":` + string(blockInstanceSchema) + `}`)
+)
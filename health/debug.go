@@ -0,0 +1,86 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package health
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+const redacted = "[REDACTED]"
+
+// Configuration values are redacted when their key contains any of these words
+var defaultRedactedKeys = []string{"password", "secret", "token", "credential", "key", "auth", "private"}
+
+// DebugConfig is the response of /debug/kapeta/config
+type DebugConfig struct {
+	ProviderID    string                 `json:"providerId"`
+	BlockRef      string                 `json:"blockRef"`
+	SystemID      string                 `json:"systemId"`
+	InstanceID    string                 `json:"instanceId"`
+	Configuration map[string]interface{} `json:"configuration"`
+}
+
+// configurationProvider is implemented by providers that can return the whole instance configuration
+type configurationProvider interface {
+	GetConfiguration() map[string]interface{}
+}
+
+func (h *Handler) serveDebugConfig(w http.ResponseWriter, _ *http.Request) {
+	if !h.config.IsReady() {
+		writeJSON(w, http.StatusServiceUnavailable, &Report{
+			Status: StatusFail,
+			Checks: []CheckResult{{Name: "config", Status: StatusFail, Error: "configuration is not initialized"}},
+		})
+		return
+	}
+
+	provider := h.config.GetProvider()
+	dump := &DebugConfig{
+		ProviderID:    provider.GetProviderId(),
+		BlockRef:      provider.GetBlockReference(),
+		SystemID:      provider.GetSystemId(),
+		InstanceID:    provider.GetInstanceId(),
+		Configuration: map[string]interface{}{},
+	}
+	if configuration, ok := providers.As[configurationProvider](provider); ok {
+		dump.Configuration = h.redact(configuration.GetConfiguration()).(map[string]interface{})
+	}
+	writeJSON(w, http.StatusOK, dump)
+}
+
+// redact returns a copy of the value where every value under a sensitive key is replaced
+func (h *Handler) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if h.isSensitive(key) && item != nil {
+				result[key] = redacted
+				continue
+			}
+			result[key] = h.redact(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = h.redact(item)
+		}
+		return result
+	}
+	return value
+}
+
+func (h *Handler) isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range h.options.redactedKeys {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/blockdef"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// Paths served by the handler
+const (
+	PathHealthz     = "/healthz"
+	PathReadyz      = "/readyz"
+	PathDebugConfig = "/debug/kapeta/config"
+)
+
+// Status of a check or of a whole report
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// CheckResult is the outcome of a single readiness check
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the response of /healthz and /readyz
+type Report struct {
+	Status Status        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// clusterServiceStatus is implemented by providers that talk to the local cluster service
+type clusterServiceStatus interface {
+	ClusterServiceStatus() (time.Time, error)
+}
+
+// Handler serves the health, readiness and debug endpoints
+type Handler struct {
+	config  *config.Config
+	options *options
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a handler serving /healthz, /readyz and /debug/kapeta/config for the configuration,
+// usually &config.CONFIG.
+//
+// /healthz only reports that the process is serving requests, so a liveness probe does not restart the
+// block while configuration is loading. /readyz fails with 503 until the configuration is initialized,
+// the identity is resolved and the cluster service was reachable on the last request.
+func NewHandler(cfg *config.Config, opts ...Option) *Handler {
+	h := &Handler{config: cfg, options: newOptions(opts), mux: http.NewServeMux()}
+	h.mux.HandleFunc(PathHealthz, h.serveHealthz)
+	h.mux.HandleFunc(PathReadyz, h.serveReadyz)
+	if h.options.debug {
+		h.mux.HandleFunc(PathDebugConfig, h.serveDebugConfig)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, &Report{Status: StatusOK})
+}
+
+func (h *Handler) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Ready runs the readiness checks. Checks that need the provider are skipped until the configuration is initialized.
+func (h *Handler) Ready(ctx context.Context) *Report {
+	report := &Report{Status: StatusOK}
+	add := func(name string, err error) {
+		result := CheckResult{Name: name, Status: StatusOK}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	if !h.config.IsReady() {
+		add("config", errors.New("configuration is not initialized"))
+		return report
+	}
+	add("config", nil)
+
+	provider := h.config.GetProvider()
+	add("identity", checkIdentity(provider))
+	if h.options.consumers {
+		checkConsumers(provider, add)
+	}
+	// After the consumer checks, so their requests are taken into account
+	if status, ok := providers.As[clusterServiceStatus](provider); ok {
+		add("clusterService", checkClusterService(status))
+	}
+	for _, check := range h.options.checks {
+		add(check.name, check.run(ctx))
+	}
+	return report
+}
+
+func checkIdentity(provider providers.ConfigProvider) error {
+	if provider.GetSystemId() == "" || provider.GetInstanceId() == "" {
+		return errors.New("system and instance ID are not resolved")
+	}
+	return nil
+}
+
+func checkClusterService(status clusterServiceStatus) error {
+	lastContact, err := status.ClusterServiceStatus()
+	if err == nil {
+		return nil
+	}
+	if lastContact.IsZero() {
+		return fmt.Errorf("cluster service has not been reached: %w", err)
+	}
+	return fmt.Errorf("cluster service unreachable since %s: %w", lastContact.Format(time.RFC3339), err)
+}
+
+// checkConsumers resolves the address of every consumer declared by the block
+func checkConsumers(provider providers.ConfigProvider, add func(name string, err error)) {
+	definition, _ := provider.GetBlockDefinition().(map[string]interface{})
+	block, err := blockdef.Parse(definition)
+	if err != nil {
+		add("consumers", err)
+		return
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.IsService() {
+			_, err = provider.GetServiceAddress(consumer.Name, consumer.PortType)
+		} else {
+			_, err = provider.GetResourceInfo(consumer.ResourceType(), consumer.PortType, consumer.Name)
+		}
+		add(fmt.Sprintf("consumer %s (%s)", consumer.Name, consumer.PortType), err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, handler http.Handler, path string, value interface{}) int {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if value != nil {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), value), recorder.Body.String())
+	}
+	return recorder.Code
+}
+
+func TestNotInitialized(t *testing.T) {
+	handler := NewHandler(&config.Config{})
+
+	report := &Report{}
+	assert.Equal(t, http.StatusOK, get(t, handler, PathHealthz, report), "liveness does not depend on the configuration")
+	assert.Equal(t, StatusOK, report.Status)
+
+	report = &Report{}
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, handler, PathReadyz, report))
+	assert.Equal(t, &Report{Status: StatusFail, Checks: []CheckResult{
+		{Name: "config", Status: StatusFail, Error: "configuration is not initialized"},
+	}}, report)
+
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, handler, PathDebugConfig, nil))
+	assert.Equal(t, http.StatusNotFound, get(t, handler, "/unknown", nil))
+}
+
+func TestLocalProvider(t *testing.T) {
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity: clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		InstanceConfig: map[string]any{
+			"greeting":   "hello",
+			"dbPassword": "hunter2",
+			"nested":     map[string]any{"apiToken": "abc", "url": "http://example.com"},
+			"tenant":     "acme",
+		},
+		Resources: []clustertest.Resource{{
+			PortType: "mongodb",
+			Name:     "messages",
+			Info:     providers.ResourceInfo{Host: "127.0.0.1", Port: "27017"},
+		}},
+	})
+	_, err := config.Init("../testdata/block")
+	require.NoError(t, err)
+
+	dbErr := errors.New("database down")
+	handler := NewHandler(&config.CONFIG,
+		WithConsumerChecks(),
+		WithCheck("database", func(ctx context.Context) error { return dbErr }),
+		WithRedactedKeys("tenant"),
+	)
+
+	report := &Report{}
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, handler, PathReadyz, report))
+	assert.Equal(t, []CheckResult{
+		{Name: "config", Status: StatusOK},
+		{Name: "identity", Status: StatusOK},
+		{Name: "consumer messages (mongodb)", Status: StatusOK},
+		{Name: "clusterService", Status: StatusOK},
+		{Name: "database", Status: StatusFail, Error: "database down"},
+	}, report.Checks)
+
+	dbErr = nil
+	assert.Equal(t, http.StatusOK, get(t, handler, PathReadyz, &Report{}))
+
+	dump := &DebugConfig{}
+	assert.Equal(t, http.StatusOK, get(t, handler, PathDebugConfig, dump))
+	assert.Equal(t, &DebugConfig{
+		ProviderID: "local",
+		BlockRef:   "soren_mathiasen/sample-java-chat-messages-service:local",
+		SystemID:   "system-id",
+		InstanceID: "instance-id",
+		Configuration: map[string]interface{}{
+			"greeting":   "hello",
+			"dbPassword": "[REDACTED]",
+			"nested":     map[string]interface{}{"apiToken": "[REDACTED]", "url": "http://example.com"},
+			"tenant":     "[REDACTED]",
+		},
+	}, dump)
+
+	// Once the cluster service goes away the block is no longer ready
+	srv.Close()
+	report = &Report{}
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, handler, PathReadyz, report))
+	assert.Equal(t, StatusFail, report.Checks[3].Status)
+	assert.Contains(t, report.Checks[3].Error, "cluster service unreachable since")
+
+	assert.Equal(t, http.StatusOK, get(t, handler, PathHealthz, &Report{}))
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package health
+
+import (
+	"context"
+	"strings"
+)
+
+type check struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+type options struct {
+	consumers    bool
+	debug        bool
+	checks       []check
+	redactedKeys []string
+}
+
+// Option configures NewHandler
+type Option func(*options)
+
+// WithConsumerChecks makes /readyz resolve the address of every consumer declared in kapeta.yml
+func WithConsumerChecks() Option {
+	return func(o *options) {
+		o.consumers = true
+	}
+}
+
+// WithCheck adds a readiness check, e.g. a ping of the database the block owns
+func WithCheck(name string, run func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, check{name: name, run: run})
+	}
+}
+
+// WithoutDebug does not serve /debug/kapeta/config
+func WithoutDebug() Option {
+	return func(o *options) {
+		o.debug = false
+	}
+}
+
+// WithRedactedKeys redacts configuration values whose key contains any of the words, in addition to the defaults
+func WithRedactedKeys(words ...string) Option {
+	return func(o *options) {
+		for _, word := range words {
+			o.redactedKeys = append(o.redactedKeys, strings.ToLower(word))
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		debug:        true,
+		redactedKeys: append([]string(nil), defaultRedactedKeys...),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package instrument
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/kapetacom/sdk-go-config/instrument"
+
+// Attributes recorded on spans and metrics
+const (
+	AttrMethod       = attribute.Key("kapeta.config.method")
+	AttrProviderID   = attribute.Key("kapeta.provider.id")
+	AttrResourceName = attribute.Key("kapeta.resource.name")
+	AttrResourceType = attribute.Key("kapeta.resource.type")
+	AttrPortType     = attribute.Key("kapeta.port.type")
+	AttrInstanceID   = attribute.Key("kapeta.instance.id")
+	AttrConfigPath   = attribute.Key("kapeta.config.path")
+	AttrCache        = attribute.Key("kapeta.cache")
+	AttrCacheHit     = attribute.Key("kapeta.cache.hit")
+)
+
+// Values of the error.type attribute
+const (
+	ErrorTypeNotFound    = "not_found"
+	ErrorTypeUnavailable = "unavailable"
+	ErrorTypeTimeout     = "timeout"
+	ErrorTypeOther       = "error"
+)
+
+// Instrumentation records spans and metrics for provider lookups and cluster service requests.
+// It implements providers.Instrumenter, so it can be passed to config.WithInstrumentation.
+type Instrumentation struct {
+	tracer trace.Tracer
+
+	lookups         metric.Int64Counter
+	lookupDuration  metric.Float64Histogram
+	lookupErrors    metric.Int64Counter
+	cacheLookups    metric.Int64Counter
+	requestDuration metric.Float64Histogram
+}
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures New
+type Option func(*options)
+
+// WithTracerProvider sets the tracer provider instead of the global one
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the meter provider instead of the global one
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = provider
+	}
+}
+
+// New creates the instrumentation, using the global tracer and meter providers unless others are given
+func New(opts ...Option) (*Instrumentation, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.tracerProvider == nil {
+		o.tracerProvider = otel.GetTracerProvider()
+	}
+	if o.meterProvider == nil {
+		o.meterProvider = otel.GetMeterProvider()
+	}
+
+	meter := o.meterProvider.Meter(instrumentationName)
+	i := &Instrumentation{tracer: o.tracerProvider.Tracer(instrumentationName)}
+
+	var err error
+	if i.lookups, err = meter.Int64Counter("kapeta.config.lookups",
+		metric.WithDescription("Number of configuration lookups")); err != nil {
+		return nil, err
+	}
+	if i.lookupDuration, err = meter.Float64Histogram("kapeta.config.lookup.duration",
+		metric.WithDescription("Duration of configuration lookups"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if i.lookupErrors, err = meter.Int64Counter("kapeta.config.errors",
+		metric.WithDescription("Number of failed configuration lookups, by error type")); err != nil {
+		return nil, err
+	}
+	if i.cacheLookups, err = meter.Int64Counter("kapeta.config.cache.lookups",
+		metric.WithDescription("Number of cache lookups, by cache and whether they were hits")); err != nil {
+		return nil, err
+	}
+	if i.requestDuration, err = meter.Float64Histogram("kapeta.cluster_service.request.duration",
+		metric.WithDescription("Duration of requests to the local cluster service"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Wrap returns a provider recording a span and metrics for every lookup made through it
+func (i *Instrumentation) Wrap(provider providers.ConfigProvider) providers.ConfigProvider {
+	return &Provider{Wrapper: providers.Wrapper{ConfigProvider: provider}, instrumentation: i}
+}
+
+// RecordCacheLookup records a lookup in a cache, such as the secrets cache
+func (i *Instrumentation) RecordCacheLookup(cache string, hit bool) {
+	i.cacheLookups.Add(context.Background(), 1, metric.WithAttributes(AttrCache.String(cache), AttrCacheHit.Bool(hit)))
+}
+
+// record runs the lookup in a span and records its duration and outcome
+func record[T any](i *Instrumentation, providerID, method string, attributes []attribute.KeyValue, lookup func() (T, error)) (T, error) {
+	attributes = append(attributes, AttrMethod.String(method), AttrProviderID.String(providerID))
+	ctx, span := i.tracer.Start(context.Background(), "kapeta.config."+method, trace.WithAttributes(attributes...))
+	defer span.End()
+
+	start := time.Now()
+	value, err := lookup()
+	duration := time.Since(start)
+
+	metricAttributes := metric.WithAttributes(AttrMethod.String(method), AttrProviderID.String(providerID))
+	i.lookups.Add(ctx, 1, metricAttributes)
+	i.lookupDuration.Record(ctx, duration.Seconds(), metricAttributes)
+	if err != nil {
+		errorType := ErrorType(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+		// Not found is an answer rather than a failure, so the span is only marked as failed for other errors
+		if errorType != ErrorTypeNotFound {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		i.lookupErrors.Add(ctx, 1, metric.WithAttributes(AttrMethod.String(method), AttrProviderID.String(providerID), semconv.ErrorTypeKey.String(errorType)))
+	}
+	return value, err
+}
+
+// ErrorType classifies an error as not found, timeout, unavailable or other
+func ErrorType(err error) string {
+	if errors.Is(err, providers.ErrNotFound) {
+		return ErrorTypeNotFound
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTypeTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorTypeTimeout
+		}
+		return ErrorTypeUnavailable
+	}
+	return ErrorTypeOther
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	config "github.com/kapetacom/sdk-go-config"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testInstrumentation struct {
+	*Instrumentation
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+}
+
+func newTestInstrumentation(t *testing.T) *testInstrumentation {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	instrumentation, err := New(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	require.NoError(t, err)
+	return &testInstrumentation{Instrumentation: instrumentation, spans: spans, reader: reader}
+}
+
+// sums returns the value of every data point of the counter, keyed by its attributes
+func (ti *testInstrumentation) sums(t *testing.T, name string) map[string]int64 {
+	data := metricdata.ResourceMetrics{}
+	require.NoError(t, ti.reader.Collect(context.Background(), &data))
+	result := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				result[point.Attributes.Encoded(attribute.DefaultEncoder())] += point.Value
+			}
+		}
+	}
+	return result
+}
+
+// histogramCount returns the number of recordings of the histogram
+func (ti *testInstrumentation) histogramCount(t *testing.T, name string) uint64 {
+	data := metricdata.ResourceMetrics{}
+	require.NoError(t, ti.reader.Collect(context.Background(), &data))
+	var count uint64
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name == name {
+				for _, point := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					count += point.Count
+				}
+			}
+		}
+	}
+	return count
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	result := map[attribute.Key]string{}
+	for _, kv := range span.Attributes() {
+		result[kv.Key] = kv.Value.Emit()
+	}
+	return result
+}
+
+func TestProvider(t *testing.T) {
+	ti := newTestInstrumentation(t)
+	static := providers.NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &providers.StaticFixture{
+		Configuration: map[string]interface{}{"greeting": "hello"},
+		Services:      map[string]map[string]string{"users": {"rest": "http://users/"}},
+	})
+	provider := ti.Wrap(static)
+
+	address, err := provider.GetServiceAddress("users", "rest")
+	require.NoError(t, err)
+	assert.Equal(t, "http://users/", address)
+	_, err = provider.GetServiceAddress("unknown", "rest")
+	assert.ErrorIs(t, err, providers.ErrNotFound)
+	assert.Equal(t, "hello", provider.Get("greeting"))
+	assert.Equal(t, "system-id", provider.GetSystemId())
+
+	spans := ti.spans.Ended()
+	require.Len(t, spans, 3, "identity getters are not recorded")
+
+	assert.Equal(t, "kapeta.config.GetServiceAddress", spans[0].Name())
+	assert.Equal(t, map[attribute.Key]string{
+		AttrMethod:       "GetServiceAddress",
+		AttrProviderID:   "static",
+		AttrResourceName: "users",
+		AttrPortType:     "rest",
+	}, attributes(spans[0]))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, ErrorTypeNotFound, attributes(spans[1])["error.type"])
+	assert.Equal(t, codes.Unset, spans[1].Status().Code, "not found is not a failure")
+
+	assert.Equal(t, "greeting", attributes(spans[2])[AttrConfigPath])
+
+	assert.Equal(t, map[string]int64{
+		"kapeta.config.method=GetServiceAddress,kapeta.provider.id=static": 2,
+		"kapeta.config.method=Get,kapeta.provider.id=static":               1,
+	}, ti.sums(t, "kapeta.config.lookups"))
+	assert.Equal(t, map[string]int64{
+		"error.type=not_found,kapeta.config.method=GetServiceAddress,kapeta.provider.id=static": 1,
+	}, ti.sums(t, "kapeta.config.errors"))
+	assert.Equal(t, uint64(3), ti.histogramCount(t, "kapeta.config.lookup.duration"))
+
+	found, ok := providers.As[*providers.StaticConfigProvider](provider)
+	assert.True(t, ok)
+	assert.Same(t, static, found)
+}
+
+func TestTransport(t *testing.T) {
+	ti := newTestInstrumentation(t)
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity:      clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		ProviderPorts: map[string]string{"rest": "40001"},
+	})
+	provider := providers.NewLocalConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{}, providers.WithTransport(ti.Transport(nil)))
+	defer provider.DisableExitHandler()
+
+	wrapped := ti.Wrap(provider)
+	_, err := wrapped.GetServerPort("rest")
+	require.NoError(t, err)
+
+	srv.Close()
+	_, err = wrapped.GetServerPort("grpc")
+	require.Error(t, err)
+
+	var requests []sdktrace.ReadOnlySpan
+	for _, span := range ti.spans.Ended() {
+		if span.SpanKind() == trace.SpanKindClient {
+			requests = append(requests, span)
+		}
+	}
+	// The last two requests are the lookups, the others are made while the provider is created
+	require.GreaterOrEqual(t, len(requests), 2)
+	assert.Equal(t, uint64(len(requests)), ti.histogramCount(t, "kapeta.cluster_service.request.duration"))
+	requests = requests[len(requests)-2:]
+	assert.Equal(t, "kapeta.cluster_service GET", requests[0].Name())
+	assert.Equal(t, "200", attributes(requests[0])["http.response.status_code"])
+	assert.Contains(t, attributes(requests[0])["url.full"], "/config/provides/rest")
+	assert.Equal(t, codes.Error, requests[1].Status().Code)
+	assert.Equal(t, ErrorTypeUnavailable, attributes(requests[1])["error.type"])
+
+	assert.Equal(t, map[string]int64{
+		"error.type=unavailable,kapeta.config.method=GetServerPort,kapeta.provider.id=local": 1,
+	}, ti.sums(t, "kapeta.config.errors"))
+}
+
+func TestRecordCacheLookup(t *testing.T) {
+	ti := newTestInstrumentation(t)
+	ti.RecordCacheLookup("secrets", false)
+	ti.RecordCacheLookup("secrets", true)
+	ti.RecordCacheLookup("secrets", true)
+
+	assert.Equal(t, map[string]int64{
+		"kapeta.cache=secrets,kapeta.cache.hit=false": 1,
+		"kapeta.cache=secrets,kapeta.cache.hit=true":  2,
+	}, ti.sums(t, "kapeta.config.cache.lookups"))
+}
+
+func TestErrorType(t *testing.T) {
+	assert.Equal(t, ErrorTypeNotFound, ErrorType(fmt.Errorf("lookup: %w", &providers.NotFoundError{Kind: providers.KindService, Name: "users"})))
+	assert.Equal(t, ErrorTypeTimeout, ErrorType(fmt.Errorf("request: %w", context.DeadlineExceeded)))
+	assert.Equal(t, ErrorTypeUnavailable, ErrorType(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, ErrorTypeOther, ErrorType(errors.New("invalid response")))
+}
+
+func TestInit(t *testing.T) {
+	ti := newTestInstrumentation(t)
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "../testdata/static.yml")
+
+	provider, err := config.Init("../testdata/block", config.WithInstrumentation(ti))
+	require.NoError(t, err)
+	assert.IsType(t, &Provider{}, provider)
+
+	port, err := provider.GetServerPort("rest")
+	require.NoError(t, err)
+	assert.Equal(t, "40001", port)
+	require.Len(t, ti.spans.Ended(), 1)
+	assert.Equal(t, "kapeta.config.GetServerPort", ti.spans.Ended()[0].Name())
+}
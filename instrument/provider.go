@@ -0,0 +1,90 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package instrument
+
+import (
+	"github.com/kapetacom/sdk-go-config/plangraph"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Provider records a span and metrics for every lookup of the wrapped provider.
+// The identity getters are passed through without recording, since they never fail and are called very often.
+type Provider struct {
+	providers.Wrapper
+	instrumentation *Instrumentation
+}
+
+func (p *Provider) GetServerPort(portType string) (string, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetServerPort", []attribute.KeyValue{AttrPortType.String(portType)}, func() (string, error) {
+		return p.ConfigProvider.GetServerPort(portType)
+	})
+}
+
+func (p *Provider) GetServerHost() (string, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetServerHost", nil, p.ConfigProvider.GetServerHost)
+}
+
+func (p *Provider) GetServiceAddress(serviceName, portType string) (string, error) {
+	attributes := []attribute.KeyValue{AttrResourceName.String(serviceName), AttrPortType.String(portType)}
+	return record(p.instrumentation, p.GetProviderId(), "GetServiceAddress", attributes, func() (string, error) {
+		return p.ConfigProvider.GetServiceAddress(serviceName, portType)
+	})
+}
+
+func (p *Provider) GetServiceEndpoints(serviceName, portType string) ([]providers.Endpoint, error) {
+	attributes := []attribute.KeyValue{AttrResourceName.String(serviceName), AttrPortType.String(portType)}
+	return record(p.instrumentation, p.GetProviderId(), "GetServiceEndpoints", attributes, func() ([]providers.Endpoint, error) {
+		return p.ConfigProvider.GetServiceEndpoints(serviceName, portType)
+	})
+}
+
+func (p *Provider) GetResourceInfo(resourceType, portType, resourceName string) (*providers.ResourceInfo, error) {
+	attributes := []attribute.KeyValue{AttrResourceType.String(resourceType), AttrPortType.String(portType), AttrResourceName.String(resourceName)}
+	return record(p.instrumentation, p.GetProviderId(), "GetResourceInfo", attributes, func() (*providers.ResourceInfo, error) {
+		return p.ConfigProvider.GetResourceInfo(resourceType, portType, resourceName)
+	})
+}
+
+func (p *Provider) GetInstanceHost(instanceID string) (string, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetInstanceHost", []attribute.KeyValue{AttrInstanceID.String(instanceID)}, func() (string, error) {
+		return p.ConfigProvider.GetInstanceHost(instanceID)
+	})
+}
+
+func (p *Provider) Get(path string) interface{} {
+	value, _ := record(p.instrumentation, p.GetProviderId(), "Get", []attribute.KeyValue{AttrConfigPath.String(path)}, func() (interface{}, error) {
+		return p.ConfigProvider.Get(path), nil
+	})
+	return value
+}
+
+func (p *Provider) GetOrDefault(path string, defaultValue interface{}) interface{} {
+	value, _ := record(p.instrumentation, p.GetProviderId(), "GetOrDefault", []attribute.KeyValue{AttrConfigPath.String(path)}, func() (interface{}, error) {
+		return p.ConfigProvider.GetOrDefault(path, defaultValue), nil
+	})
+	return value
+}
+
+func (p *Provider) GetInstanceForConsumer(resourceName string) (*providers.BlockInstanceDetails, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetInstanceForConsumer", []attribute.KeyValue{AttrResourceName.String(resourceName)}, func() (*providers.BlockInstanceDetails, error) {
+		return p.ConfigProvider.GetInstanceForConsumer(resourceName)
+	})
+}
+
+func (p *Provider) GetInstanceOperator(instanceId string) (*providers.InstanceOperator, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetInstanceOperator", []attribute.KeyValue{AttrInstanceID.String(instanceId)}, func() (*providers.InstanceOperator, error) {
+		return p.ConfigProvider.GetInstanceOperator(instanceId)
+	})
+}
+
+func (p *Provider) GetInstancesForProvider(resourceName string) ([]*providers.BlockInstanceDetails, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetInstancesForProvider", []attribute.KeyValue{AttrResourceName.String(resourceName)}, func() ([]*providers.BlockInstanceDetails, error) {
+		return p.ConfigProvider.GetInstancesForProvider(resourceName)
+	})
+}
+
+func (p *Provider) GetPlanGraph() (*plangraph.Graph, error) {
+	return record(p.instrumentation, p.GetProviderId(), "GetPlanGraph", nil, p.ConfigProvider.GetPlanGraph)
+}
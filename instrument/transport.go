@@ -0,0 +1,63 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package instrument
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport returns a transport recording a client span and the duration of every request made with the base
+// transport, or http.DefaultTransport if it is nil
+func (i *Instrumentation) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, instrumentation: i}
+}
+
+type transport struct {
+	base            http.RoundTripper
+	instrumentation *Instrumentation
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attributes := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	ctx, span := t.instrumentation.tracer.Start(req.Context(), "kapeta.cluster_service "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	defer span.End()
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	duration := time.Since(start)
+
+	metricAttributes := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(req.Method)}
+	if err != nil {
+		errorType := ErrorType(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		metricAttributes = append(metricAttributes, semconv.ErrorTypeKey.String(errorType))
+	} else {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, resp.Status)
+			metricAttributes = append(metricAttributes, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		}
+		metricAttributes = append(metricAttributes, semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	t.instrumentation.requestDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(metricAttributes...))
+	return resp, err
+}
@@ -1,6 +1,9 @@
 package config
 
-import "github.com/kapetacom/sdk-go-config/providers"
+import (
+	"github.com/kapetacom/sdk-go-config/plangraph"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
 
 // ConfigProviderMock is a mock implementation of providers.ConfigProvider.
 // It can be used to mock the ConfigProvider interface.
@@ -27,10 +30,12 @@ type ConfigProviderMock struct {
 	GetInstanceOperatorFunc     func(instanceId string) (*providers.InstanceOperator, error)
 	GetInstancesForProviderFunc func(resourceName string) ([]*providers.BlockInstanceDetails, error)
 	GetOrDefaultFunc            func(path string, defaultValue interface{}) interface{}
+	GetPlanGraphFunc            func() (*plangraph.Graph, error)
 	GetProviderIdFunc           func() string
 	GetServerHostFunc           func() (string, error)
 	GetServerPortFunc           func(portType string) (string, error)
 	GetServiceAddressFunc       func(serviceName string, portType string) (string, error)
+	GetServiceEndpointsFunc     func(serviceName string, portType string) ([]providers.Endpoint, error)
 	GetSystemIdFunc             func() string
 }
 
@@ -70,6 +75,10 @@ func (c *ConfigProviderMock) GetOrDefault(path string, defaultValue interface{})
 	return c.GetOrDefaultFunc(path, defaultValue)
 }
 
+func (c *ConfigProviderMock) GetPlanGraph() (*plangraph.Graph, error) {
+	return c.GetPlanGraphFunc()
+}
+
 func (c *ConfigProviderMock) GetProviderId() string {
 	return c.GetProviderIdFunc()
 }
@@ -90,6 +99,10 @@ func (c *ConfigProviderMock) GetServiceAddress(serviceName string, portType stri
 	return c.GetServiceAddressFunc(serviceName, portType)
 }
 
+func (c *ConfigProviderMock) GetServiceEndpoints(serviceName string, portType string) ([]providers.Endpoint, error) {
+	return c.GetServiceEndpointsFunc(serviceName, portType)
+}
+
 func (c *ConfigProviderMock) GetSystemId() string {
 	return c.GetSystemIdFunc()
 }
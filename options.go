@@ -0,0 +1,79 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"log/slog"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/secrets"
+)
+
+type initOptions struct {
+	providerOptions []providers.Option
+	secretResolver  secrets.SecretResolver
+	instrumenter    providers.Instrumenter
+	middlewares     []providers.Middleware
+}
+
+// Option configures Init
+type Option func(*initOptions)
+
+// WithLogger sets the logger used by the configuration provider
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *initOptions) {
+		o.providerOptions = append(o.providerOptions, providers.WithLogger(logger))
+	}
+}
+
+// WithSecretResolver resolves secret references in configuration values and credentials with the resolver.
+// Without it, a resolver is created from KAPETA_SECRETS_BACKEND if set.
+func WithSecretResolver(resolver secrets.SecretResolver) Option {
+	return func(o *initOptions) {
+		o.secretResolver = resolver
+	}
+}
+
+// WithInstrumentation records every lookup of the provider and every request to the local cluster service
+// with the instrumenter, see the instrument package. Secret cache lookups are recorded as well if the
+// instrumenter has a RecordCacheLookup(cache string, hit bool) method.
+func WithInstrumentation(instrumenter providers.Instrumenter) Option {
+	return func(o *initOptions) {
+		o.instrumenter = instrumenter
+		o.providerOptions = append(o.providerOptions, providers.WithInstrumenter(instrumenter))
+	}
+}
+
+// WithMiddleware wraps the provider with the middlewares, see providers.Chain. They see the configuration
+// with secret references resolved, and are wrapped by the instrumentation so their effect is recorded.
+func WithMiddleware(middlewares ...providers.Middleware) Option {
+	return func(o *initOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// SetLogger sets the SDK-wide logger, used by everything that is not given a logger explicitly
+func SetLogger(logger *slog.Logger) {
+	cfg.SetLogger(logger)
+}
+
+// resolverOptions returns the options of the resolver created from the environment
+func (o *initOptions) resolverOptions() []secrets.ResolverOption {
+	recorder, ok := o.instrumenter.(interface{ RecordCacheLookup(cache string, hit bool) })
+	if !ok {
+		return nil
+	}
+	return []secrets.ResolverOption{secrets.WithCacheObserver(func(hit bool) {
+		recorder.RecordCacheLookup("secrets", hit)
+	})}
+}
+
+func newInitOptions(opts []Option) *initOptions {
+	o := &initOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// OverrideServiceAddress makes the service consumed as resourceName on the port type resolve to the address,
+// instead of the address given by the provider. It can be called before or after Init, and the returned
+// function restores the previous address:
+//
+//	t.Cleanup(config.CONFIG.OverrideServiceAddress("users", "rest", server.URL))
+func (c *Config) OverrideServiceAddress(resourceName, portType, address string) (restore func()) {
+	return c.overrides.OverrideServiceAddress(resourceName, portType, address)
+}
+
+// OverrideResourceInfo makes the resource consumed as resourceName on the port type resolve to the info,
+// instead of the info given by the provider. A nil info removes the override. The returned function restores the
+// previous info.
+func (c *Config) OverrideResourceInfo(resourceName, portType string, info *providers.ResourceInfo) (restore func()) {
+	return c.overrides.OverrideResourceInfo(resourceName, portType, info)
+}
+
+// OverrideValue makes the configuration value at the path resolve to the value, instead of the value given
+// by the provider. The returned function restores the previous value.
+func (c *Config) OverrideValue(path string, value interface{}) (restore func()) {
+	return c.overrides.OverrideValue(path, value)
+}
+
+// OverrideInstanceHost makes the instance resolve to the host, instead of the host given by the provider.
+// The returned function restores the previous host.
+func (c *Config) OverrideInstanceHost(instanceID, host string) (restore func()) {
+	return c.overrides.OverrideInstanceHost(instanceID, host)
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+func TestOverrides(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/static.yml")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	// Overrides made before Init are in effect once the provider is created
+	restoreValue := CONFIG.OverrideValue("greeting", "hi")
+	defer restoreValue()
+
+	provider, err := Init("testdata/block")
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if CONFIG.Get("greeting") != "hi" {
+		t.Errorf("Get() = %v, want hi", CONFIG.Get("greeting"))
+	}
+	if _, ok := provider.(*providers.StaticConfigProvider); ok {
+		t.Errorf("Init() returned the static provider without the overrides")
+	}
+	if _, ok := providers.As[*providers.StaticConfigProvider](provider); !ok {
+		t.Errorf("As() did not find the static provider behind the overrides")
+	}
+
+	restoreInfo := CONFIG.OverrideResourceInfo("messages", "mongodb", &providers.ResourceInfo{Host: "localhost", Port: "27017"})
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if err != nil {
+		t.Fatalf("GetResourceInfo() returned error: %v", err)
+	}
+	if info.Host != "localhost" {
+		t.Errorf("GetResourceInfo() host = %s, want localhost", info.Host)
+	}
+
+	restoreInfo()
+	info, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if err != nil {
+		t.Fatalf("GetResourceInfo() returned error: %v", err)
+	}
+	if info.Host == "localhost" {
+		t.Errorf("GetResourceInfo() host is still overridden after restore")
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"github.com/kapetacom/sdk-go-config/plangraph"
+)
+
+// PlanGraph returns the graph of the plan the block instance runs in.
+// Use Neighbourhood with the ID of the instance to get the instances it is connected to.
+func PlanGraph() (*plangraph.Graph, error) {
+	return GetProvider().GetPlanGraph()
+}
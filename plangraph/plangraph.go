@@ -0,0 +1,198 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package plangraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+)
+
+// Graph is the graph of block instances in a plan and the connections between them.
+// A connection goes from the consuming instance to the providing instance.
+// Instances and connections are kept in plan order.
+type Graph struct {
+	name        string
+	instances   []model.BlockInstance
+	connections []model.Connection
+}
+
+// New creates the graph of the plan
+func New(plan *model.Plan) *Graph {
+	return &Graph{
+		name:        plan.Metadata.Name,
+		instances:   append([]model.BlockInstance{}, plan.Spec.Blocks...),
+		connections: append([]model.Connection{}, plan.Spec.Connections...),
+	}
+}
+
+// Parse creates the graph of a JSON encoded plan
+func Parse(data []byte) (*Graph, error) {
+	plan := &model.Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	return New(plan), nil
+}
+
+// Name returns the name of the plan
+func (g *Graph) Name() string {
+	return g.name
+}
+
+// Instances returns every block instance in the plan
+func (g *Graph) Instances() []model.BlockInstance {
+	return append([]model.BlockInstance{}, g.instances...)
+}
+
+// Instance returns the block instance with the ID
+func (g *Graph) Instance(instanceID string) (model.BlockInstance, bool) {
+	for _, instance := range g.instances {
+		if instance.Id == instanceID {
+			return instance, true
+		}
+	}
+	return model.BlockInstance{}, false
+}
+
+// Connections returns every connection in the plan
+func (g *Graph) Connections() []model.Connection {
+	return append([]model.Connection{}, g.connections...)
+}
+
+// ConnectionsOut returns the connections of resources the instance consumes
+func (g *Graph) ConnectionsOut(instanceID string) []model.Connection {
+	return g.filterConnections(func(connection model.Connection) bool {
+		return connection.Consumer.BlockId == instanceID
+	})
+}
+
+// ConnectionsIn returns the connections of resources the instance provides
+func (g *Graph) ConnectionsIn(instanceID string) []model.Connection {
+	return g.filterConnections(func(connection model.Connection) bool {
+		return connection.Provider.BlockId == instanceID
+	})
+}
+
+// ProvidersFor returns the instances providing the resource the instance consumes as resourceName.
+// An empty resource name returns the providers of every resource the instance consumes.
+func (g *Graph) ProvidersFor(consumerID, resourceName string) []model.BlockInstance {
+	return g.connectedInstances(g.ConnectionsOut(consumerID), func(connection model.Connection) (string, string) {
+		return connection.Consumer.ResourceName, connection.Provider.BlockId
+	}, resourceName)
+}
+
+// ConsumersOf returns the instances consuming the resource the instance provides as resourceName.
+// An empty resource name returns the consumers of every resource the instance provides.
+func (g *Graph) ConsumersOf(providerID, resourceName string) []model.BlockInstance {
+	return g.connectedInstances(g.ConnectionsIn(providerID), func(connection model.Connection) (string, string) {
+		return connection.Provider.ResourceName, connection.Consumer.BlockId
+	}, resourceName)
+}
+
+// Neighbourhood returns the graph of the instance, the instances it is connected to and the connections between them
+func (g *Graph) Neighbourhood(instanceID string) *Graph {
+	connections := g.filterConnections(func(connection model.Connection) bool {
+		return connection.Consumer.BlockId == instanceID || connection.Provider.BlockId == instanceID
+	})
+	ids := map[string]bool{instanceID: true}
+	for _, connection := range connections {
+		ids[connection.Consumer.BlockId] = true
+		ids[connection.Provider.BlockId] = true
+	}
+
+	neighbourhood := &Graph{name: g.name, instances: []model.BlockInstance{}, connections: connections}
+	for _, instance := range g.instances {
+		if ids[instance.Id] {
+			neighbourhood.instances = append(neighbourhood.instances, instance)
+		}
+	}
+	return neighbourhood
+}
+
+// DOT returns the graph in the Graphviz DOT language, with an edge from every consumer to its provider
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(g.name))
+	for _, instance := range g.instances {
+		label := instance.Name
+		if instance.Block.Ref != "" {
+			label += "\n" + instance.Block.Ref
+		}
+		fmt.Fprintf(&b, "  %s [label=%s];\n", strconv.Quote(instance.Id), strconv.Quote(label))
+	}
+	for _, connection := range g.connections {
+		label := connection.Consumer.ResourceName
+		if connection.Provider.ResourceName != connection.Consumer.ResourceName {
+			label += " -> " + connection.Provider.ResourceName
+		}
+		if connection.Port != nil && connection.Port.Type != "" {
+			label += " (" + connection.Port.Type + ")"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n",
+			strconv.Quote(connection.Consumer.BlockId), strconv.Quote(connection.Provider.BlockId), strconv.Quote(label))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+type jsonInstance struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Ref  string `json:"ref"`
+}
+
+type jsonGraph struct {
+	Name        string             `json:"name"`
+	Instances   []jsonInstance     `json:"instances"`
+	Connections []model.Connection `json:"connections"`
+}
+
+// MarshalJSON encodes the graph as its name, the ID, name and block reference of every instance, and the connections
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	encoded := jsonGraph{
+		Name:        g.name,
+		Instances:   make([]jsonInstance, 0, len(g.instances)),
+		Connections: append(make([]model.Connection, 0, len(g.connections)), g.connections...),
+	}
+	for _, instance := range g.instances {
+		encoded.Instances = append(encoded.Instances, jsonInstance{ID: instance.Id, Name: instance.Name, Ref: instance.Block.Ref})
+	}
+	return json.Marshal(encoded)
+}
+
+func (g *Graph) filterConnections(match func(model.Connection) bool) []model.Connection {
+	connections := make([]model.Connection, 0)
+	for _, connection := range g.connections {
+		if match(connection) {
+			connections = append(connections, connection)
+		}
+	}
+	return connections
+}
+
+// connectedInstances returns the instances at the other end of the connections, in plan order and once each,
+// even if the plan lists an instance more than once.
+// ends returns the resource name at the near end and the instance ID at the other end of a connection.
+func (g *Graph) connectedInstances(connections []model.Connection, ends func(model.Connection) (string, string), resourceName string) []model.BlockInstance {
+	ids := map[string]bool{}
+	for _, connection := range connections {
+		name, instanceID := ends(connection)
+		if resourceName == "" || name == resourceName {
+			ids[instanceID] = true
+		}
+	}
+
+	instances := make([]model.BlockInstance, 0)
+	for _, instance := range g.instances {
+		if ids[instance.Id] {
+			instances = append(instances, instance)
+			delete(ids, instance.Id)
+		}
+	}
+	return instances
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package plangraph
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(instances []model.BlockInstance) []string {
+	result := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		result = append(result, instance.Id)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	assert.Equal(t, "kapeta/sample-plan", graph.Name())
+	assert.Equal(t, []string{"gateway", "messages-service", "users-1", "users-2", "billing"}, ids(graph.Instances()))
+	assert.Len(t, graph.Connections(), 4)
+
+	instance, found := graph.Instance("users-1")
+	assert.True(t, found)
+	assert.Equal(t, "kapeta/users-service:1.2.0", instance.Block.Ref)
+	_, found = graph.Instance("missing")
+	assert.False(t, found)
+
+	_, err = Parse([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestConnections(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	out := graph.ConnectionsOut("messages-service")
+	require.Len(t, out, 2)
+	assert.Equal(t, "users-1", out[0].Provider.BlockId)
+	assert.Equal(t, "users-2", out[1].Provider.BlockId)
+
+	in := graph.ConnectionsIn("messages-service")
+	require.Len(t, in, 1)
+	assert.Equal(t, "gateway", in[0].Consumer.BlockId)
+
+	assert.Empty(t, graph.ConnectionsOut("users-1"))
+	assert.NotNil(t, graph.ConnectionsOut("users-1"), "no connections is an empty list")
+}
+
+func TestProvidersAndConsumers(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"users-1", "users-2"}, ids(graph.ProvidersFor("messages-service", "users")))
+	assert.Equal(t, []string{"users-1", "users-2"}, ids(graph.ProvidersFor("messages-service", "")))
+	assert.Empty(t, ids(graph.ProvidersFor("messages-service", "other")))
+
+	// The consumer resource name is matched, whatever the provider calls it
+	assert.Equal(t, []string{"users-1"}, ids(graph.ProvidersFor("billing", "accounts")))
+	assert.Empty(t, ids(graph.ProvidersFor("billing", "users")))
+
+	assert.Equal(t, []string{"messages-service", "billing"}, ids(graph.ConsumersOf("users-1", "users")))
+	assert.Equal(t, []string{"gateway"}, ids(graph.ConsumersOf("messages-service", "")))
+}
+
+func TestNeighbourhood(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	neighbourhood := graph.Neighbourhood("messages-service")
+	assert.Equal(t, "kapeta/sample-plan", neighbourhood.Name())
+	assert.Equal(t, []string{"gateway", "messages-service", "users-1", "users-2"}, ids(neighbourhood.Instances()))
+	assert.Len(t, neighbourhood.Connections(), 3)
+
+	alone := graph.Neighbourhood("missing")
+	assert.Empty(t, alone.Instances())
+	assert.Empty(t, alone.Connections())
+}
+
+func TestDOT(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	assert.Equal(t, `digraph "kapeta/sample-plan" {
+  "gateway" [label="Gateway\nkapeta/gateway:1.0.0"];
+  "messages-service" [label="Messages\nkapeta/messages:local"];
+  "gateway" -> "messages-service" [label="messages (rest)"];
+}
+`, graph.Neighbourhood("gateway").DOT())
+
+	assert.Contains(t, graph.DOT(), `"billing" -> "users-1" [label="accounts -> users (rest)"];`)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	graph, err := Parse(testdata.PlanJSON)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(graph.Neighbourhood("gateway"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "kapeta/sample-plan",
+		"instances": [
+			{"id": "gateway", "name": "Gateway", "ref": "kapeta/gateway:1.0.0"},
+			{"id": "messages-service", "name": "Messages", "ref": "kapeta/messages:local"}
+		],
+		"connections": [
+			{
+				"consumer": {"blockId": "gateway", "resourceName": "messages"},
+				"provider": {"blockId": "messages-service", "resourceName": "messages"},
+				"port": {"type": "rest"}
+			}
+		]
+	}`, string(data))
+}
+
+func TestNewCopiesPlan(t *testing.T) {
+	plan := &model.Plan{Spec: model.PlanSpec{Blocks: []model.BlockInstance{{Id: "a"}}}}
+	graph := New(plan)
+	plan.Spec.Blocks[0].Id = "changed"
+
+	instances := graph.Instances()
+	assert.Equal(t, []string{"a"}, ids(instances))
+	instances[0].Id = "changed"
+	assert.Equal(t, []string{"a"}, ids(graph.Instances()))
+}
+
+func TestDuplicateInstances(t *testing.T) {
+	graph := New(&model.Plan{Spec: model.PlanSpec{
+		Blocks: []model.BlockInstance{{Id: "a"}, {Id: "b"}, {Id: "a"}},
+		Connections: []model.Connection{
+			{Consumer: model.Endpoint{BlockId: "a", ResourceName: "users"}, Provider: model.Endpoint{BlockId: "b", ResourceName: "users"}},
+		},
+	}})
+
+	assert.Equal(t, []string{"a"}, ids(graph.ConsumersOf("b", "users")), "an instance listed twice is returned once")
+}
@@ -12,7 +12,10 @@ import (
 	"strings"
 	"sync"
 
+	cfg "github.com/kapetacom/sdk-go-config/config"
 	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/ref"
+	"github.com/kapetacom/sdk-go-config/secrets"
 	"gopkg.in/yaml.v3"
 )
 
@@ -26,6 +29,8 @@ type Config struct {
 
 	callbacks []func(providers.ConfigProvider)
 	once      sync.Once
+
+	overrides providers.Overrides
 }
 
 // TODO: See if we can remove this global variable
@@ -38,6 +43,12 @@ const (
 	kapetaBlockRef   = "KAPETA_BLOCK_REF"
 	kapetaInstanceID = "KAPETA_INSTANCE_ID"
 
+	kapetaStaticConfigFile  = "KAPETA_STATIC_CONFIG_FILE"
+	defaultStaticConfigFile = "kapeta-static.yml"
+
+	kapetaDockerEnvFile  = "KAPETA_DOCKER_ENV_FILE"
+	defaultDockerEnvFile = "kapeta-env.json"
+
 	defaultSystemType = "development"
 	defaultSystemID   = ""
 	defaultInstanceID = ""
@@ -108,15 +119,19 @@ func (c *Config) getInstanceHost(instanceID string) (string, error) {
 	return c.GetProvider().GetInstanceHost(instanceID)
 }
 
-// Init initializes the configuration provider based on the kapeta.yml file in the given block directory
-func Init(blockDir string) (providers.ConfigProvider, error) {
-	muConfig.Lock()
-	defer muConfig.Unlock()
-
-	if CONFIG.provider != nil {
-		return CONFIG.provider, nil
-	}
+// Environment describes the block and identity a provider is created for, as resolved from kapeta.yml and the environment
+type Environment struct {
+	SystemType       string
+	SystemID         string
+	InstanceID       string
+	BlockRef         string
+	BlockDefinition  map[string]interface{}
+	StaticConfigFile string
+	DockerEnvFile    string
+}
 
+// LoadBlockDefinition reads and parses the kapeta.yml file in the given block directory
+func LoadBlockDefinition(blockDir string) (map[string]interface{}, error) {
 	blockDefinition := map[string]interface{}{}
 
 	if configContent, exists := os.LookupEnv("TEST_KAPETA_BLOCK_CONFIG_FILE"); exists {
@@ -140,31 +155,115 @@ func Init(blockDir string) (providers.ConfigProvider, error) {
 			return nil, fmt.Errorf("error parsing kapeta.yml: %v", err)
 		}
 	}
-	metadata := blockDefinition["metadata"]
-	metadataMap := metadata.(map[string]interface{})
-	if metadataMap["name"] == nil {
+	metadataMap, _ := blockDefinition["metadata"].(map[string]interface{})
+	if metadataMap == nil || metadataMap["name"] == nil {
 		return nil, fmt.Errorf("kapeta.yml file contained invalid YML: %s", blockDir)
 	}
 
+	return blockDefinition, nil
+}
+
+// LoadEnvironment loads the block definition and resolves the environment the same way Init does, without creating a provider
+func LoadEnvironment(blockDir string) (*Environment, error) {
+	blockDefinition, err := LoadBlockDefinition(blockDir)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataMap := blockDefinition["metadata"].(map[string]interface{})
 	blockRefLocal := fmt.Sprintf("%s:local", metadataMap["name"])
 
-	systemType := strings.ToLower(getEnvOrDefault(kapetaSystemType, defaultSystemType))
-	systemID := getEnvOrDefault(kapetaSystemID, defaultSystemID)
-	instanceID := getEnvOrDefault(kapetaInstanceID, defaultInstanceID)
-	blockRef := getEnvOrDefault(kapetaBlockRef, blockRefLocal)
+	return &Environment{
+		SystemType:       strings.ToLower(getEnvOrDefault(kapetaSystemType, defaultSystemType)),
+		SystemID:         getEnvOrDefault(kapetaSystemID, defaultSystemID),
+		InstanceID:       getEnvOrDefault(kapetaInstanceID, defaultInstanceID),
+		BlockRef:         getEnvOrDefault(kapetaBlockRef, blockRefLocal),
+		BlockDefinition:  blockDefinition,
+		StaticConfigFile: getEnvOrDefault(kapetaStaticConfigFile, filepath.Join(blockDir, defaultStaticConfigFile)),
+		DockerEnvFile:    getEnvOrDefault(kapetaDockerEnvFile, filepath.Join(blockDir, defaultDockerEnvFile)),
+	}, nil
+}
+
+// Init initializes the configuration provider based on the kapeta.yml file in the given block directory.
+// The provider is returned wrapped by the overrides and any middlewares, so use providers.As rather than
+// a type assertion to reach the concrete provider.
+func Init(blockDir string, opts ...Option) (providers.ConfigProvider, error) {
+	o := newInitOptions(opts)
+
+	muConfig.Lock()
+	defer muConfig.Unlock()
+
+	if CONFIG.provider != nil {
+		return CONFIG.provider, nil
+	}
+
+	env, err := LoadEnvironment(blockDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, explicit := os.LookupEnv(kapetaBlockRef); explicit {
+		if _, err := ref.Parse(env.BlockRef); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", kapetaBlockRef, err)
+		}
+	}
+
+	// The Kubernetes and docker providers panic if the environment config file cannot be read
+	if _, err := cfg.LoadConfigFile(); err != nil {
+		return nil, fmt.Errorf("error reading environment config file: %w", err)
+	}
 
 	var provider providers.ConfigProvider
 
-	switch systemType {
+	switch env.SystemType {
 	case "k8s", "kubernetes":
-		provider = providers.NewKubernetesConfigProvider(blockRef, systemID, instanceID, blockDefinition)
+		provider = providers.NewKubernetesConfigProvider(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, o.providerOptions...)
 
 	case "development", "dev", "local":
-		provider = providers.NewLocalConfigProvider(blockRef, systemID, instanceID, blockDefinition)
+		localProvider, err := providers.NewLocalConfigProviderE(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, o.providerOptions...)
+		if err != nil {
+			return nil, err
+		}
+		provider = localProvider
+
+	case "static":
+		fixture, err := providers.LoadStaticFixture(env.StaticConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading static configuration: %w", err)
+		}
+		provider = providers.NewStaticConfigProvider(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, fixture, o.providerOptions...)
+
+	case "docker", "docker-compose":
+		dockerEnv, err := LoadDockerEnv(env.DockerEnvFile)
+		if err != nil {
+			return nil, err
+		}
+		provider = providers.NewDockerComposeConfigProvider(env.BlockRef, env.SystemID, env.InstanceID, env.BlockDefinition, dockerEnv, o.providerOptions...)
 
 	default:
-		return nil, fmt.Errorf("unknown environment: %s", systemType)
+		return nil, fmt.Errorf("unknown environment: %s", env.SystemType)
+	}
+
+	resolver := o.secretResolver
+	if resolver == nil {
+		envResolver, err := secrets.NewResolverFromEnv(o.resolverOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring secrets: %w", err)
+		}
+		if envResolver != nil {
+			resolver = envResolver
+		}
+	}
+
+	var middlewares []providers.Middleware
+	if o.instrumenter != nil {
+		middlewares = append(middlewares, o.instrumenter.Wrap)
+	}
+	middlewares = append(middlewares, CONFIG.overrides.Middleware())
+	middlewares = append(middlewares, o.middlewares...)
+	if resolver != nil {
+		middlewares = append(middlewares, secrets.Middleware(resolver))
 	}
+	provider = providers.Chain(provider, middlewares...)
 
 	CONFIG.provider = provider
 
@@ -175,6 +274,21 @@ func Init(blockDir string) (providers.ConfigProvider, error) {
 	return provider, nil
 }
 
+// LoadDockerEnv loads the env file of the docker-compose provider.
+// A missing file is only an error if KAPETA_DOCKER_ENV_FILE points at it, since every value has a default.
+func LoadDockerEnv(path string) (*providers.DockerComposeEnv, error) {
+	if _, explicit := os.LookupEnv(kapetaDockerEnvFile); !explicit {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return &providers.DockerComposeEnv{}, nil
+		}
+	}
+	env, err := providers.LoadDockerComposeEnv(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading docker env file: %w", err)
+	}
+	return env, nil
+}
+
 func Transcode(in, out interface{}) error {
 	buf := new(bytes.Buffer)
 	err := json.NewEncoder(buf).Encode(in)
@@ -1,40 +1,27 @@
 package config
 
 import (
+	"context"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
-	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/kapetacom/sdk-go-config/clustertest"
 	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/ref"
 )
 
-func hostAndFromURL(url string) (string, string) {
-	hostAndPort := url[7:]
-	return strings.Split(hostAndPort, ":")[0], strings.Split(hostAndPort, ":")[1]
+func testClusterFixture() *clustertest.Fixture {
+	return &clustertest.Fixture{
+		Identity:       clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		InstanceConfig: map[string]any{"id": "instanceID", "host": "bla"},
+	}
 }
-func TestInit(t *testing.T) {
 
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/identity") {
-			_, _ = w.Write([]byte("{\"systemId\": \"system-id\", \"instanceId\": \"instance-id\"}"))
-			return
-		}
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
-		_, _ = w.Write([]byte("40004"))
-	}))
-	defer srv.Close()
+func TestInit(t *testing.T) {
 
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+	clustertest.Start(t, testClusterFixture())
 
 	tests := []struct {
 		name        string
@@ -71,24 +58,7 @@ func TestInit(t *testing.T) {
 }
 
 func TestGetOrDefault(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/identity") {
-			_, _ = w.Write([]byte("{\"systemId\": \"system-id\", \"instanceId\": \"instance-id\"}"))
-			return
-		}
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
-		_, _ = w.Write([]byte("40004"))
-	}))
-	defer srv.Close()
-
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+	clustertest.Start(t, testClusterFixture())
 
 	tests := []struct {
 		name         string
@@ -128,6 +98,220 @@ func TestGetOrDefault(t *testing.T) {
 	}
 }
 
+func TestInitStatic(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/static.yml")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	provider, err := Init("testdata/block")
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if provider.GetProviderId() != "static" {
+		t.Errorf("GetProviderId() = %s, want static", provider.GetProviderId())
+	}
+	if provider.GetBlockReference() != "soren_mathiasen/sample-java-chat-messages-service:local" {
+		t.Errorf("GetBlockReference() = %s", provider.GetBlockReference())
+	}
+	if provider.Get("greeting") != "hello" {
+		t.Errorf("Get() = %v, want hello", provider.Get("greeting"))
+	}
+
+	CONFIG.provider = nil
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/missing.yml")
+	_, err = Init("testdata/block")
+	if err == nil {
+		t.Errorf("Init() did not return error for a missing static config file")
+	}
+}
+
+func TestInitDocker(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "docker")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	// Without an env file, conventions are used
+	provider, err := Init("testdata/block")
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if provider.GetProviderId() != "docker" {
+		t.Errorf("GetProviderId() = %s, want docker", provider.GetProviderId())
+	}
+	if port, _ := provider.GetServerPort("rest"); port != "80" {
+		t.Errorf("GetServerPort() = %s, want 80", port)
+	}
+
+	CONFIG.provider = nil
+	t.Setenv("KAPETA_DOCKER_ENV_FILE", "testdata/kapeta-env.json")
+	provider, err = Init("testdata/block")
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if provider.Get("greeting") != "hello" {
+		t.Errorf("Get() = %v, want hello", provider.Get("greeting"))
+	}
+
+	CONFIG.provider = nil
+	t.Setenv("KAPETA_DOCKER_ENV_FILE", "testdata/missing.json")
+	if _, err = Init("testdata/block"); err == nil {
+		t.Errorf("Init() did not return error for a missing docker env file")
+	}
+}
+
+// upperResolver stands in for a secret backend and upper-cases every value
+type upperResolver struct{}
+
+func (upperResolver) Resolve(_ context.Context, value string) (string, error) {
+	return strings.ToUpper(value), nil
+}
+
+func TestInitSecrets(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/static.yml")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	provider, err := Init("testdata/block", WithSecretResolver(upperResolver{}))
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if provider.Get("greeting") != "HELLO" {
+		t.Errorf("Get() = %v, want HELLO", provider.Get("greeting"))
+	}
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if err != nil {
+		t.Fatalf("GetResourceInfo() returned error: %v", err)
+	}
+	if info.Credentials["password"] != "SECRET" {
+		t.Errorf("GetResourceInfo() password = %s, want SECRET", info.Credentials["password"])
+	}
+
+	CONFIG.provider = nil
+	t.Setenv("KAPETA_SECRETS_BACKEND", "unknown")
+	if _, err = Init("testdata/block"); err == nil {
+		t.Errorf("Init() did not return error for an unknown secrets backend")
+	}
+}
+
+// fixedPortProvider serves every port type on the same port
+type fixedPortProvider struct {
+	providers.Wrapper
+	port string
+}
+
+func (p *fixedPortProvider) GetServerPort(_ string) (string, error) {
+	return p.port, nil
+}
+
+func TestInitMiddleware(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/static.yml")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	var greeting interface{}
+	observe := func(next providers.ConfigProvider) providers.ConfigProvider {
+		greeting = next.Get("greeting")
+		return next
+	}
+	fixedPort := func(next providers.ConfigProvider) providers.ConfigProvider {
+		return &fixedPortProvider{Wrapper: providers.Wrapper{ConfigProvider: next}, port: "8080"}
+	}
+
+	provider, err := Init("testdata/block", WithSecretResolver(upperResolver{}), WithMiddleware(observe, fixedPort))
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	if greeting != "HELLO" {
+		t.Errorf("middleware saw greeting %v, want the resolved HELLO", greeting)
+	}
+	if port, _ := provider.GetServerPort("rest"); port != "8080" {
+		t.Errorf("GetServerPort() = %s, want 8080", port)
+	}
+	if _, ok := providers.As[*providers.StaticConfigProvider](provider); !ok {
+		t.Errorf("As() did not find the static provider behind the middlewares")
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	t.Setenv("KAPETA_SYSTEM_TYPE", "kubernetes")
+	t.Setenv("KAPETA_CONFIG_PATH", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Init("testdata/block"); err == nil || !strings.HasPrefix(err.Error(), "error reading environment config file: ") {
+		t.Errorf("Init() error = %v, want an error reading the environment config file", err)
+	}
+
+	t.Setenv("KAPETA_CONFIG_PATH", "")
+	t.Setenv("KAPETA_SYSTEM_TYPE", "local")
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster: [invalid")
+	if _, err := Init("testdata/block"); err == nil || !strings.HasPrefix(err.Error(), "failed to load cluster config: ") {
+		t.Errorf("Init() error = %v, want the error of the local provider", err)
+	}
+	if CONFIG.provider != nil {
+		t.Errorf("Init() kept a provider after failing")
+	}
+}
+
+func TestInitInvalidBlockRef(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "static")
+	t.Setenv("KAPETA_STATIC_CONFIG_FILE", "testdata/static.yml")
+	t.Setenv("KAPETA_BLOCK_REF", "messages:1.0")
+	CONFIG.provider = nil
+	defer func() { CONFIG.provider = nil }()
+
+	_, err := Init("testdata/block")
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid KAPETA_BLOCK_REF: ") {
+		t.Fatalf("Init() error = %v, want invalid KAPETA_BLOCK_REF", err)
+	}
+
+	t.Setenv("KAPETA_BLOCK_REF", "kapeta://kapeta/messages:1.0.0")
+	provider, err := Init("testdata/block")
+	if err != nil {
+		t.Fatalf("Init() returned error: %v", err)
+	}
+	blockRef, ok := providers.As[interface{ GetBlockRef() ref.Ref }](provider)
+	if !ok {
+		t.Fatalf("provider does not have GetBlockRef")
+	}
+	if blockRef.GetBlockRef().Version != "1.0.0" {
+		t.Errorf("GetBlockRef() = %v, want version 1.0.0", blockRef.GetBlockRef())
+	}
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	t.Setenv("KAPETA_SYSTEM_TYPE", "Kubernetes")
+	t.Setenv("KAPETA_SYSTEM_ID", "system-id")
+	t.Setenv("KAPETA_INSTANCE_ID", "instance-id")
+
+	env, err := LoadEnvironment("testdata/block")
+	if err != nil {
+		t.Fatalf("LoadEnvironment() returned error: %v", err)
+	}
+	if env.SystemType != "kubernetes" {
+		t.Errorf("SystemType = %s, want kubernetes", env.SystemType)
+	}
+	if env.SystemID != "system-id" || env.InstanceID != "instance-id" {
+		t.Errorf("identity = %s/%s, want system-id/instance-id", env.SystemID, env.InstanceID)
+	}
+	if env.BlockRef != "soren_mathiasen/sample-java-chat-messages-service:local" {
+		t.Errorf("BlockRef = %s", env.BlockRef)
+	}
+	if env.StaticConfigFile != "testdata/block/kapeta-static.yml" {
+		t.Errorf("StaticConfigFile = %s", env.StaticConfigFile)
+	}
+	if env.BlockDefinition["kind"] != "kapeta://kapeta/block-type-service:0.0.2" {
+		t.Errorf("BlockDefinition kind = %v", env.BlockDefinition["kind"])
+	}
+
+	if _, err := LoadEnvironment("testdata/invalid"); err == nil {
+		t.Errorf("LoadEnvironment() did not return error for an invalid block definition")
+	}
+}
+
 func TestGetProvider(t *testing.T) {
 	t.Run("provider is nil", func(t *testing.T) {
 		defer func() {
@@ -5,8 +5,13 @@ package providers
 
 import (
 	"encoding/json"
-	"github.com/kapetacom/schemas/packages/go/model"
+	"log/slog"
 	"os"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/plangraph"
+	"github.com/kapetacom/sdk-go-config/ref"
 )
 
 type ConfigProvider interface {
@@ -16,6 +21,7 @@ type ConfigProvider interface {
 	GetInstanceId() string
 	GetServerPort(portType string) (string, error)
 	GetServiceAddress(serviceName, portType string) (string, error)
+	GetServiceEndpoints(serviceName, portType string) ([]Endpoint, error)
 	GetResourceInfo(resourceType, portType, resourceName string) (*ResourceInfo, error)
 	GetInstanceHost(instanceID string) (string, error)
 	GetServerHost() (string, error)
@@ -25,6 +31,7 @@ type ConfigProvider interface {
 	GetInstanceForConsumer(resourceName string) (*BlockInstanceDetails, error)
 	GetInstanceOperator(instanceId string) (*InstanceOperator, error)
 	GetInstancesForProvider(resourceName string) ([]*BlockInstanceDetails, error)
+	GetPlanGraph() (*plangraph.Graph, error)
 }
 
 type DefaultCredentials struct {
@@ -35,6 +42,7 @@ type DefaultCredentials struct {
 type InstanceOperatorPort struct {
 	Protocol string `json:"protocol"`
 	Port     int    `json:"port"`
+	TLS      *TLS   `json:"tls,omitempty"`
 }
 
 type InstanceOperator struct {
@@ -45,6 +53,7 @@ type InstanceOperator struct {
 	Hash        string                          `json:"hash,omitempty"`
 	Credentials map[string]any                  `json:"credentials,omitempty"`
 	Options     map[string]any                  `json:"options,omitempty"`
+	TLS         *TLS                            `json:"tls,omitempty"`
 }
 
 type BlockInstanceDetails struct {
@@ -53,6 +62,13 @@ type BlockInstanceDetails struct {
 	Connections []*model.Connection `json:"connections"`
 }
 
+// Endpoint is a single address a consumed service can be reached on.
+// A service backed by several block instances resolves to one endpoint per instance.
+type Endpoint struct {
+	Address    string `json:"address"`
+	InstanceId string `json:"instanceId,omitempty"`
+}
+
 type InstanceValue struct {
 	ID string `json:"id"`
 }
@@ -77,6 +93,7 @@ type ResourceInfo struct {
 	Protocol    string                 `json:"protocol"`
 	Options     map[string]interface{} `json:"options"`
 	Credentials map[string]string      `json:"credentials"`
+	TLS         *TLS                   `json:"tls,omitempty"`
 }
 
 type AbstractConfigProvider struct {
@@ -85,6 +102,15 @@ type AbstractConfigProvider struct {
 	InstanceID               string                 `json:"instanceId"`
 	BlockDefinition          map[string]interface{} `json:"blockDefinition"`
 	EnvironmentConfiguration map[string]string      `json:"environmentConfiguration"`
+	logger                   *slog.Logger
+}
+
+// GetLogger returns the logger of the provider, falling back to the SDK-wide logger
+func (a *AbstractConfigProvider) GetLogger() *slog.Logger {
+	if a.logger != nil {
+		return a.logger
+	}
+	return cfg.Logger()
 }
 
 func (a *AbstractConfigProvider) GetBlockDefinition() interface{} {
@@ -95,6 +121,12 @@ func (a *AbstractConfigProvider) GetBlockReference() string {
 	return a.BlockRef
 }
 
+// GetBlockRef returns the parsed block reference, or the zero Ref if it is not a valid reference
+func (a *AbstractConfigProvider) GetBlockRef() ref.Ref {
+	blockRef, _ := ref.Parse(a.BlockRef)
+	return blockRef
+}
+
 func (a *AbstractConfigProvider) GetSystemId() string {
 	return a.SystemID
 }
@@ -116,3 +148,130 @@ func (a *AbstractConfigProvider) LookupEnv(name string) (string, bool) {
 	value := a.EnvironmentConfiguration[name]
 	return value, value != ""
 }
+
+// copyConfiguration copies the configuration along with its nested maps and slices
+func copyConfiguration(configuration map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(configuration))
+	for key, value := range configuration {
+		result[key] = copyValue(value)
+	}
+	return result
+}
+
+// copyResourceInfo copies the info along with its credentials, options and TLS material
+func copyResourceInfo(info *ResourceInfo) *ResourceInfo {
+	copied := *info
+	if info.Credentials != nil {
+		copied.Credentials = make(map[string]string, len(info.Credentials))
+		for key, value := range info.Credentials {
+			copied.Credentials[key] = value
+		}
+	}
+	if info.Options != nil {
+		copied.Options = copyValue(info.Options).(map[string]interface{})
+	}
+	copied.TLS = copyTLS(info.TLS)
+	return &copied
+}
+
+// copyValue copies the maps and slices of a value decoded from JSON
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = copyValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = copyValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// copyInstanceOperator copies the operator along with its ports, credentials, options and TLS material
+func copyInstanceOperator(operator *InstanceOperator) *InstanceOperator {
+	copied := *operator
+	if operator.Ports != nil {
+		copied.Ports = make(map[string]InstanceOperatorPort, len(operator.Ports))
+		for portType, port := range operator.Ports {
+			port.TLS = copyTLS(port.TLS)
+			copied.Ports[portType] = port
+		}
+	}
+	if operator.Credentials != nil {
+		copied.Credentials = copyValue(operator.Credentials).(map[string]interface{})
+	}
+	if operator.Options != nil {
+		copied.Options = copyValue(operator.Options).(map[string]interface{})
+	}
+	copied.TLS = copyTLS(operator.TLS)
+	return &copied
+}
+
+// copyBlockInstanceDetails copies the details along with the block definition and the connections
+func copyBlockInstanceDetails(details *BlockInstanceDetails) *BlockInstanceDetails {
+	copied := *details
+	if details.Block != nil {
+		block := *details.Block
+		block.Attachments = append([]model.Attachment(nil), details.Block.Attachments...)
+		block.Metadata.Description = copyString(block.Metadata.Description)
+		block.Metadata.Title = copyString(block.Metadata.Title)
+		block.Metadata.Visibility = copyString(block.Metadata.Visibility)
+		if details.Block.Spec != nil {
+			block.Spec = copyValue(details.Block.Spec).(map[string]interface{})
+		}
+		copied.Block = &block
+	}
+	if details.Connections != nil {
+		copied.Connections = make([]*model.Connection, len(details.Connections))
+		for i, connection := range details.Connections {
+			if connection == nil {
+				continue
+			}
+			c := *connection
+			if connection.Mapping != nil {
+				c.Mapping = copyValue(connection.Mapping).(map[string]interface{})
+			}
+			if connection.Port != nil {
+				port := *connection.Port
+				c.Port = &port
+			}
+			copied.Connections[i] = &c
+		}
+	}
+	return &copied
+}
+
+// copyInstances copies every instance in the list
+func copyInstances(instances []*BlockInstanceDetails) []*BlockInstanceDetails {
+	copied := make([]*BlockInstanceDetails, 0, len(instances))
+	for _, details := range instances {
+		if details != nil {
+			details = copyBlockInstanceDetails(details)
+		}
+		copied = append(copied, details)
+	}
+	return copied
+}
+
+func copyTLS(material *TLS) *TLS {
+	if material == nil {
+		return nil
+	}
+	copied := *material
+	return &copied
+}
+
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+// Middleware wraps a provider to add behaviour, such as caching, retries or logging, to its lookups
+type Middleware func(next ConfigProvider) ConfigProvider
+
+// Chain wraps the base provider with the middlewares. The first middleware is the outermost,
+// so it sees every call first and the base provider sees it last.
+func Chain(base ConfigProvider, middlewares ...Middleware) ConfigProvider {
+	provider := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		provider = middlewares[i](provider)
+	}
+	return provider
+}
+
+// Wrapper passes every call through to the wrapped provider. Embed it in a middleware to only
+// implement the methods it changes:
+//
+//	type loggingProvider struct {
+//		providers.Wrapper
+//	}
+//
+//	func (p *loggingProvider) GetServiceAddress(serviceName, portType string) (string, error) {
+//		address, err := p.ConfigProvider.GetServiceAddress(serviceName, portType)
+//		slog.Info("resolved service", "name", serviceName, "address", address, "error", err)
+//		return address, err
+//	}
+//
+// Wrapper implements Unwrapper, so optional capabilities of the wrapped provider are still found with As.
+type Wrapper struct {
+	ConfigProvider
+}
+
+// Unwrap returns the wrapped provider
+func (w Wrapper) Unwrap() ConfigProvider {
+	return w.ConfigProvider
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tracingProvider records the name of the middleware in the calls made through it
+type tracingProvider struct {
+	Wrapper
+	name  string
+	calls *[]string
+}
+
+func (p *tracingProvider) GetServerPort(portType string) (string, error) {
+	*p.calls = append(*p.calls, p.name)
+	return p.ConfigProvider.GetServerPort(portType)
+}
+
+func tracing(name string, calls *[]string) Middleware {
+	return func(next ConfigProvider) ConfigProvider {
+		return &tracingProvider{Wrapper: Wrapper{ConfigProvider: next}, name: name, calls: calls}
+	}
+}
+
+func TestChain(t *testing.T) {
+	static := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &StaticFixture{
+		ServerPorts: map[string]string{"rest": "40001"},
+		ServerTLS:   map[string]*TLS{"rest": {CertFile: "/tls/tls.crt", KeyFile: "/tls/tls.key"}},
+	})
+
+	var calls []string
+	provider := Chain(static, tracing("outer", &calls), tracing("inner", &calls))
+
+	port, err := provider.GetServerPort("rest")
+	require.NoError(t, err)
+	assert.Equal(t, "40001", port)
+	assert.Equal(t, []string{"outer", "inner"}, calls, "the first middleware sees calls first")
+	assert.Equal(t, "system-id", provider.GetSystemId(), "methods not implemented by the middleware are passed through")
+
+	found, ok := As[*StaticConfigProvider](provider)
+	assert.True(t, ok)
+	assert.Same(t, static, found)
+
+	material, err := ResolveServerTLS(provider, "rest")
+	require.NoError(t, err, "capabilities of the base provider are found behind middlewares")
+	assert.Equal(t, "/tls/tls.crt", material.CertFile)
+
+	assert.Same(t, static, Chain(static))
+}
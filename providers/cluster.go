@@ -0,0 +1,58 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+)
+
+// ClusterServiceTransport returns the base URL to send requests to the cluster service to, and a transport
+// reaching it over TCP, TLS verified with the CA bundle of the connection, or a unix socket
+func ClusterServiceTransport(connection *cfg.ClusterConnection) (string, *http.Transport, error) {
+	clusterURL, err := url.Parse(connection.URL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid cluster service URL %s: %w", connection.URL, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch clusterURL.Scheme {
+	case "http":
+	case "https":
+		if connection.CAFile != "" {
+			pem, err := os.ReadFile(connection.CAFile)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to read cluster service CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return "", nil, fmt.Errorf("no certificates found in cluster service CA %s", connection.CAFile)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+	case "unix":
+		socket := clusterURL.Path
+		if socket == "" {
+			return "", nil, fmt.Errorf("invalid cluster service URL %s: missing socket path", connection.URL)
+		}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		// The host is ignored when dialing the socket
+		return "http://localhost", transport, nil
+	default:
+		return "", nil, fmt.Errorf("invalid cluster service URL %s: scheme must be http, https or unix", connection.URL)
+	}
+	return strings.TrimSuffix(clusterURL.String(), "/"), transport, nil
+}
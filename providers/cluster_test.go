@@ -0,0 +1,159 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// identityHandler answers the requests made while creating a local provider, and records the authorization headers
+func identityHandler(authorizations *[]string, mu *sync.Mutex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*authorizations = append(*authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/identity"):
+			_, _ = w.Write([]byte(`{"systemId": "system-id", "instanceId": "instance-id"}`))
+		case strings.HasSuffix(r.URL.Path, "/provides/rest"):
+			_, _ = w.Write([]byte("40001"))
+		default:
+			_, _ = w.Write([]byte("{}"))
+		}
+	}
+}
+
+func TestLocalUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "kapeta")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "cluster.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var authorizations []string
+	srv := httptest.NewUnstartedServer(identityHandler(&authorizations, &mu))
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	home := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(home, "cluster-service.token"), []byte("secret-token\n"), 0o600))
+	t.Setenv("KAPETA_HOME", home)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", "unix://"+socket)
+
+	provider := NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
+	port, err := provider.GetServerPort("rest")
+	require.NoError(t, err)
+	assert.Equal(t, "40001", port)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, authorizations)
+	for _, authorization := range authorizations {
+		assert.Equal(t, "Bearer secret-token", authorization)
+	}
+}
+
+func TestLocalHTTPS(t *testing.T) {
+	var mu sync.Mutex
+	var authorizations []string
+	srv := httptest.NewTLSServer(identityHandler(&authorizations, &mu))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	t.Setenv("KAPETA_HOME", t.TempDir())
+	t.Setenv("KAPETA_LOCAL_CLUSTER_URL", srv.URL)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_CA_FILE", caFile)
+
+	provider := NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
+	port, err := provider.GetServerPort("rest")
+	require.NoError(t, err)
+	assert.Equal(t, "40001", port)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, authorizations[0], "no token is sent without a token file")
+
+	t.Setenv("KAPETA_LOCAL_CLUSTER_CA_FILE", "")
+	assert.Panics(t, func() {
+		NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{})
+	}, "the certificate of the cluster service is verified")
+}
+
+func TestClusterServiceTransport(t *testing.T) {
+	baseURL, _, err := ClusterServiceTransport(&cfg.ClusterConnection{URL: "http://127.0.0.1:35100/"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:35100", baseURL)
+
+	baseURL, _, err = ClusterServiceTransport(&cfg.ClusterConnection{URL: "unix:///var/run/kapeta.sock"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost", baseURL)
+
+	_, _, err = ClusterServiceTransport(&cfg.ClusterConnection{URL: "unix://"})
+	assert.EqualError(t, err, "invalid cluster service URL unix://: missing socket path")
+	_, _, err = ClusterServiceTransport(&cfg.ClusterConnection{URL: "ftp://127.0.0.1"})
+	assert.EqualError(t, err, "invalid cluster service URL ftp://127.0.0.1: scheme must be http, https or unix")
+	_, _, err = ClusterServiceTransport(&cfg.ClusterConnection{URL: "https://127.0.0.1", CAFile: "/missing/ca.crt"})
+	assert.ErrorContains(t, err, "failed to read cluster service CA")
+}
+
+func TestLocalProfile(t *testing.T) {
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity: clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+	})
+
+	home := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "profiles", "feature"), 0o700))
+	clusterConfig := "cluster:\n  url: " + srv.URL + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(home, "profiles", "feature", "cluster-service.yml"), []byte(clusterConfig), 0o600))
+	t.Setenv("KAPETA_HOME", home)
+	t.Setenv("KAPETA_PROFILE", "feature")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", "")
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", "")
+	t.Setenv(KAPETA_ENVIRONMENT_TYPE, "")
+
+	provider := NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
+	assert.Equal(t, "system-id", provider.GetSystemId(), "the cluster service of the profile is used")
+
+	requests := srv.RequestsTo(http.MethodGet, "/config/identity")
+	require.NotEmpty(t, requests)
+	assert.Equal(t, "process; profile=feature", requests[0].Header.Get(HEADER_KAPETA_ENVIRONMENT))
+}
+
+func TestClusterServiceHeaders(t *testing.T) {
+	t.Setenv("KAPETA_PROFILE", "")
+	t.Setenv(KAPETA_ENVIRONMENT_TYPE, "docker")
+
+	headers := ClusterServiceHeaders("kapeta/messages:local", "system-id", "instance-id")
+	assert.Equal(t, map[string]string{
+		HEADER_KAPETA_ENVIRONMENT: "docker",
+		HEADER_KAPETA_BLOCK:       "kapeta/messages:local",
+		HEADER_KAPETA_SYSTEM:      "system-id",
+		HEADER_KAPETA_INSTANCE:    "instance-id",
+	}, headers, "the environment has no profile without KAPETA_PROFILE")
+
+	t.Setenv("KAPETA_PROFILE", "feature")
+	assert.Equal(t, "docker; profile=feature", ClusterServiceHeaders("", "", "")[HEADER_KAPETA_ENVIRONMENT])
+}
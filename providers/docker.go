@@ -0,0 +1,281 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+)
+
+// Port every block listens on in docker-compose unless configured otherwise, keyed by port type
+var dockerDefaultPorts = map[string]string{
+	"grpc": "50051",
+}
+
+const dockerDefaultPort = "80"
+
+// DockerComposeEnv is the content of the kapeta-env.json file mounted into a docker-compose service.
+// Everything is optional, but only the services and instance hosts listed can be looked up.
+type DockerComposeEnv struct {
+	SystemID      string                 `json:"systemId,omitempty"`
+	InstanceID    string                 `json:"instanceId,omitempty"`
+	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	// Ports overrides the port convention for the server ports of this block, keyed by port type
+	Ports map[string]string `json:"ports,omitempty"`
+	// Services maps a consumed resource name to the compose service providing it, as "service" or "service:port".
+	// Without a port, the service is expected to listen on the port convention.
+	Services map[string]string `json:"services,omitempty"`
+	// Resources is keyed by resource name and then port type
+	Resources map[string]map[string]*ResourceInfo `json:"resources,omitempty"`
+	// InstanceHosts maps a block instance ID to its compose service
+	InstanceHosts map[string]string                  `json:"instanceHosts,omitempty"`
+	Operators     map[string]*InstanceOperator       `json:"operators,omitempty"`
+	Consumers     map[string]*BlockInstanceDetails   `json:"consumers,omitempty"`
+	Providers     map[string][]*BlockInstanceDetails `json:"providers,omitempty"`
+}
+
+// LoadDockerComposeEnv reads and parses a kapeta-env.json file
+func LoadDockerComposeEnv(path string) (*DockerComposeEnv, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading docker env file: %w", err)
+	}
+
+	env := &DockerComposeEnv{}
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, fmt.Errorf("error parsing docker env file %s: %w", path, err)
+	}
+	return env, nil
+}
+
+// DockerComposeConfigProvider implements the ConfigProvider interface for blocks running in docker-compose.
+// Every lookup can be overridden with the same environment variable the KubernetesConfigProvider reads.
+type DockerComposeConfigProvider struct {
+	AbstractConfigProvider
+	env           *DockerComposeEnv
+	configuration map[string]interface{}
+}
+
+// NewDockerComposeConfigProvider creates a new instance of DockerComposeConfigProvider.
+// The identity in the env file is used when no system or instance ID is given.
+func NewDockerComposeConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}, env *DockerComposeEnv, opts ...Option) *DockerComposeConfigProvider {
+	o := newOptions(opts)
+	if env == nil {
+		env = &DockerComposeEnv{}
+	}
+	if systemID == "" {
+		systemID = env.SystemID
+	}
+	if instanceID == "" {
+		instanceID = env.InstanceID
+	}
+
+	provider := &DockerComposeConfigProvider{
+		AbstractConfigProvider: AbstractConfigProvider{
+			BlockRef:                 blockRef,
+			SystemID:                 systemID,
+			InstanceID:               instanceID,
+			BlockDefinition:          blockDefinition,
+			EnvironmentConfiguration: cfg.ReadConfigFile(),
+			logger:                   o.logger,
+		},
+		env:           env,
+		configuration: env.Configuration,
+	}
+
+	if value, exists := provider.LookupEnv(EnvInstanceConfig); exists {
+		provider.configuration = map[string]interface{}{}
+		if err := json.Unmarshal([]byte(value), &provider.configuration); err != nil {
+			panic(fmt.Sprintf("Invalid JSON in environment variable: %s", EnvInstanceConfig))
+		}
+	}
+	if provider.configuration == nil {
+		provider.configuration = map[string]interface{}{}
+	}
+	return provider
+}
+
+// GetProviderId returns the identifier for the config provider
+func (d *DockerComposeConfigProvider) GetProviderId() string {
+	return "docker"
+}
+
+// GetServerPort returns the port to listen on for the current instance
+func (d *DockerComposeConfigProvider) GetServerPort(portType string) (string, error) {
+	if portType == "" {
+		portType = DEFAULT_SERVER_PORT_TYPE
+	}
+	if value, exists := d.LookupEnv(ServerPortEnvVar(portType)); exists {
+		return value, nil
+	}
+	if port, exists := lookupKey(d.env.Ports, portType); exists {
+		return port, nil
+	}
+	return conventionPort(portType), nil
+}
+
+// GetServerHost returns the host for the current process
+func (d *DockerComposeConfigProvider) GetServerHost() (string, error) {
+	if value, exists := d.LookupEnv(EnvProviderHost); exists {
+		return value, nil
+	}
+	// Any host within the Docker container
+	return "0.0.0.0", nil
+}
+
+// GetServiceAddress returns the address of the compose service providing the resource
+func (d *DockerComposeConfigProvider) GetServiceAddress(resourceName, portType string) (string, error) {
+	if value, exists := d.LookupEnv(ServiceEnvVar(resourceName, portType)); exists {
+		return value, nil
+	}
+
+	service, exists := lookupKey(d.env.Services, resourceName)
+	if !exists {
+		return "", &NotFoundError{Kind: KindService, Name: resourceName + "/" + portType}
+	}
+	host := service
+	if _, _, err := net.SplitHostPort(service); err != nil {
+		host = net.JoinHostPort(service, conventionPort(portType))
+	}
+	if strings.EqualFold(portType, "grpc") {
+		return host, nil
+	}
+	return fmt.Sprintf("http://%s/", host), nil
+}
+
+// GetServiceEndpoints returns the endpoints of the service, which is the compose service itself unless overridden
+func (d *DockerComposeConfigProvider) GetServiceEndpoints(resourceName, portType string) ([]Endpoint, error) {
+	envVar := ServiceEndpointsEnvVar(resourceName, portType)
+	if value, exists := d.LookupEnv(envVar); exists {
+		endpoints := make([]Endpoint, 0)
+		if err := json.Unmarshal([]byte(value), &endpoints); err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return endpoints, nil
+	}
+
+	address, err := d.GetServiceAddress(resourceName, portType)
+	if err != nil {
+		return nil, err
+	}
+	return []Endpoint{{Address: address}}, nil
+}
+
+// GetResourceInfo returns the resource info from the environment or the env file
+func (d *DockerComposeConfigProvider) GetResourceInfo(resourceType, portType, resourceName string) (*ResourceInfo, error) {
+	envVar := ResourceEnvVar(resourceName, portType)
+	if value, exists := d.LookupEnv(envVar); exists {
+		info := &ResourceInfo{}
+		if err := json.Unmarshal([]byte(value), info); err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return info, nil
+	}
+
+	if ports, exists := lookupKey(d.env.Resources, resourceName); exists {
+		if info, exists := lookupKey(ports, portType); exists && info != nil {
+			return copyResourceInfo(info), nil
+		}
+	}
+	return nil, &NotFoundError{Kind: KindResource, Name: resourceName + "/" + portType}
+}
+
+// GetInstanceHost returns the compose service of the instance
+func (d *DockerComposeConfigProvider) GetInstanceHost(instanceID string) (string, error) {
+	if value, exists := d.LookupEnv(EnvBlockHosts); exists {
+		hosts := map[string]string{}
+		if err := json.Unmarshal([]byte(value), &hosts); err != nil {
+			return "", fmt.Errorf("invalid JSON in environment variable: %s", EnvBlockHosts)
+		}
+		if host, exists := hosts[instanceID]; exists {
+			return host, nil
+		}
+	}
+
+	if host, exists := lookupKey(d.env.InstanceHosts, instanceID); exists {
+		return host, nil
+	}
+	return "", &NotFoundError{Kind: KindInstanceHost, Name: instanceID}
+}
+
+// Get returns the configuration value for the given path
+func (d *DockerComposeConfigProvider) Get(path string) interface{} {
+	return d.GetOrDefault(path, nil)
+}
+
+// GetOrDefault returns the configuration value for the given path, or the default value if not found
+func (d *DockerComposeConfigProvider) GetOrDefault(path string, defaultValue interface{}) interface{} {
+	if value, exists := d.configuration[path]; exists && value != nil {
+		return copyValue(value)
+	}
+	return defaultValue
+}
+
+// GetConfiguration returns a copy of the whole instance configuration
+func (d *DockerComposeConfigProvider) GetConfiguration() map[string]interface{} {
+	return copyConfiguration(d.configuration)
+}
+
+// GetInstanceForConsumer returns the provider instance connected to the given consumer resource
+func (d *DockerComposeConfigProvider) GetInstanceForConsumer(resourceName string) (*BlockInstanceDetails, error) {
+	envVar := InstanceForConsumerEnvVar(resourceName)
+	if value, exists := d.LookupEnv(envVar); exists {
+		details := &BlockInstanceDetails{}
+		if err := json.Unmarshal([]byte(value), details); err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return details, nil
+	}
+
+	if details, exists := lookupKey(d.env.Consumers, resourceName); exists && details != nil {
+		return copyBlockInstanceDetails(details), nil
+	}
+	return nil, &NotFoundError{Kind: KindConsumer, Name: resourceName}
+}
+
+// GetInstanceOperator returns the operator details for the given instance ID
+func (d *DockerComposeConfigProvider) GetInstanceOperator(instanceId string) (*InstanceOperator, error) {
+	envVar := InstanceOperatorEnvVar(instanceId)
+	if value, exists := d.LookupEnv(envVar); exists {
+		operator := &InstanceOperator{}
+		if err := json.Unmarshal([]byte(value), operator); err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return operator, nil
+	}
+
+	if operator, exists := lookupKey(d.env.Operators, instanceId); exists && operator != nil {
+		return copyInstanceOperator(operator), nil
+	}
+	return nil, &NotFoundError{Kind: KindInstanceOperator, Name: instanceId}
+}
+
+// GetInstancesForProvider returns the consumer instances connected to the given provider resource.
+// An unconnected resource returns an empty list.
+func (d *DockerComposeConfigProvider) GetInstancesForProvider(resourceName string) ([]*BlockInstanceDetails, error) {
+	envVar := InstancesForProviderEnvVar(resourceName)
+	if value, exists := d.LookupEnv(envVar); exists {
+		instances := make([]*BlockInstanceDetails, 0)
+		if err := json.Unmarshal([]byte(value), &instances); err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return instances, nil
+	}
+
+	instances, _ := lookupKey(d.env.Providers, resourceName)
+	return copyInstances(instances), nil
+}
+
+// conventionPort returns the port every block listens on for the port type unless configured otherwise
+func conventionPort(portType string) string {
+	if port, exists := dockerDefaultPorts[strings.ToLower(portType)]; exists {
+		return port
+	}
+	return dockerDefaultPort
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerComposeConfigProvider(t *testing.T) {
+	env, err := LoadDockerComposeEnv("../testdata/kapeta-env.json")
+	require.NoError(t, err)
+
+	provider := NewDockerComposeConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{}, env)
+
+	assert.Equal(t, "docker", provider.GetProviderId())
+	assert.Equal(t, "kapeta://kapeta/sample-plan:local", provider.GetSystemId())
+	assert.Equal(t, "messages-service", provider.GetInstanceId())
+	assert.Equal(t, "hello", provider.Get("greeting"))
+	assert.Equal(t, "default", provider.GetOrDefault("unknown", "default"))
+
+	port, err := provider.GetServerPort("")
+	assert.NoError(t, err)
+	assert.Equal(t, "8080", port)
+
+	host, err := provider.GetServerHost()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0", host)
+
+	address, err := provider.GetServiceAddress("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://users-service:8081/", address, "the compose service and its port are taken from the env file")
+
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, []Endpoint{{Address: "http://users-service:8081/"}}, endpoints)
+
+	address, err = provider.GetServiceAddress("notifications", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://notifications-service:80/", address, "the ports of this block don't apply to other services")
+
+	address, err = provider.GetServiceAddress("notifications", "grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, "notifications-service:50051", address)
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mongo", info.Host)
+		assert.Equal(t, "27017", info.Port.String())
+		assert.Equal(t, "secret", info.Credentials["password"])
+
+		info.Host = "changed"
+		info, _ = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+		assert.Equal(t, "mongo", info.Host, "the resource info in the env file must not be changed by the caller")
+	}
+
+	_, err = provider.GetResourceInfo("kapeta/resource-type-postgresql", "postgres", "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	host, err = provider.GetInstanceHost("users-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "users-service", host)
+
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 27017, operator.Ports["mongodb"].Port)
+	}
+
+	details, err := provider.GetInstanceForConsumer("users")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "users-1", details.InstanceId)
+	}
+
+	instances, err := provider.GetInstancesForProvider("messages")
+	if assert.NoError(t, err) && assert.Len(t, instances, 1) {
+		assert.Equal(t, "gateway", instances[0].InstanceId)
+	}
+}
+
+func TestDockerComposeConventions(t *testing.T) {
+	provider := NewDockerComposeConfigProvider("kapeta/messages:local", "system-id", "instance-id", map[string]interface{}{}, nil)
+
+	assert.Equal(t, "system-id", provider.GetSystemId())
+
+	port, err := provider.GetServerPort("rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "80", port)
+
+	port, err = provider.GetServerPort("grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, "50051", port)
+
+	_, err = provider.GetServiceAddress("users", "rest")
+	assert.ErrorIs(t, err, ErrNotFound, "only services listed in the env file are known")
+
+	_, err = provider.GetInstanceHost("a1b2c3-instance")
+	assert.ErrorIs(t, err, ErrNotFound, "only instance hosts listed in the env file are known")
+
+	_, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetInstanceForConsumer("users")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetInstanceOperator("users")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	instances, err := provider.GetInstancesForProvider("users")
+	assert.NoError(t, err)
+	assert.Empty(t, instances)
+
+	assert.Empty(t, provider.GetConfiguration())
+}
+
+func TestDockerComposeEnvOverrides(t *testing.T) {
+	t.Setenv("KAPETA_INSTANCE_CONFIG", `{"greeting":"hi"}`)
+	t.Setenv("KAPETA_PROVIDER_PORT_REST", "9000")
+	t.Setenv("KAPETA_PROVIDER_HOST", "127.0.0.1")
+	t.Setenv("KAPETA_CONSUMER_SERVICE_USERS_REST", "http://example.com/")
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB", `{"host":"mongo.example.com","port":"27018"}`)
+	t.Setenv("KAPETA_BLOCK_HOSTS", `{"users-1":"users.example.com"}`)
+
+	env, err := LoadDockerComposeEnv("../testdata/kapeta-env.json")
+	require.NoError(t, err)
+	provider := NewDockerComposeConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{}, env)
+
+	assert.Equal(t, "hi", provider.Get("greeting"))
+
+	port, _ := provider.GetServerPort("rest")
+	assert.Equal(t, "9000", port)
+
+	host, _ := provider.GetServerHost()
+	assert.Equal(t, "127.0.0.1", host)
+
+	address, _ := provider.GetServiceAddress("users", "rest")
+	assert.Equal(t, "http://example.com/", address)
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mongo.example.com", info.Host)
+	}
+
+	host, _ = provider.GetInstanceHost("users-1")
+	assert.Equal(t, "users.example.com", host)
+
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB", "invalid-json")
+	_, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.EqualError(t, err, "invalid JSON in environment variable: KAPETA_CONSUMER_RESOURCE_MESSAGES_MONGODB")
+}
+
+func TestLoadDockerComposeEnv(t *testing.T) {
+	_, err := LoadDockerComposeEnv("../testdata/missing.json")
+	assert.ErrorContains(t, err, "error reading docker env file")
+
+	_, err = LoadDockerComposeEnv("../testdata/static.yml")
+	assert.ErrorContains(t, err, "error parsing docker env file")
+}
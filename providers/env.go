@@ -0,0 +1,61 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"strings"
+)
+
+// Environment variables read by the Kubernetes provider that do not depend on a resource name
+const (
+	EnvProviderHost   = "KAPETA_PROVIDER_HOST"
+	EnvInstanceConfig = "KAPETA_INSTANCE_CONFIG"
+	EnvBlockHosts     = "KAPETA_BLOCK_HOSTS"
+)
+
+func toEnvName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ',', '-':
+			return '_'
+		default:
+			return r
+		}
+	}, name)))
+}
+
+// ServerPortEnvVar returns the environment variable holding the port to listen on for the port type
+func ServerPortEnvVar(portType string) string {
+	return "KAPETA_PROVIDER_PORT_" + toEnvName(portType)
+}
+
+// ServiceEnvVar returns the environment variable holding the address of a consumed service
+func ServiceEnvVar(resourceName, portType string) string {
+	return "KAPETA_CONSUMER_SERVICE_" + toEnvName(resourceName) + "_" + toEnvName(portType)
+}
+
+// ServiceEndpointsEnvVar returns the environment variable holding the JSON list of endpoints of a consumed service
+func ServiceEndpointsEnvVar(resourceName, portType string) string {
+	return "KAPETA_CONSUMER_ENDPOINTS_" + toEnvName(resourceName) + "_" + toEnvName(portType)
+}
+
+// ResourceEnvVar returns the environment variable holding the JSON encoded ResourceInfo of a consumed resource
+func ResourceEnvVar(resourceName, portType string) string {
+	return "KAPETA_CONSUMER_RESOURCE_" + toEnvName(resourceName) + "_" + toEnvName(portType)
+}
+
+// InstanceForConsumerEnvVar returns the environment variable holding the JSON encoded instance a consumer is connected to
+func InstanceForConsumerEnvVar(resourceName string) string {
+	return "KAPETA_INSTANCE_FOR_CONSUMER_" + toEnvName(resourceName)
+}
+
+// InstanceOperatorEnvVar returns the environment variable holding the JSON encoded operator of an instance
+func InstanceOperatorEnvVar(instanceID string) string {
+	return "KAPETA_INSTANCE_OPERATOR_" + toEnvName(instanceID)
+}
+
+// InstancesForProviderEnvVar returns the environment variable holding the JSON list of instances connected to a provider
+func InstancesForProviderEnvVar(resourceName string) string {
+	return "KAPETA_INSTANCES_FOR_PROVIDER_" + toEnvName(resourceName)
+}
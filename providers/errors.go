@@ -0,0 +1,50 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is matched by every NotFoundError, so callers can use errors.Is(err, ErrNotFound)
+var ErrNotFound = errors.New("not found")
+
+// Kinds of lookups that can fail with a NotFoundError
+const (
+	KindServerPort       = "server port"
+	KindService          = "service"
+	KindResource         = "resource"
+	KindInstanceHost     = "instance host"
+	KindInstanceOperator = "instance operator"
+	KindConsumer         = "consumer"
+	KindBlockInstance    = "block instance"
+	KindAsset            = "asset"
+	KindServerTLS        = "server TLS"
+	KindClientTLS        = "client TLS"
+	KindPlan             = "plan"
+)
+
+// NotFoundError is returned when a lookup is not configured, as opposed to failing to reach the configuration source.
+type NotFoundError struct {
+	Kind string
+	Name string
+	// Detail optionally describes where the value was looked up, e.g. the missing environment variable
+	Detail string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%s not found: %s: %s", e.Kind, e.Name, e.Detail)
+	}
+	return fmt.Sprintf("%s not found: %s", e.Kind, e.Name)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func missingEnvVar(kind, name, envVar string) *NotFoundError {
+	return &NotFoundError{Kind: kind, Name: name, Detail: "missing environment variable " + envVar}
+}
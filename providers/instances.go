@@ -0,0 +1,108 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"strings"
+
+	"github.com/kapetacom/sdk-go-config/ref"
+)
+
+// InstanceFilter selects block instances, see FilterInstances
+type InstanceFilter func(instance *BlockInstanceDetails) bool
+
+// FilterInstances returns the instances selected by every filter, in the order they were given
+func FilterInstances(instances []*BlockInstanceDetails, filters ...InstanceFilter) []*BlockInstanceDetails {
+	result := make([]*BlockInstanceDetails, 0, len(instances))
+	for _, instance := range instances {
+		selected := true
+		for _, filter := range filters {
+			if !filter(instance) {
+				selected = false
+				break
+			}
+		}
+		if selected {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+// ByBlockKind selects instances of blocks of the kind, such as kapeta/block-type-gateway-http, whatever the version
+func ByBlockKind(kind string) InstanceFilter {
+	kind = blockKindName(kind)
+	return func(instance *BlockInstanceDetails) bool {
+		return strings.EqualFold(BlockKind(instance), kind)
+	}
+}
+
+// ByPortType selects instances with a connection on the port type
+func ByPortType(portType string) InstanceFilter {
+	return func(instance *BlockInstanceDetails) bool {
+		for _, connectionPortType := range connectionPortTypes(instance) {
+			if strings.EqualFold(connectionPortType, portType) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// GroupByBlockKind groups the instances by the kind of their block, see BlockKind.
+// The instances of every group are in the order they were given.
+func GroupByBlockKind(instances []*BlockInstanceDetails) map[string][]*BlockInstanceDetails {
+	groups := make(map[string][]*BlockInstanceDetails)
+	for _, instance := range instances {
+		kind := BlockKind(instance)
+		groups[kind] = append(groups[kind], instance)
+	}
+	return groups
+}
+
+// GroupByPortType groups the instances by the lowercase port types of their connections. An instance connected on
+// several port types is in every group, and connections without a port type are not grouped.
+// The instances of every group are in the order they were given.
+func GroupByPortType(instances []*BlockInstanceDetails) map[string][]*BlockInstanceDetails {
+	groups := make(map[string][]*BlockInstanceDetails)
+	for _, instance := range instances {
+		for _, portType := range connectionPortTypes(instance) {
+			groups[portType] = append(groups[portType], instance)
+		}
+	}
+	return groups
+}
+
+// BlockKind returns the kind of the block of the instance without the kapeta:// prefix and version,
+// e.g. kapeta/block-type-service, or an empty string if the block is unknown
+func BlockKind(instance *BlockInstanceDetails) string {
+	if instance.Block == nil {
+		return ""
+	}
+	return blockKindName(instance.Block.Kind)
+}
+
+func blockKindName(kind string) string {
+	if parsed, err := ref.Parse(kind); err == nil {
+		return parsed.FullName()
+	}
+	return kind
+}
+
+// connectionPortTypes returns the distinct lowercase port types of the connections of the instance, in order
+func connectionPortTypes(instance *BlockInstanceDetails) []string {
+	seen := map[string]bool{}
+	portTypes := make([]string, 0)
+	for _, connection := range instance.Connections {
+		if connection == nil || connection.Port == nil || connection.Port.Type == "" {
+			continue
+		}
+		portType := strings.ToLower(connection.Port.Type)
+		if !seen[portType] {
+			seen[portType] = true
+			portTypes = append(portTypes, portType)
+		}
+	}
+	return portTypes
+}
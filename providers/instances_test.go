@@ -0,0 +1,70 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func testInstance(id, kind string, portTypes ...string) *BlockInstanceDetails {
+	instance := &BlockInstanceDetails{InstanceId: id, Connections: []*model.Connection{}}
+	if kind != "" {
+		instance.Block = &model.Kind{Kind: kind}
+	}
+	for _, portType := range portTypes {
+		instance.Connections = append(instance.Connections, &model.Connection{
+			Consumer: model.Endpoint{BlockId: id, ResourceName: portType + "-client"},
+			Port:     &model.Port{Type: portType},
+		})
+	}
+	return instance
+}
+
+func instanceIDs(instances []*BlockInstanceDetails) []string {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, instance.InstanceId)
+	}
+	return ids
+}
+
+func TestFilterInstances(t *testing.T) {
+	instances := []*BlockInstanceDetails{
+		testInstance("gateway", "kapeta://kapeta/block-type-gateway-http:0.0.1", "http"),
+		testInstance("users", "kapeta://kapeta/block-type-service:0.0.2", "rest", "grpc"),
+		testInstance("messages", "kapeta/block-type-service", "REST"),
+		testInstance("unknown", ""),
+	}
+
+	assert.Equal(t, []string{"users", "messages"}, instanceIDs(FilterInstances(instances, ByBlockKind("kapeta://kapeta/block-type-service:1.0.0"))))
+	assert.Equal(t, []string{"gateway"}, instanceIDs(FilterInstances(instances, ByBlockKind("Kapeta/Block-Type-Gateway-HTTP"))))
+	assert.Equal(t, []string{"users", "messages"}, instanceIDs(FilterInstances(instances, ByPortType("rest"))))
+	assert.Equal(t, []string{"users"}, instanceIDs(FilterInstances(instances, ByPortType("rest"), ByPortType("grpc"))))
+	assert.Equal(t, []string{"gateway", "users", "messages", "unknown"}, instanceIDs(FilterInstances(instances)))
+	assert.NotNil(t, FilterInstances(nil, ByPortType("rest")))
+}
+
+func TestGroupInstances(t *testing.T) {
+	instances := []*BlockInstanceDetails{
+		testInstance("users", "kapeta://kapeta/block-type-service:0.0.2", "rest", "grpc"),
+		testInstance("gateway", "kapeta://kapeta/block-type-gateway-http:0.0.1", "http"),
+		testInstance("messages", "kapeta/block-type-service", "REST", "rest"),
+		testInstance("unknown", ""),
+	}
+
+	byKind := GroupByBlockKind(instances)
+	assert.Len(t, byKind, 3)
+	assert.Equal(t, []string{"users", "messages"}, instanceIDs(byKind["kapeta/block-type-service"]))
+	assert.Equal(t, []string{"gateway"}, instanceIDs(byKind["kapeta/block-type-gateway-http"]))
+	assert.Equal(t, []string{"unknown"}, instanceIDs(byKind[""]))
+
+	byPortType := GroupByPortType(instances)
+	assert.Len(t, byPortType, 3)
+	assert.Equal(t, []string{"users", "messages"}, instanceIDs(byPortType["rest"]), "an instance is grouped once per port type")
+	assert.Equal(t, []string{"users"}, instanceIDs(byPortType["grpc"]))
+	assert.Equal(t, []string{"gateway"}, instanceIDs(byPortType["http"]))
+}
@@ -0,0 +1,17 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"net/http"
+)
+
+// Instrumenter observes the lookups of a provider and its requests to the local cluster service.
+// See the instrument package for an OpenTelemetry implementation.
+type Instrumenter interface {
+	// Wrap returns a provider recording every lookup made through it
+	Wrap(provider ConfigProvider) ConfigProvider
+	// Transport returns a transport recording every request made with the base transport
+	Transport(base http.RoundTripper) http.RoundTripper
+}
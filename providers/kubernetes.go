@@ -5,9 +5,7 @@ package providers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"strings"
 	"sync"
 
 	cfg "github.com/kapetacom/sdk-go-config/config"
@@ -15,17 +13,6 @@ import (
 
 const DEFAULT_SERVER_PORT_TYPE = "rest"
 
-func toEnvName(name string) string {
-	return strings.ToUpper(strings.TrimSpace(strings.Map(func(r rune) rune {
-		switch r {
-		case '.', ',', '-':
-			return '_'
-		default:
-			return r
-		}
-	}, name)))
-}
-
 // KubernetesConfigProvider implements the ConfigProvider interface
 type KubernetesConfigProvider struct {
 	AbstractConfigProvider
@@ -36,7 +23,8 @@ type KubernetesConfigProvider struct {
 }
 
 // NewKubernetesConfigProvider creates a new instance of KubernetesConfigProvider
-func NewKubernetesConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}) ConfigProvider {
+func NewKubernetesConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}, opts ...Option) ConfigProvider {
+	o := newOptions(opts)
 	envConfig := cfg.ReadConfigFile()
 	return &KubernetesConfigProvider{
 		AbstractConfigProvider: AbstractConfigProvider{
@@ -45,6 +33,7 @@ func NewKubernetesConfigProvider(blockRef, systemID, instanceID string, blockDef
 			InstanceID:               instanceID,
 			BlockDefinition:          blockDefinition,
 			EnvironmentConfiguration: envConfig,
+			logger:                   o.logger,
 		},
 		configuration: nil,
 	}
@@ -56,7 +45,7 @@ func (k *KubernetesConfigProvider) GetServerPort(portType string) (string, error
 		portType = DEFAULT_SERVER_PORT_TYPE
 	}
 
-	envVar := fmt.Sprintf("KAPETA_PROVIDER_PORT_%s", toEnvName(portType))
+	envVar := ServerPortEnvVar(portType)
 	if value, exists := k.LookupEnv(envVar); exists {
 		return value, nil
 	}
@@ -66,8 +55,7 @@ func (k *KubernetesConfigProvider) GetServerPort(portType string) (string, error
 
 // GetServerHost returns the host for the current process
 func (k *KubernetesConfigProvider) GetServerHost() (string, error) {
-	envVar := "KAPETA_PROVIDER_HOST"
-	if value, exists := k.LookupEnv(envVar); exists {
+	if value, exists := k.LookupEnv(EnvProviderHost); exists {
 		return value, nil
 	}
 
@@ -77,17 +65,37 @@ func (k *KubernetesConfigProvider) GetServerHost() (string, error) {
 
 // GetServiceAddress returns the service address for the given resource name and port type
 func (k *KubernetesConfigProvider) GetServiceAddress(resourceName, portType string) (string, error) {
-	envVar := fmt.Sprintf("KAPETA_CONSUMER_SERVICE_%s_%s", toEnvName(resourceName), toEnvName(portType))
+	envVar := ServiceEnvVar(resourceName, portType)
 	if value, exists := k.LookupEnv(envVar); exists {
 		return value, nil
 	}
 
-	return "", fmt.Errorf("missing environment variable for internal resource: %s", envVar)
+	return "", missingEnvVar(KindService, resourceName+"/"+portType, envVar)
+}
+
+// GetServiceEndpoints returns all endpoints for the given resource name and port type.
+// The endpoints are read as a JSON list, falling back to the single service address if no list is configured.
+func (k *KubernetesConfigProvider) GetServiceEndpoints(resourceName, portType string) ([]Endpoint, error) {
+	envVar := ServiceEndpointsEnvVar(resourceName, portType)
+	if value, exists := k.LookupEnv(envVar); exists {
+		endpoints := make([]Endpoint, 0)
+		err := json.Unmarshal([]byte(value), &endpoints)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON in environment variable: %s", envVar)
+		}
+		return endpoints, nil
+	}
+
+	address, err := k.GetServiceAddress(resourceName, portType)
+	if err != nil {
+		return nil, err
+	}
+	return []Endpoint{{Address: address}}, nil
 }
 
 // GetResourceInfo returns the resource info for the given resource type, port type, and resource name
 func (k *KubernetesConfigProvider) GetResourceInfo(resourceType, portType, resourceName string) (*ResourceInfo, error) {
-	envVar := fmt.Sprintf("KAPETA_CONSUMER_RESOURCE_%s_%s", toEnvName(resourceName), toEnvName(portType))
+	envVar := ResourceEnvVar(resourceName, portType)
 	if value, exists := k.LookupEnv(envVar); exists {
 		var resourceInfo ResourceInfo
 		err := json.Unmarshal([]byte(value), &resourceInfo)
@@ -97,7 +105,7 @@ func (k *KubernetesConfigProvider) GetResourceInfo(resourceType, portType, resou
 		return &resourceInfo, nil
 	}
 
-	return nil, fmt.Errorf("missing environment variable for operator resource: %s", envVar)
+	return nil, missingEnvVar(KindResource, resourceName+"/"+portType, envVar)
 }
 
 // GetProviderId returns the identifier for the config provider
@@ -105,33 +113,43 @@ func (k *KubernetesConfigProvider) GetProviderId() string {
 	return "kubernetes"
 }
 
+// loadConfiguration reads the configuration from the environment variable the first time it is needed.
+// Must be called with muConfig held.
+func (k *KubernetesConfigProvider) loadConfiguration() {
+	if k.configuration != nil {
+		return
+	}
+
+	envVar := EnvInstanceConfig
+	value, exists := k.LookupEnv(envVar)
+	if !exists {
+		// Only warn once, the environment does not change while running
+		k.GetLogger().Warn("missing environment variable for instance configuration", "envVar", envVar, "blockRef", k.BlockRef)
+		k.configuration = make(map[string]interface{})
+		return
+	}
+
+	err := json.Unmarshal([]byte(value), &k.configuration)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid JSON in environment variable: %s", envVar))
+	}
+	if k.configuration == nil {
+		k.configuration = make(map[string]interface{})
+	}
+}
+
 // getConfiguration is a private method to get the configuration value from the environment variable
 func (k *KubernetesConfigProvider) getConfiguration(path string, defaultValue interface{}) interface{} {
 	k.muConfig.Lock()
 	defer k.muConfig.Unlock()
-	if k.configuration == nil {
-		envVar := "KAPETA_INSTANCE_CONFIG"
-		if value, exists := k.LookupEnv(envVar); exists {
-			err := json.Unmarshal([]byte(value), &k.configuration)
-			if err != nil {
-				panic(fmt.Sprintf("Invalid JSON in environment variable: %s", envVar))
-			}
-		} else {
-			fmt.Printf("Missing environment variable for instance configuration: %s\n", envVar)
-			return defaultValue
-		}
-
-		if k.configuration == nil {
-			k.configuration = make(map[string]interface{})
-		}
-	}
+	k.loadConfiguration()
 
 	result := k.configuration[path]
 	if result == nil {
 		return defaultValue
 	}
 
-	return result
+	return copyValue(result)
 }
 
 // Get is an implementation of the ConfigProvider interface to get the configuration value from the object path
@@ -144,18 +162,26 @@ func (k *KubernetesConfigProvider) GetOrDefault(path string, defaultValue interf
 	return k.getConfiguration(path, defaultValue)
 }
 
+// GetConfiguration returns a copy of the whole instance configuration
+func (k *KubernetesConfigProvider) GetConfiguration() map[string]interface{} {
+	k.muConfig.Lock()
+	defer k.muConfig.Unlock()
+	k.loadConfiguration()
+	return copyConfiguration(k.configuration)
+}
+
 // GetInstanceHost returns the hostname for the given instance ID
 func (k *KubernetesConfigProvider) GetInstanceHost(instanceID string) (string, error) {
 	k.muHosts.Lock()
 	defer k.muHosts.Unlock()
 	if k.instanceHosts == nil {
-		if blockHosts, exists := k.LookupEnv("KAPETA_BLOCK_HOSTS"); exists {
+		if blockHosts, exists := k.LookupEnv(EnvBlockHosts); exists {
 			err := json.Unmarshal([]byte(blockHosts), &k.instanceHosts)
 			if err != nil {
-				panic("Invalid JSON in environment variable: KAPETA_BLOCK_HOSTS")
+				panic("Invalid JSON in environment variable: " + EnvBlockHosts)
 			}
 		} else {
-			return "", errors.New("environment variable KAPETA_BLOCK_HOSTS not found. Could not resolve instance host")
+			return "", missingEnvVar(KindInstanceHost, instanceID, EnvBlockHosts)
 		}
 	}
 
@@ -163,11 +189,11 @@ func (k *KubernetesConfigProvider) GetInstanceHost(instanceID string) (string, e
 		return host, nil
 	}
 
-	return "", fmt.Errorf("unknown instance id when resolving host: %s", instanceID)
+	return "", &NotFoundError{Kind: KindInstanceHost, Name: instanceID}
 }
 
 func (k *KubernetesConfigProvider) GetInstanceForConsumer(resourceName string) (*BlockInstanceDetails, error) {
-	envVar := fmt.Sprintf("KAPETA_INSTANCE_FOR_CONSUMER_%s", toEnvName(resourceName))
+	envVar := InstanceForConsumerEnvVar(resourceName)
 	if value, exists := k.LookupEnv(envVar); exists {
 		blockDetails := &BlockInstanceDetails{}
 		err := json.Unmarshal([]byte(value), blockDetails)
@@ -177,11 +203,11 @@ func (k *KubernetesConfigProvider) GetInstanceForConsumer(resourceName string) (
 		return blockDetails, nil
 	}
 
-	return nil, fmt.Errorf("missing environment variable for instance consumer: %s", envVar)
+	return nil, missingEnvVar(KindConsumer, resourceName, envVar)
 }
 
 func (k *KubernetesConfigProvider) GetInstanceOperator(instanceId string) (*InstanceOperator, error) {
-	envVar := fmt.Sprintf("KAPETA_INSTANCE_OPERATOR_%s", toEnvName(instanceId))
+	envVar := InstanceOperatorEnvVar(instanceId)
 	if value, exists := k.LookupEnv(envVar); exists {
 		instanceOperator := &InstanceOperator{}
 		err := json.Unmarshal([]byte(value), instanceOperator)
@@ -191,11 +217,12 @@ func (k *KubernetesConfigProvider) GetInstanceOperator(instanceId string) (*Inst
 		return instanceOperator, nil
 	}
 
-	return nil, fmt.Errorf("missing environment variable for instance consumer: %s", envVar)
+	return nil, missingEnvVar(KindInstanceOperator, instanceId, envVar)
 }
 
+// GetInstancesForProvider returns the consumer instances connected to the given provider resource
 func (k *KubernetesConfigProvider) GetInstancesForProvider(resourceName string) ([]*BlockInstanceDetails, error) {
-	envVar := fmt.Sprintf("KAPETA_INSTANCES_FOR_PROVIDER_%s", toEnvName(resourceName))
+	envVar := InstancesForProviderEnvVar(resourceName)
 	if value, exists := k.LookupEnv(envVar); exists {
 		instanceOperators := make([]*BlockInstanceDetails, 0)
 		err := json.Unmarshal([]byte(value), &instanceOperators)
@@ -205,5 +232,6 @@ func (k *KubernetesConfigProvider) GetInstancesForProvider(resourceName string)
 		return instanceOperators, nil
 	}
 
-	return nil, fmt.Errorf("missing environment variable for instance consumer: %s", envVar)
+	// A provider without any connected consumers has no environment variable
+	return make([]*BlockInstanceDetails, 0), nil
 }
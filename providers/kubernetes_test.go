@@ -4,8 +4,11 @@
 package providers
 
 import (
+	"bytes"
 	"encoding/json"
+	"log/slog"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,12 +16,12 @@ import (
 
 func TestKubernetesConfigProvider(t *testing.T) {
 	// Mock environment variables for testing
-	os.Setenv("KAPETA_PROVIDER_PORT_REST", "8080")
-	os.Setenv("KAPETA_PROVIDER_HOST", "localhost")
-	os.Setenv("KAPETA_CONSUMER_SERVICE_TEST_SERVICE_REST", "http://test-service:8080")
-	os.Setenv("KAPETA_CONSUMER_RESOURCE_TEST_RESOURCE_REST", `{"host": "test-resource", "port": "9090", "type": "test", "protocol": "http"}`)
-	os.Setenv("KAPETA_INSTANCE_CONFIG", `{"exampleField": "exampleValue"}`)
-	os.Setenv("KAPETA_BLOCK_HOSTS", `{"test-instance": "test-host"}`)
+	t.Setenv("KAPETA_PROVIDER_PORT_REST", "8080")
+	t.Setenv("KAPETA_PROVIDER_HOST", "localhost")
+	t.Setenv("KAPETA_CONSUMER_SERVICE_TEST_SERVICE_REST", "http://test-service:8080")
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_TEST_RESOURCE_REST", `{"host": "test-resource", "port": "9090", "type": "test", "protocol": "http"}`)
+	t.Setenv("KAPETA_INSTANCE_CONFIG", `{"exampleField": "exampleValue"}`)
+	t.Setenv("KAPETA_BLOCK_HOSTS", `{"test-instance": "test-host"}`)
 
 	// Create an instance of KubernetesConfigProvider
 	configProvider := NewKubernetesConfigProvider("blockRef", "systemID", "instanceID", map[string]interface{}{})
@@ -74,8 +77,8 @@ func TestNewKubernetesConfigProvider(t *testing.T) {
 }
 
 func TestK8sGetServerPort(t *testing.T) {
-	os.Setenv("KAPETA_PROVIDER_PORT_REST", "8080")
-	os.Setenv("KAPETA_PROVIDER_PORT_GRPC", "8081")
+	t.Setenv("KAPETA_PROVIDER_PORT_REST", "8080")
+	t.Setenv("KAPETA_PROVIDER_PORT_GRPC", "8081")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -95,7 +98,7 @@ func TestK8sGetServerPort(t *testing.T) {
 }
 
 func TestK8sGetServerHost(t *testing.T) {
-	os.Setenv("KAPETA_PROVIDER_HOST", "0.0.0.0")
+	t.Setenv("KAPETA_PROVIDER_HOST", "0.0.0.0")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -107,8 +110,8 @@ func TestK8sGetServerHost(t *testing.T) {
 }
 
 func TestK8sGetServiceAddress(t *testing.T) {
-	os.Setenv("KAPETA_CONSUMER_SERVICE_FOO_REST", "10.0.0.1:8080")
-	os.Setenv("KAPETA_CONSUMER_SERVICE_BAR_GRPC", "10.0.0.2:8081")
+	t.Setenv("KAPETA_CONSUMER_SERVICE_FOO_REST", "10.0.0.1:8080")
+	t.Setenv("KAPETA_CONSUMER_SERVICE_BAR_GRPC", "10.0.0.2:8081")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -124,12 +127,13 @@ func TestK8sGetServiceAddress(t *testing.T) {
 
 	_, err = provider.GetServiceAddress("baz", "rest")
 	assert.Error(t, err)
-	assert.Equal(t, "missing environment variable for internal resource: KAPETA_CONSUMER_SERVICE_BAZ_REST", err.Error())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "service not found: baz/rest: missing environment variable KAPETA_CONSUMER_SERVICE_BAZ_REST", err.Error())
 }
 
 func TestK8sGetResourceInfo(t *testing.T) {
-	os.Setenv("KAPETA_CONSUMER_RESOURCE_FOO_REST", "{\"host\": \"10.0.0.1\", \"port\": \"8080\"}")
-	os.Setenv("KAPETA_CONSUMER_RESOURCE_BAR_GRPC", "{\"host\": \"10.0.0.2\", \"port\": 8081}")
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_FOO_REST", "{\"host\": \"10.0.0.1\", \"port\": \"8080\"}")
+	t.Setenv("KAPETA_CONSUMER_RESOURCE_BAR_GRPC", "{\"host\": \"10.0.0.2\", \"port\": 8081}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -147,11 +151,12 @@ func TestK8sGetResourceInfo(t *testing.T) {
 
 	_, err = provider.GetResourceInfo("foo", "rest", "baz")
 	assert.Error(t, err)
-	assert.Equal(t, "missing environment variable for operator resource: KAPETA_CONSUMER_RESOURCE_BAZ_REST", err.Error())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "resource not found: baz/rest: missing environment variable KAPETA_CONSUMER_RESOURCE_BAZ_REST", err.Error())
 }
 
 func TestK8sGet(t *testing.T) {
-	os.Setenv("KAPETA_INSTANCE_CONFIG", "{\"foo\": \"bar\"}")
+	t.Setenv("KAPETA_INSTANCE_CONFIG", "{\"foo\": \"bar\"}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -165,7 +170,7 @@ func TestK8sGet(t *testing.T) {
 }
 
 func TestK8sGetOrDefault(t *testing.T) {
-	os.Setenv("KAPETA_INSTANCE_CONFIG", "{\"foo\": \"bar\"}")
+	t.Setenv("KAPETA_INSTANCE_CONFIG", "{\"foo\": \"bar\"}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -179,7 +184,7 @@ func TestK8sGetOrDefault(t *testing.T) {
 }
 
 func TestK8sGetInstanceHost(t *testing.T) {
-	os.Setenv("KAPETA_BLOCK_HOSTS", "{\"instance-id\": \"10.0.0.1\"}")
+	t.Setenv("KAPETA_BLOCK_HOSTS", "{\"instance-id\": \"10.0.0.1\"}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "kubernetes",
@@ -191,13 +196,15 @@ func TestK8sGetInstanceHost(t *testing.T) {
 
 	_, err = provider.GetInstanceHost("unknown-instance-id")
 	assert.Error(t, err)
-	assert.Equal(t, "unknown instance id when resolving host: unknown-instance-id", err.Error())
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, KindInstanceHost, notFound.Kind)
+	assert.Equal(t, "instance host not found: unknown-instance-id", err.Error())
 }
 
 func TestK8sGetInstanceForConsumer(t *testing.T) {
 	envVar := "KAPETA_INSTANCE_FOR_CONSUMER_TESTRESOURCE"
-	os.Setenv(envVar, "{\"instanceId\": \"instance-id\", \"block\": {\"ref\": \"block-ref\"}, \"connections\": []}")
-	defer os.Unsetenv(envVar)
+	t.Setenv(envVar, "{\"instanceId\": \"instance-id\", \"block\": {\"ref\": \"block-ref\"}, \"connections\": []}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{"type": "kubernetes"})
 
@@ -208,20 +215,19 @@ func TestK8sGetInstanceForConsumer(t *testing.T) {
 	assert.Equal(t, "instance-id", blockDetails.InstanceId)
 
 	// Test with invalid JSON in environment variable
-	os.Setenv(envVar, "invalid-json")
+	t.Setenv(envVar, "invalid-json")
 	_, err = provider.GetInstanceForConsumer("TestResource")
 	assert.Error(t, err)
 
 	// Test with missing environment variable
 	os.Unsetenv(envVar)
 	_, err = provider.GetInstanceForConsumer("TestResource")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 func TestK8sGetInstanceOperator(t *testing.T) {
 	envVar := "KAPETA_INSTANCE_OPERATOR_12E0023C_0814_402F_9C62_25A7C1FCD906"
-	os.Setenv(envVar, "{\"hostname\": \"test-host\", \"ports\": {\"http\": {\"port\": 80}}}")
-	defer os.Unsetenv(envVar)
+	t.Setenv(envVar, "{\"hostname\": \"test-host\", \"ports\": {\"http\": {\"port\": 80}}}")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{"type": "kubernetes"})
 
@@ -233,20 +239,19 @@ func TestK8sGetInstanceOperator(t *testing.T) {
 	assert.Equal(t, 80, instanceOperator.Ports["http"].Port)
 
 	// Test with invalid JSON
-	os.Setenv(envVar, "invalid-json")
+	t.Setenv(envVar, "invalid-json")
 	_, err = provider.GetInstanceOperator("instanceid")
 	assert.Error(t, err)
 
 	// Test with missing environment variable
 	os.Unsetenv(envVar)
 	_, err = provider.GetInstanceOperator("instanceid")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 func TestK8sGetInstancesForProvider(t *testing.T) {
 	envVar := "KAPETA_INSTANCES_FOR_PROVIDER_TESTRESOURCE"
-	os.Setenv(envVar, "[{\"instanceId\": \"instance-id-1\", \"block\": {\"ref\": \"block-ref-1\"}}, {\"instanceId\": \"instance-id-2\", \"block\": {\"ref\": \"block-ref-2\"}}]")
-	defer os.Unsetenv(envVar)
+	t.Setenv(envVar, "[{\"instanceId\": \"instance-id-1\", \"block\": {\"ref\": \"block-ref-1\"}}, {\"instanceId\": \"instance-id-2\", \"block\": {\"ref\": \"block-ref-2\"}}]")
 
 	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{"type": "kubernetes"})
 
@@ -256,12 +261,53 @@ func TestK8sGetInstancesForProvider(t *testing.T) {
 	assert.Len(t, instances, 2)
 
 	// Test with invalid JSON
-	os.Setenv(envVar, "invalid-json")
+	t.Setenv(envVar, "invalid-json")
 	_, err = provider.GetInstancesForProvider("TestResource")
 	assert.Error(t, err)
 
-	// Test with missing environment variable
+	// Test with missing environment variable, i.e. no connected consumers
 	os.Unsetenv(envVar)
-	_, err = provider.GetInstancesForProvider("TestResource")
+	instances, err = provider.GetInstancesForProvider("TestResource")
+	assert.NoError(t, err)
+	assert.Empty(t, instances)
+}
+
+func TestK8sGetServiceEndpoints(t *testing.T) {
+	envVar := "KAPETA_CONSUMER_ENDPOINTS_USERS_REST"
+	t.Setenv(envVar, `[{"address": "10.0.0.1:8080", "instanceId": "a"}, {"address": "10.0.0.2:8080", "instanceId": "b"}]`)
+	t.Setenv("KAPETA_CONSUMER_SERVICE_ORDERS_REST", "10.0.0.3:8080")
+
+	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{"type": "kubernetes"})
+
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Address: "10.0.0.1:8080", InstanceId: "a"},
+		{Address: "10.0.0.2:8080", InstanceId: "b"},
+	}, endpoints)
+
+	// Falls back to the single service address
+	endpoints, err = provider.GetServiceEndpoints("orders", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, []Endpoint{{Address: "10.0.0.3:8080"}}, endpoints)
+
+	t.Setenv(envVar, "invalid-json")
+	_, err = provider.GetServiceEndpoints("users", "rest")
+	assert.Error(t, err)
+
+	_, err = provider.GetServiceEndpoints("unknown", "rest")
 	assert.Error(t, err)
 }
+
+func TestK8sMissingInstanceConfigWarnsOnce(t *testing.T) {
+	os.Unsetenv("KAPETA_INSTANCE_CONFIG")
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	provider := NewKubernetesConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{}, WithLogger(logger))
+
+	assert.Equal(t, "default", provider.GetOrDefault("foo", "default"))
+	assert.Nil(t, provider.Get("foo"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "missing environment variable for instance configuration"))
+	assert.Contains(t, buf.String(), "envVar=KAPETA_INSTANCE_CONFIG")
+}
@@ -6,19 +6,23 @@ package providers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/kapetacom/schemas/packages/go/model"
 
 	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/plangraph"
 )
 
 const (
@@ -27,6 +31,11 @@ const (
 	HEADER_KAPETA_SYSTEM      = "X-Kapeta-System"
 	HEADER_KAPETA_INSTANCE    = "X-Kapeta-Instance"
 	HEADER_KAPETA_ENVIRONMENT = "X-Kapeta-Environment"
+
+	// EnvLocalOffline set to LocalOfflineFallback makes the local provider fall back to cluster-service.yml
+	// when the cluster service cannot be reached
+	EnvLocalOffline      = "KAPETA_LOCAL_OFFLINE"
+	LocalOfflineFallback = "fallback"
 )
 
 type AssetWrapper[T any] struct {
@@ -39,13 +48,39 @@ type LocalConfigProvider struct {
 	mu            sync.Mutex
 	configuration map[string]interface{}
 	cfg           *cfg.ClusterConfig
-	GetPlan       func() (*model.Plan, error)
-	GetKind       func(ref string) (*model.Kind, error)
+	client        *http.Client
+	baseURL       string
+	token         string
+	stopSignals   func()
+	muStatus      sync.Mutex
+	lastContact   time.Time
+	lastError     error
+	// offlineFallback is set when KAPETA_LOCAL_OFFLINE=fallback
+	offlineFallback bool
+	offlineOnce     sync.Once
+	GetPlan         func() (*model.Plan, error)
+	GetKind         func(ref string) (*model.Kind, error)
+}
+
+// NewLocalConfigProvider creates an instance of LocalConfigProvider. It panics if the provider cannot be created,
+// see NewLocalConfigProviderE.
+func NewLocalConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}, opts ...Option) *LocalConfigProvider {
+	localProvider, err := NewLocalConfigProviderE(blockRef, systemID, instanceID, blockDefinition, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return localProvider
 }
 
-// NewLocalConfigProvider creates an instance of LocalConfigProvider
-func NewLocalConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}) *LocalConfigProvider {
-	envConfig := cfg.ReadConfigFile()
+// NewLocalConfigProviderE creates an instance of LocalConfigProvider, resolves its identity and registers it with
+// the local cluster service. It returns an error if the environment config file or cluster-service.yml cannot be
+// read, or if the cluster service cannot be reached and offline mode is not enabled.
+func NewLocalConfigProviderE(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}, opts ...Option) (*LocalConfigProvider, error) {
+	o := newOptions(opts)
+	envConfig, err := cfg.LoadConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environment config file: %w", err)
+	}
 
 	localProvider := &LocalConfigProvider{
 		AbstractConfigProvider: AbstractConfigProvider{
@@ -54,10 +89,11 @@ func NewLocalConfigProvider(blockRef, systemID, instanceID string, blockDefiniti
 			InstanceID:               instanceID,
 			BlockDefinition:          blockDefinition,
 			EnvironmentConfiguration: envConfig,
+			logger:                   o.logger,
 		},
 		configuration: make(map[string]interface{}),
-		cfg:           cfg.NewClusterConfig(),
 	}
+	localProvider.offlineFallback = localProvider.getEnvWithDefault(EnvLocalOffline, "") == LocalOfflineFallback
 
 	// These methods are properties, so we can override them in tests
 	localProvider.GetPlan = func() (*model.Plan, error) {
@@ -78,27 +114,66 @@ func NewLocalConfigProvider(blockRef, systemID, instanceID string, blockDefiniti
 		return kind.Data, nil
 	}
 
+	// The provider keeps using the config it was created with, even if cluster-service.yml is reloaded
+	clusterConfig, err := cfg.LoadClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster config: %w", err)
+	}
+	localProvider.cfg = clusterConfig
+	if err := localProvider.connect(o); err != nil {
+		return nil, fmt.Errorf("failed to configure cluster service connection: %w", err)
+	}
+	// ResolveIdentity says which step failed
 	if err := localProvider.ResolveIdentity(); err != nil {
-		panic(fmt.Errorf("failed to resolve identity: %w", err))
+		return nil, err
 	}
 	// Only relevant locally
 	if err := localProvider.RegisterInstanceWithLocalClusterService(); err != nil {
-		panic(fmt.Errorf("failed to register instance: %w", err))
+		if !localProvider.useOffline(err) {
+			return nil, fmt.Errorf("failed to register instance: %w", err)
+		}
 	}
-	return localProvider
+	return localProvider, nil
+}
+
+// connect sets up the client for the cluster service URL, CA and token
+func (l *LocalConfigProvider) connect(o *options) error {
+	connection, err := l.cfg.GetClusterServiceConnection()
+	if err != nil {
+		return err
+	}
+	baseURL, clusterTransport, err := ClusterServiceTransport(connection)
+	if err != nil {
+		return err
+	}
+
+	var transport http.RoundTripper = clusterTransport
+	if o.transport != nil {
+		transport = o.transport
+	}
+	if o.instrumenter != nil {
+		transport = o.instrumenter.Transport(transport)
+	}
+	l.client = &http.Client{Transport: transport}
+	l.baseURL = baseURL
+	l.token = connection.Token
+	return nil
 }
 
 // ResolveIdentity resolves and verifies system and instance ID
 func (l *LocalConfigProvider) ResolveIdentity() error {
-	fmt.Printf("Resolving identity for block: %s\n", l.BlockRef)
+	l.GetLogger().Debug("resolving identity", "blockRef", l.BlockRef)
 
 	url := l.getIdentityURL()
 	identity, err := l.getIdentity(url)
+	if err != nil && l.useOffline(err) {
+		identity, err = l.getOfflineIdentity()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to resolve identity: %w", err)
 	}
 
-	fmt.Printf("Identity resolved:\n - System ID: %s\n - Instance ID: %s\n", identity.SystemID, identity.InstanceID)
+	l.GetLogger().Info("identity resolved", "blockRef", l.BlockRef, "systemId", identity.SystemID, "instanceId", identity.InstanceID)
 
 	l.setIdentity(identity.SystemID, identity.InstanceID)
 
@@ -112,6 +187,9 @@ func (l *LocalConfigProvider) ResolveIdentity() error {
 // LoadConfiguration loads the configuration for the instance
 func (l *LocalConfigProvider) loadConfiguration() error {
 	configuration, err := l.getInstanceConfig()
+	if err != nil && l.useOffline(err) {
+		configuration, err = l.getOfflineInstanceConfig()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -133,6 +211,14 @@ func (l *LocalConfigProvider) GetServerPort(portType string) (string, error) {
 
 	url := l.getProviderPortURL(portType)
 	port, err := l.getString(url)
+	if errors.Is(err, ErrNotFound) {
+		return "", &NotFoundError{Kind: KindServerPort, Name: portType}
+	}
+	if err != nil && l.useOffline(err) {
+		if offlinePort, ok := l.cfg.GetClusterConfig().ServicePort(l.SystemID, l.InstanceID, portType); ok {
+			return strconv.Itoa(offlinePort), nil
+		}
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve server port for type %s: %w", portType, err)
 	}
@@ -173,11 +259,53 @@ func (l *LocalConfigProvider) RegisterInstanceWithLocalClusterService() error {
 	}
 
 	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		<-sigCh
-		exitHandler()
+		select {
+		case <-sigCh:
+			exitHandler()
+		case <-done:
+		}
 	}()
+
+	l.mu.Lock()
+	l.stopSignals = func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// DisableExitHandler stops the handler that notifies the cluster service and exits on SIGINT and SIGTERM.
+// Call it when the application shuts down gracefully itself, and call InstanceStopped once it has.
+func (l *LocalConfigProvider) DisableExitHandler() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopSignals != nil {
+		l.stopSignals()
+		l.stopSignals = nil
+	}
+}
+
+// InstanceListening notifies the cluster service that the instance is accepting connections for the port type on
+// the address. It updates the registered instance with a PATCH rather than registering it again.
+func (l *LocalConfigProvider) InstanceListening(portType, address string) error {
+	url := l.getInstanceURL()
+	body := map[string]interface{}{
+		"status":   "ready",
+		"portType": portType,
+		"address":  address,
+	}
+	response, err := l.sendRequest(http.MethodPatch, url, body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to notify instance listening: %w", err)
+	}
+	defer response.Body.Close()
+	if (response.StatusCode < 200) || (response.StatusCode > 299) {
+		return fmt.Errorf("failed to notify instance listening: %v", response.Status)
+	}
 	return nil
 }
 
@@ -186,14 +314,43 @@ func (l *LocalConfigProvider) InstanceStopped() {
 	url := l.getInstanceURL()
 	_, err := l.sendRequest(http.MethodDelete, url, nil, nil)
 	if err != nil {
-		fmt.Printf("failed to notify instance stopped: %s\n", err)
+		l.GetLogger().Warn("failed to notify instance stopped", "url", url, "error", err)
 	}
 }
 
 // GetServiceAddress gets the service address for the specified resource and port type
 func (l *LocalConfigProvider) GetServiceAddress(resourceName, portType string) (string, error) {
 	url := l.getServiceClientURL(resourceName, portType)
-	return l.getString(url)
+	address, err := l.getString(url)
+	if errors.Is(err, ErrNotFound) {
+		return "", &NotFoundError{Kind: KindService, Name: resourceName + "/" + portType}
+	}
+	return address, err
+}
+
+// GetServiceEndpoints gets all endpoints for the specified resource and port type.
+// Falls back to the single service address if the cluster service does not know the endpoints.
+func (l *LocalConfigProvider) GetServiceEndpoints(resourceName, portType string) ([]Endpoint, error) {
+	url := l.getServiceEndpointsURL(resourceName, portType)
+
+	d, err := l.getRequestRaw(url)
+	if errors.Is(err, ErrNotFound) {
+		address, err := l.GetServiceAddress(resourceName, portType)
+		if err != nil {
+			return nil, err
+		}
+		return []Endpoint{{Address: address}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service endpoints: %w from %v", err, url)
+	}
+
+	endpoints := make([]Endpoint, 0)
+	err = json.Unmarshal(d, &endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return endpoints, nil
 }
 
 // GetResourceInfo gets the resource information for the specified resource type, port type, and resource name
@@ -202,6 +359,9 @@ func (l *LocalConfigProvider) GetResourceInfo(resourceType, portType, resourceNa
 
 	resourceInfo := &ResourceInfo{}
 	d, err := l.getRequestRaw(url)
+	if errors.Is(err, ErrNotFound) {
+		return nil, &NotFoundError{Kind: KindResource, Name: resourceName + "/" + portType}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resource info: %w from %v", err, url)
 	}
@@ -215,14 +375,63 @@ func (l *LocalConfigProvider) GetResourceInfo(resourceType, portType, resourceNa
 // GetInstanceHost gets the host for the specified instance ID
 func (l *LocalConfigProvider) GetInstanceHost(instanceID string) (string, error) {
 	url := l.getInstanceHostURL(instanceID)
-	return l.getString(url)
+	host, err := l.getString(url)
+	if errors.Is(err, ErrNotFound) {
+		return "", &NotFoundError{Kind: KindInstanceHost, Name: instanceID}
+	}
+	return host, err
+}
+
+// GetServerTLS gets the TLS material the cluster service has for serving the port type.
+// Falls back to the environment and mounted files, see AbstractConfigProvider.GetServerTLS.
+func (l *LocalConfigProvider) GetServerTLS(portType string) (*TLS, error) {
+	if portType == "" {
+		portType = DEFAULT_SERVER_PORT_TYPE
+	}
+	material, err := l.getTLS(l.getServerTLSURL(portType))
+	if errors.Is(err, ErrNotFound) || (err != nil && l.useOffline(err)) {
+		return l.AbstractConfigProvider.GetServerTLS(portType)
+	}
+	return material, err
+}
+
+// GetClientTLS gets the TLS material the cluster service has for connecting to the consumed resource.
+// Falls back to the environment and mounted files, see AbstractConfigProvider.GetClientTLS.
+func (l *LocalConfigProvider) GetClientTLS(resourceName string) (*TLS, error) {
+	material, err := l.getTLS(l.getClientTLSURL(resourceName))
+	if errors.Is(err, ErrNotFound) || (err != nil && l.useOffline(err)) {
+		return l.AbstractConfigProvider.GetClientTLS(resourceName)
+	}
+	return material, err
+}
+
+func (l *LocalConfigProvider) getTLS(url string) (*TLS, error) {
+	d, err := l.getRequestRaw(url)
+	if errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS material: %w from %v", err, url)
+	}
+	material := &TLS{}
+	if err := json.Unmarshal(d, material); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return material, nil
 }
 
 // GetConfig gets the configuration value for the specified path
 func (l *LocalConfigProvider) GetConfig(path string) interface{} {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.configuration[path]
+	return copyValue(l.configuration[path])
+}
+
+// GetConfiguration returns a copy of the whole instance configuration
+func (l *LocalConfigProvider) GetConfiguration() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return copyConfiguration(l.configuration)
 }
 
 // GetOrDefault gets the configuration value for the specified path, or a default value if not found
@@ -230,7 +439,7 @@ func (l *LocalConfigProvider) GetOrDefault(path string, defaultValue interface{}
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	if value, ok := l.configuration[path]; ok {
-		return value
+		return copyValue(value)
 	}
 	return defaultValue
 }
@@ -250,6 +459,49 @@ func (l *LocalConfigProvider) getInstanceConfig() (map[string]interface{}, error
 	return configuration, nil
 }
 
+// useOffline reports whether a failed request to the cluster service should be answered from cluster-service.yml
+// instead, and warns the first time it is
+func (l *LocalConfigProvider) useOffline(err error) bool {
+	if !l.offlineFallback || errors.Is(err, ErrNotFound) {
+		return false
+	}
+	l.offlineOnce.Do(func() {
+		l.GetLogger().Warn("cluster service is unreachable, running in offline mode: server ports and configuration are read from cluster-service.yml and other lookups fail",
+			"clusterService", l.cfg.GetClusterServiceAddress(), "error", err)
+	})
+	return true
+}
+
+// getOfflineIdentity finds the instance of the block in cluster-service.yml, unless the identity is given
+func (l *LocalConfigProvider) getOfflineIdentity() (*Identity, error) {
+	if l.SystemID != "" && l.InstanceID != "" {
+		return &Identity{SystemID: l.SystemID, InstanceID: l.InstanceID}, nil
+	}
+
+	var candidates []cfg.ClusterInstance
+	for _, instance := range l.cfg.GetClusterConfig().InstancesForBlock(l.BlockRef) {
+		if l.SystemID == "" || instance.SystemID == l.SystemID {
+			candidates = append(candidates, instance)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no instance of %s in cluster-service.yml", l.BlockRef)
+	case 1:
+		return &Identity{SystemID: candidates[0].SystemID, InstanceID: candidates[0].InstanceID}, nil
+	default:
+		return nil, fmt.Errorf("%s has %d instances in cluster-service.yml, set KAPETA_SYSTEM_ID and KAPETA_INSTANCE_ID to choose one", l.BlockRef, len(candidates))
+	}
+}
+
+func (l *LocalConfigProvider) getOfflineInstanceConfig() (map[string]interface{}, error) {
+	configuration, ok := l.cfg.GetClusterConfig().InstanceConfig(l.SystemID, l.InstanceID)
+	if !ok {
+		return nil, fmt.Errorf("no configuration for instance %s of %s in cluster-service.yml", l.InstanceID, l.SystemID)
+	}
+	return copyConfiguration(configuration), nil
+}
+
 func (l *LocalConfigProvider) getIdentityURL() string {
 	return l.getConfigBaseURL() + "/identity"
 }
@@ -270,10 +522,22 @@ func (l *LocalConfigProvider) getServiceClientURL(resourceName, serviceType stri
 	return l.getConfigBaseURL() + fmt.Sprintf("/consumes/%s/%s", l.encode(resourceName), l.encode(serviceType))
 }
 
+func (l *LocalConfigProvider) getServiceEndpointsURL(resourceName, serviceType string) string {
+	return l.getServiceClientURL(resourceName, serviceType) + "/endpoints"
+}
+
 func (l *LocalConfigProvider) getResourceInfoURL(operatorType, portType, resourceName string) string {
 	return l.getConfigBaseURL() + fmt.Sprintf("/consumes/resource/%s/%s/%s", l.encode(operatorType), l.encode(portType), l.encode(resourceName))
 }
 
+func (l *LocalConfigProvider) getServerTLSURL(portType string) string {
+	return l.getConfigBaseURL() + fmt.Sprintf("/tls/provides/%s", l.encode(portType))
+}
+
+func (l *LocalConfigProvider) getClientTLSURL(resourceName string) string {
+	return l.getConfigBaseURL() + fmt.Sprintf("/tls/consumes/%s", l.encode(resourceName))
+}
+
 func (l *LocalConfigProvider) getInstanceHostURL(instanceID string) string {
 	elements := []string{l.encode(l.SystemID), l.encode(instanceID), "address", "public"}
 	subPath := strings.Join(elements, "/")
@@ -285,8 +549,7 @@ func (l *LocalConfigProvider) getInstanceURL() string {
 }
 
 func (l *LocalConfigProvider) getClusterServiceBaseURL() string {
-
-	return l.cfg.GetClusterServiceAddress()
+	return l.baseURL
 }
 
 func (l *LocalConfigProvider) sendRequest(method, url string, body interface{}, headers map[string]string) (*http.Response, error) {
@@ -308,16 +571,38 @@ func (l *LocalConfigProvider) sendRequest(method, url string, body interface{},
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	if l.token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := l.client.Do(req)
+	l.recordContact(err)
 	if err != nil {
+		l.GetLogger().Debug("cluster service request failed", "method", method, "url", url, "error", err)
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	l.GetLogger().Debug("cluster service request", "method", method, "url", url, "status", resp.StatusCode)
 
 	return resp, nil
 }
 
+func (l *LocalConfigProvider) recordContact(err error) {
+	l.muStatus.Lock()
+	defer l.muStatus.Unlock()
+	l.lastError = err
+	if err == nil {
+		l.lastContact = time.Now()
+	}
+}
+
+// ClusterServiceStatus returns when the cluster service last responded, and the error of the last request
+// if the cluster service could not be reached
+func (l *LocalConfigProvider) ClusterServiceStatus() (time.Time, error) {
+	l.muStatus.Lock()
+	defer l.muStatus.Unlock()
+	return l.lastContact, l.lastError
+}
+
 func (l *LocalConfigProvider) getRequest(url string) (string, error) {
 	resp, err := l.sendRequest(http.MethodGet, url, nil, nil)
 	if err != nil {
@@ -326,7 +611,7 @@ func (l *LocalConfigProvider) getRequest(url string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return "", nil
+		return "", ErrNotFound
 	}
 
 	if resp.StatusCode > 399 {
@@ -342,18 +627,31 @@ func (l *LocalConfigProvider) getRequest(url string) (string, error) {
 }
 
 func (l *LocalConfigProvider) getDefaultHeaders() map[string]string {
-	out := map[string]string{
-		HEADER_KAPETA_ENVIRONMENT: "process",
-		HEADER_KAPETA_BLOCK:       l.BlockRef,
-		HEADER_KAPETA_SYSTEM:      l.SystemID,
-		HEADER_KAPETA_INSTANCE:    l.InstanceID,
+	return ClusterServiceHeaders(l.BlockRef, l.SystemID, l.InstanceID)
+}
+
+// ClusterServiceHeaders returns the headers identifying the instance to the cluster service
+func ClusterServiceHeaders(blockRef, systemID, instanceID string) map[string]string {
+	return map[string]string{
+		HEADER_KAPETA_ENVIRONMENT: ClusterServiceEnvironment(),
+		HEADER_KAPETA_BLOCK:       blockRef,
+		HEADER_KAPETA_SYSTEM:      systemID,
+		HEADER_KAPETA_INSTANCE:    instanceID,
 	}
+}
 
+// ClusterServiceEnvironment returns the X-Kapeta-Environment header sent to the cluster service: the environment
+// type, "process" unless KAPETA_ENVIRONMENT_TYPE is set, followed by the profile selected with KAPETA_PROFILE,
+// such as "process; profile=feature"
+func ClusterServiceEnvironment() string {
+	environment := "process"
 	if os.Getenv(KAPETA_ENVIRONMENT_TYPE) != "" {
-		out[HEADER_KAPETA_ENVIRONMENT] = os.Getenv(KAPETA_ENVIRONMENT_TYPE)
+		environment = os.Getenv(KAPETA_ENVIRONMENT_TYPE)
 	}
-
-	return out
+	if profile := cfg.GetProfile(); profile != "" {
+		environment += "; profile=" + profile
+	}
+	return environment
 }
 
 func (l *LocalConfigProvider) getRequestRaw(url string) ([]byte, error) {
@@ -364,7 +662,7 @@ func (l *LocalConfigProvider) getRequestRaw(url string) ([]byte, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil
+		return nil, ErrNotFound
 	}
 
 	if resp.StatusCode > 399 {
@@ -420,6 +718,9 @@ func (l *LocalConfigProvider) GetInstanceOperator(instanceId string) (*InstanceO
 	)
 	operator := &InstanceOperator{}
 	err := l.doRequestValue(fullUrl, operator)
+	if errors.Is(err, ErrNotFound) {
+		return nil, &NotFoundError{Kind: KindInstanceOperator, Name: instanceId}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get operator: %w", err)
 	}
@@ -442,7 +743,7 @@ func (l *LocalConfigProvider) GetInstanceForConsumer(resourceName string) (*Bloc
 	}
 
 	if connection == nil {
-		return nil, fmt.Errorf("could not find connection for consumer %s", resourceName)
+		return nil, &NotFoundError{Kind: KindConsumer, Name: resourceName, Detail: "no connection in plan"}
 	}
 
 	var instance *model.BlockInstance // Assuming BlockInstance is a defined type
@@ -454,12 +755,12 @@ func (l *LocalConfigProvider) GetInstanceForConsumer(resourceName string) (*Bloc
 	}
 
 	if instance == nil {
-		return nil, fmt.Errorf("could not find instance %s in plan", connection.Provider.BlockId)
+		return nil, &NotFoundError{Kind: KindBlockInstance, Name: connection.Provider.BlockId, Detail: "not in plan"}
 	}
 
 	block, err := l.GetKind(instance.Block.Ref)
 	if err != nil {
-		return nil, fmt.Errorf("could not find block %s in plan: %v", instance.Block.Ref, err)
+		return nil, fmt.Errorf("could not find block %s in plan: %w", instance.Block.Ref, err)
 	}
 
 	return &BlockInstanceDetails{
@@ -471,61 +772,76 @@ func (l *LocalConfigProvider) GetInstanceForConsumer(resourceName string) (*Bloc
 	}, nil
 }
 
+// GetInstancesForProvider returns the consumer instances connected to the given provider resource, in the order
+// the instances appear in the plan. The connections of each instance are in plan order, and a connection listed
+// more than once in the plan is only returned once.
 func (l *LocalConfigProvider) GetInstancesForProvider(resourceName string) ([]*BlockInstanceDetails, error) {
 	plan, err := l.GetPlan()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get plan: %s, Error: %w", l.SystemID, err)
 	}
+	graph := plangraph.New(plan)
 
-	blockDetails := make(map[string]*BlockInstanceDetails)
-
-	connections := make([]model.Connection, 0)
-	for _, connection := range plan.Spec.Connections {
-		if connection.Provider.BlockId == l.InstanceID &&
-			connection.Provider.ResourceName == resourceName {
-			connections = append(connections, connection)
+	connections := graph.ConnectionsIn(l.InstanceID)
+	consumerConnections := make(map[string][]*model.Connection)
+	seen := make(map[connectionKey]bool)
+	for i := range connections {
+		connection := &connections[i]
+		if connection.Provider.ResourceName != resourceName {
+			continue
 		}
-	}
-
-	for _, connection := range connections {
-		blockInstanceID := connection.Consumer.BlockId
-		if details, exists := blockDetails[blockInstanceID]; exists {
-			details.Connections = append(details.Connections, &connection)
-			blockDetails[blockInstanceID] = details
+		key := newConnectionKey(connection)
+		if seen[key] {
 			continue
 		}
+		seen[key] = true
 
-		var instance *model.BlockInstance
-		for _, b := range plan.Spec.Blocks {
-			if b.Id == blockInstanceID {
-				instance = &b
-				break
-			}
-		}
-		if instance == nil {
-			return nil, fmt.Errorf("could not find instance %s in plan", blockInstanceID)
+		blockInstanceID := connection.Consumer.BlockId
+		if _, exists := graph.Instance(blockInstanceID); !exists {
+			return nil, &NotFoundError{Kind: KindBlockInstance, Name: blockInstanceID, Detail: "not in plan"}
 		}
+		consumerConnections[blockInstanceID] = append(consumerConnections[blockInstanceID], connection)
+	}
 
+	result := make([]*BlockInstanceDetails, 0, len(consumerConnections))
+	for _, instance := range graph.ConsumersOf(l.InstanceID, resourceName) {
 		block, err := l.GetKind(instance.Block.Ref)
 		if err != nil {
-			return nil, fmt.Errorf("could not find block %s in plan: %v", instance.Block.Ref, err)
+			return nil, fmt.Errorf("could not find block %s in plan: %w", instance.Block.Ref, err)
 		}
 
-		blockDetails[blockInstanceID] = &BlockInstanceDetails{
-			InstanceId: blockInstanceID,
-			Connections: []*model.Connection{
-				&connection,
-			},
-			Block: block,
-		}
+		result = append(result, &BlockInstanceDetails{
+			InstanceId:  instance.Id,
+			Connections: consumerConnections[instance.Id],
+			Block:       block,
+		})
 	}
 
-	result := make([]*BlockInstanceDetails, 0)
-	for _, details := range blockDetails {
-		result = append(result, details)
+	return result, nil
+}
+
+// connectionKey identifies a connection in a plan, which may list the same connection more than once
+type connectionKey struct {
+	consumer model.Endpoint
+	provider model.Endpoint
+	portType string
+}
+
+func newConnectionKey(connection *model.Connection) connectionKey {
+	key := connectionKey{consumer: connection.Consumer, provider: connection.Provider}
+	if connection.Port != nil {
+		key.portType = connection.Port.Type
 	}
+	return key
+}
 
-	return result, nil
+// GetPlanGraph returns the graph of the plan, as read from the local cluster service
+func (l *LocalConfigProvider) GetPlanGraph() (*plangraph.Graph, error) {
+	plan, err := l.GetPlan()
+	if err != nil {
+		return nil, err
+	}
+	return plangraph.New(plan), nil
 }
 
 func (l *LocalConfigProvider) GetAsset(ref string, value any) error {
@@ -534,7 +850,11 @@ func (l *LocalConfigProvider) GetAsset(ref string, value any) error {
 		l.getClusterServiceBaseURL(),
 		l.encode(ref),
 	)
-	return l.doRequestValue(fullUrl, value)
+	err := l.doRequestValue(fullUrl, value)
+	if errors.Is(err, ErrNotFound) {
+		return &NotFoundError{Kind: KindAsset, Name: ref}
+	}
+	return err
 }
 
 func (l *LocalConfigProvider) doRequestValue(fullUrl string, value any) error {
@@ -4,46 +4,39 @@
 package providers
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
-	"github.com/kapetacom/schemas/packages/go/model"
+	"log/slog"
 	"net/http"
-	"net/http/httptest"
-	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/clustertest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func hostAndFromURL(url string) (string, string) {
-	hostAndPort := url[7:]
-	return strings.Split(hostAndPort, ":")[0], strings.Split(hostAndPort, ":")[1]
+// startClusterService starts a fake cluster service resolving the identity to system-id and instance-id
+func startClusterService(t *testing.T, fixture *clustertest.Fixture) *clustertest.Server {
+	t.Helper()
+	if fixture == nil {
+		fixture = &clustertest.Fixture{}
+	}
+	fixture.Identity = clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"}
+	return clustertest.Start(t, fixture)
 }
-func TestLocal(t *testing.T) {
-	// Mock environment variables for testing
 
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
-		_, _ = w.Write([]byte("40004"))
-	})
-	defer srv.Close()
-
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+func TestLocal(t *testing.T) {
+	startClusterService(t, &clustertest.Fixture{ProviderPorts: map[string]string{"http": "40004"}})
 
 	provider := NewLocalConfigProvider("kapeta/block-type-gateway-http", "systemID", "instanceID", map[string]interface{}{})
 	serverPort, err := provider.GetServerPort("http")
 	assert.NoError(t, err)
 	assert.Equal(t, "40004", serverPort)
-
 }
+
 func TestLocalCreateLocalConfigProvider(t *testing.T) {
 	blockRef := "block-ref"
 	systemID := "system-id"
@@ -52,16 +45,7 @@ func TestLocalCreateLocalConfigProvider(t *testing.T) {
 		"type": "local",
 	}
 
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("40004"))
-	})
-	defer srv.Close()
-
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+	startClusterService(t, nil)
 	provider := NewLocalConfigProvider(blockRef, systemID, instanceID, blockDefinition)
 
 	assert.Equal(t, blockRef, provider.GetBlockReference())
@@ -71,61 +55,33 @@ func TestLocalCreateLocalConfigProvider(t *testing.T) {
 }
 
 func TestLocalResolveIdentity(t *testing.T) {
-	os.Setenv("KAPETA_ENVIRONMENT_TYPE", "process")
-	os.Setenv("KAPETA_BLOCK", "block-ref")
-	os.Setenv("KAPETA_SYSTEM", "system-id")
-	os.Setenv("KAPETA_INSTANCE", "instance-id")
-	defer func() {
-		os.Unsetenv("KAPETA_ENVIRONMENT_TYPE")
-		os.Unsetenv("KAPETA_BLOCK")
-		os.Unsetenv("KAPETA_SYSTEM")
-		os.Unsetenv("KAPETA_INSTANCE")
-	}()
-
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("40004"))
-	})
-	defer srv.Close()
+	t.Setenv("KAPETA_ENVIRONMENT_TYPE", "process")
+	t.Setenv("KAPETA_BLOCK", "block-ref")
+	t.Setenv("KAPETA_SYSTEM", "system-id")
+	t.Setenv("KAPETA_INSTANCE", "instance-id")
 
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
-	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
+	srv := startClusterService(t, nil)
+	provider := NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{
 		"type": "local",
 	})
 
 	assert.Equal(t, "system-id", provider.GetSystemId())
 	assert.Equal(t, "instance-id", provider.GetInstanceId())
+	assert.Len(t, srv.RequestsTo(http.MethodGet, "/config/identity"), 1)
 }
 
 func TestLocalLoadConfiguration(t *testing.T) {
-	// create test server that return the correct values
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
-		if strings.HasSuffix(r.URL.Path, "/rest") {
-			_, _ = w.Write([]byte("8080"))
-		} else if strings.HasSuffix(r.URL.Path, "/grpc") {
-			_, _ = w.Write([]byte("8081"))
-		}
+	startClusterService(t, &clustertest.Fixture{
+		InstanceConfig: map[string]any{"greeting": "hello"},
+		ProviderPorts:  map[string]string{"rest": "8080", "grpc": "8081"},
 	})
-	defer srv.Close()
-
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
 
 	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "local",
 	})
 
+	assert.Equal(t, "hello", provider.Get("greeting"))
+
 	port, err := provider.GetServerPort("rest")
 	assert.NoError(t, err)
 	assert.Equal(t, "8080", port)
@@ -140,29 +96,13 @@ func TestLocalLoadConfiguration(t *testing.T) {
 }
 
 func TestLocalGetServiceAddress1(t *testing.T) {
-	// create test server that return the correct values
-	srv := setupLocalTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "baz") {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if strings.HasSuffix(r.URL.Path, "/rest") {
-			_, _ = w.Write([]byte("10.0.0.1:8080"))
-			return
-		}
-		if strings.HasSuffix(r.URL.Path, "/grpc") {
-			_, _ = w.Write([]byte("10.0.0.2:8081"))
-			return
-		}
-	}))
-	defer srv.Close()
-
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+	startClusterService(t, &clustertest.Fixture{
+		Services: []clustertest.Service{
+			{Name: "foo", PortType: "rest", Address: "10.0.0.1:8080"},
+			{Name: "bar", PortType: "grpc", Address: "10.0.0.2:8081"},
+		},
+		Failures: map[string]int{"/config/consumes/baz/rest": http.StatusInternalServerError},
+	})
 
 	provider := NewLocalConfigProvider("block-ref", "kapeta://soren_mathiasen/java-cloud-bucket:local", "instance-id", map[string]interface{}{
 		"type": "local",
@@ -181,33 +121,95 @@ func TestLocalGetServiceAddress1(t *testing.T) {
 	assert.Equal(t, "failed to send GET request: request failed - Status: 500", err.Error())
 }
 
-func TestLocalGetInstanceHost(t *testing.T) {
-	// create test server that return the correct values
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
-		if strings.Contains(r.URL.Path, "system-id/unknown-instance-id") {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if strings.Contains(r.URL.Path, "/instances/system-id/") {
-			_, _ = w.Write([]byte("10.0.0.1"))
-			return
-		}
+func TestLocalGetServiceEndpoints(t *testing.T) {
+	startClusterService(t, &clustertest.Fixture{
+		Services: []clustertest.Service{
+			{Name: "users", PortType: "rest", Address: "10.0.0.1:8080", Endpoints: []clustertest.Endpoint{
+				{Address: "10.0.0.1:8080", InstanceId: "a"},
+				{Address: "10.0.0.2:8080", InstanceId: "b"},
+			}},
+			{Name: "orders", PortType: "rest", Address: "10.0.0.3:8080"},
+		},
+	})
+
+	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
+		"type": "local",
+	})
+
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Address: "10.0.0.1:8080", InstanceId: "a"},
+		{Address: "10.0.0.2:8080", InstanceId: "b"},
+	}, endpoints)
+
+	// Falls back to the single service address
+	endpoints, err = provider.GetServiceEndpoints("orders", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, []Endpoint{{Address: "10.0.0.3:8080"}}, endpoints)
+
+	_, err = provider.GetServiceEndpoints("unknown", "rest")
+	assert.Error(t, err)
+}
+
+func TestLocalNotFound(t *testing.T) {
+	startClusterService(t, nil)
+
+	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
+		"type": "local",
 	})
-	defer srv.Close()
 
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
+	_, err := provider.GetServiceAddress("users", "rest")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "service not found: users/rest", err.Error())
+
+	_, err = provider.GetServerPort("grpc")
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, KindServerPort, notFound.Kind)
+	assert.Equal(t, "grpc", notFound.Name)
+
+	info, err := provider.GetResourceInfo("mongodb", "mongodb", "messages")
+	assert.Nil(t, info)
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, KindResource, notFound.Kind)
+
+	_, err = provider.GetInstanceHost("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetInstanceOperator("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetInstanceForConsumer("users")
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, KindAsset, notFound.Kind)
+	assert.Equal(t, "system-id", notFound.Name)
+}
+
+func TestLocalLogger(t *testing.T) {
+	startClusterService(t, nil)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	provider := NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{}, WithLogger(logger))
+	assert.Equal(t, logger, provider.GetLogger())
+
+	record := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(bytes.Split(buf.Bytes(), []byte("\n"))[0], &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "identity resolved", record["msg"])
+	assert.Equal(t, "block-ref", record["blockRef"])
+	assert.Equal(t, "system-id", record["systemId"])
+	assert.Equal(t, "instance-id", record["instanceId"])
+	assert.NotContains(t, buf.String(), "cluster service request", "debug records should not be written at info level")
+}
 
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_HOST")
-	defer os.Unsetenv("KAPETA_LOCAL_CLUSTER_PORT")
+func TestLocalGetInstanceHost(t *testing.T) {
+	startClusterService(t, &clustertest.Fixture{
+		InstanceHosts: map[string]string{"instance-id": "10.0.0.1"},
+		Failures:      map[string]int{"/instances/system-id/unknown-instance-id/address/public": http.StatusInternalServerError},
+	})
 
-	os.Setenv("KAPETA_INSTANCE_CONFIG", "{\"foo\": \"bar\"}")
-	defer os.Unsetenv("KAPETA_INSTANCE_CONFIG")
 	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "local",
 	})
@@ -222,252 +224,289 @@ func TestLocalGetInstanceHost(t *testing.T) {
 }
 
 func TestLocalGetInstanceOperator(t *testing.T) {
-	// Create a test server
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/config/operator/") {
-			io := InstanceOperator{
+	startClusterService(t, &clustertest.Fixture{
+		Operators: map[string]any{
+			"test-instance-id": InstanceOperator{
 				Hostname: "testhost",
 				Ports: map[string]InstanceOperatorPort{
 					"rest": {Protocol: "http", Port: 8080},
 					"grpc": {Protocol: "grpc", Port: 8081},
 				},
-			}
-			data, err := json.Marshal(io)
-			if err != nil {
-				t.Fatal(err)
-			}
-			_, _ = w.Write(data)
-			return
-		}
-		w.WriteHeader(http.StatusNotFound)
+			},
+		},
 	})
-	defer srv.Close()
 
 	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "local",
 	})
 
-	// Test the GetInstanceOperator method
-	instanceID := "test-instance-id"
-	operator, err := provider.GetInstanceOperator(instanceID)
-	if err != nil {
-		t.Errorf("GetInstanceOperator returned an error: %v", err)
-	}
+	operator, err := provider.GetInstanceOperator("test-instance-id")
+	require.NoError(t, err)
 
 	assert.Equal(t, "testhost", operator.Hostname)
 	assert.Len(t, operator.Ports, 2)
-
-	if port, ok := operator.Ports["rest"]; ok {
-		assert.Equal(t, "http", port.Protocol)
-		assert.Equal(t, 8080, port.Port)
-	} else {
-		t.Errorf("REST port not found")
-	}
-
-	if port, ok := operator.Ports["grpc"]; ok {
-		assert.Equal(t, "grpc", port.Protocol)
-		assert.Equal(t, 8081, port.Port)
-	} else {
-		t.Errorf("gRPC port not found")
-	}
+	assert.Equal(t, InstanceOperatorPort{Protocol: "http", Port: 8080}, operator.Ports["rest"])
+	assert.Equal(t, InstanceOperatorPort{Protocol: "grpc", Port: 8081}, operator.Ports["grpc"])
 }
 
 func TestLocalGetInstanceForConsumer(t *testing.T) {
 	resourceName := "test-resource"
-	// Mock data
-	mockPlan := &model.Plan{
-		Spec: model.PlanSpec{
-			Connections: []model.Connection{
-				{
-					Consumer: model.Endpoint{
-						BlockId:      "instance-id",
-						ResourceName: resourceName,
-					},
-					Provider: model.Endpoint{
-						BlockId: "provider-block-id",
-					},
-				},
-			},
-			Blocks: []model.BlockInstance{
-				{
-					Id: "provider-block-id",
-					Block: model.AssetReference{
-						Ref: "provider-ref",
+	startClusterService(t, &clustertest.Fixture{
+		Assets: map[string]any{
+			"system-id": model.Plan{
+				Spec: model.PlanSpec{
+					Connections: []model.Connection{{
+						Consumer: model.Endpoint{BlockId: "instance-id", ResourceName: resourceName},
+						Provider: model.Endpoint{BlockId: "provider-block-id"},
+					}},
+					Blocks: []model.BlockInstance{
+						{Id: "provider-block-id", Block: model.AssetReference{Ref: "provider-ref"}},
 					},
 				},
 			},
+			"provider-ref": model.Kind{Kind: "kapeta/block-type-service", Metadata: model.Metadata{Name: "kapeta/provider"}},
 		},
-	}
-
-	mockBlock := &model.Kind{
-		// fill with mock Kind data
-	}
-
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/config/operator/") {
-			io := InstanceOperator{
-				Hostname: "testhost",
-				Ports: map[string]InstanceOperatorPort{
-					"rest": {Protocol: "http", Port: 8080},
-					"grpc": {Protocol: "grpc", Port: 8081},
-				},
-			}
-			data, err := json.Marshal(io)
-			if err != nil {
-				t.Fatal(err)
-			}
-			_, _ = w.Write(data)
-			return
-		}
-		w.WriteHeader(http.StatusNotFound)
 	})
-	defer srv.Close()
 
 	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{
 		"type": "local",
 	})
 
-	// Mock GetPlan method
-	provider.GetPlan = func() (*model.Plan, error) {
-		return mockPlan, nil
-	}
-
-	// Mock GetKind method
-	provider.GetKind = func(ref string) (*model.Kind, error) {
-		if ref == "provider-ref" {
-			return mockBlock, nil
-		}
-		return nil, fmt.Errorf("block not found")
-	}
-
-	// Test the GetInstanceForConsumer method
-
 	instanceDetails, err := provider.GetInstanceForConsumer(resourceName)
-	assert.NoError(t, err)
+	require.NoError(t, err)
 
-	// Validate the returned data
 	assert.Equal(t, "provider-block-id", instanceDetails.InstanceId)
 	assert.Len(t, instanceDetails.Connections, 1)
 	assert.Equal(t, resourceName, instanceDetails.Connections[0].Consumer.ResourceName)
-	assert.Equal(t, mockBlock, instanceDetails.Block)
+	assert.Equal(t, "kapeta/provider", instanceDetails.Block.Metadata.Name)
 }
 
 func TestLocalGetInstancesForProvider(t *testing.T) {
 	resourceName := "test-resource"
-	mockPlan := &model.Plan{
-		Spec: model.PlanSpec{
-			Connections: []model.Connection{
-				{
-					Provider: model.Endpoint{
-						BlockId:      "instance-id",
-						ResourceName: resourceName,
+	startClusterService(t, &clustertest.Fixture{
+		Assets: map[string]any{
+			"system-id": model.Plan{
+				Spec: model.PlanSpec{
+					Connections: []model.Connection{
+						{
+							Provider: model.Endpoint{BlockId: "instance-id", ResourceName: resourceName},
+							Consumer: model.Endpoint{BlockId: "consumer-block-id-1"},
+						},
+						{
+							Provider: model.Endpoint{BlockId: "instance-id", ResourceName: resourceName},
+							Consumer: model.Endpoint{BlockId: "consumer-block-id-2"},
+						},
 					},
-					Consumer: model.Endpoint{
-						BlockId: "consumer-block-id-1",
+					Blocks: []model.BlockInstance{
+						{Id: "consumer-block-id-1", Block: model.AssetReference{Ref: "block-ref-1"}},
+						{Id: "consumer-block-id-2", Block: model.AssetReference{Ref: "block-ref-2"}},
 					},
 				},
+			},
+			"block-ref-1": model.Kind{},
+			"block-ref-2": model.Kind{},
+		},
+	})
+
+	provider := NewLocalConfigProvider("my-block-ref", "system-id", "instance-id", map[string]interface{}{
+		"type": "local",
+	})
+
+	instances, err := provider.GetInstancesForProvider(resourceName)
+	assert.NoError(t, err)
+
+	assert.Len(t, instances, 2)
+
+	for _, instance := range instances {
+		assert.NotNil(t, instance.Block)
+		assert.Len(t, instance.Connections, 1)
+	}
+}
+
+func TestLocalGetInstancesForProviderOrder(t *testing.T) {
+	resourceName := "messages"
+	connection := func(consumerID, consumerResource string) model.Connection {
+		return model.Connection{
+			Consumer: model.Endpoint{BlockId: consumerID, ResourceName: consumerResource},
+			Provider: model.Endpoint{BlockId: "instance-id", ResourceName: resourceName},
+			Port:     &model.Port{Type: "rest"},
+		}
+	}
+	plan := model.Plan{
+		Spec: model.PlanSpec{
+			Connections: []model.Connection{
+				connection("consumer-c", "messages"),
+				connection("consumer-a", "messages"),
+				connection("consumer-c", "archive"),
+				connection("consumer-a", "messages"),
 				{
-					Provider: model.Endpoint{
-						BlockId:      "instance-id",
-						ResourceName: resourceName,
-					},
-					Consumer: model.Endpoint{
-						BlockId: "consumer-block-id-2",
-					},
+					Consumer: model.Endpoint{BlockId: "consumer-b", ResourceName: "other"},
+					Provider: model.Endpoint{BlockId: "instance-id", ResourceName: "other"},
 				},
 			},
 			Blocks: []model.BlockInstance{
-				{
-					Id: "consumer-block-id-1",
-					Block: model.AssetReference{
-						Ref: "block-ref-1",
-					},
-				},
-				{
-					Id: "consumer-block-id-2",
-					Block: model.AssetReference{
-						Ref: "block-ref-2",
-					},
-				},
+				{Id: "consumer-a", Block: model.AssetReference{Ref: "kapeta/gateway:1.0.0"}},
+				{Id: "consumer-b", Block: model.AssetReference{Ref: "kapeta/service:1.0.0"}},
+				{Id: "consumer-c", Block: model.AssetReference{Ref: "kapeta/service:1.0.0"}},
 			},
 		},
 	}
 
-	mockBlocks := map[string]*model.Kind{
-		"block-ref-1": {},
-		"block-ref-2": {},
+	srv := startClusterService(t, &clustertest.Fixture{
+		Assets: map[string]any{
+			"system-id":            plan,
+			"kapeta/gateway:1.0.0": model.Kind{Kind: "kapeta://kapeta/block-type-gateway-http:0.0.1", Metadata: model.Metadata{Name: "kapeta/gateway"}},
+			"kapeta/service:1.0.0": model.Kind{Kind: "kapeta://kapeta/block-type-service:0.0.2", Metadata: model.Metadata{Name: "kapeta/service"}},
+		},
+	})
+
+	provider := NewLocalConfigProvider("my-block-ref", "system-id", "instance-id", map[string]interface{}{})
+
+	for i := 0; i < 10; i++ {
+		instances, err := provider.GetInstancesForProvider(resourceName)
+		require.NoError(t, err)
+
+		// Instances are in plan order, not in the order of their connections
+		require.Len(t, instances, 2)
+		assert.Equal(t, "consumer-a", instances[0].InstanceId)
+		assert.Equal(t, "kapeta/gateway", instances[0].Block.Metadata.Name)
+		assert.Equal(t, "consumer-c", instances[1].InstanceId)
+
+		// The duplicate connection is only returned once
+		require.Len(t, instances[0].Connections, 1)
+		assert.Equal(t, "messages", instances[0].Connections[0].Consumer.ResourceName)
+
+		// Every connection is its own value rather than an alias of the last one in the plan
+		require.Len(t, instances[1].Connections, 2)
+		assert.Equal(t, "messages", instances[1].Connections[0].Consumer.ResourceName)
+		assert.Equal(t, "archive", instances[1].Connections[1].Consumer.ResourceName)
+		assert.NotSame(t, instances[1].Connections[0], instances[1].Connections[1])
 	}
 
-	srv := setupLocalTestServer(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/config/operator/") {
-			io := InstanceOperator{
-				Hostname: "testhost",
-				Ports: map[string]InstanceOperatorPort{
-					"rest": {Protocol: "http", Port: 8080},
-					"grpc": {Protocol: "grpc", Port: 8081},
-				},
-			}
-			data, err := json.Marshal(io)
-			if err != nil {
-				t.Fatal(err)
-			}
-			_, _ = w.Write(data)
-			return
-		}
-		w.WriteHeader(http.StatusNotFound)
+	// Connections into an instance missing from the plan are an error
+	plan.Spec.Connections = append(plan.Spec.Connections, connection("consumer-d", "messages"))
+	srv.Update(func(fixture *clustertest.Fixture) {
+		fixture.Assets["system-id"] = plan
 	})
-	defer srv.Close()
+	_, err := provider.GetInstancesForProvider(resourceName)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
 
-	provider := NewLocalConfigProvider("my-block-ref", "system-id", "instance-id", map[string]interface{}{
-		"type": "local",
+const offlineClusterConfig = `
+services:
+  kapeta://sorenmat/go-sample:local:
+    b2a05a9a-8bcb-4afd-80b1-0416a8f26557:
+      http:
+        port: 40006
+config:
+  kapeta://sorenmat/go-sample:local:
+    b2a05a9a-8bcb-4afd-80b1-0416a8f26557:
+      greeting: hello
+instances:
+  - systemId: kapeta://sorenmat/go-sample:local
+    instanceId: b2a05a9a-8bcb-4afd-80b1-0416a8f26557
+    ref: kapeta://sorenmat/super-go-service:local
+`
+
+func TestLocalTLS(t *testing.T) {
+	t.Setenv(EnvTLSDir, t.TempDir())
+	server := startClusterService(t, &clustertest.Fixture{
+		ServerTLS: map[string]any{"rest": &TLS{CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key"}},
+		ClientTLS: map[string]any{"users": &TLS{CA: "ca", ServerName: "users"}},
 	})
+	provider := NewLocalConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
 
-	// Mock GetPlan method
-	provider.GetPlan = func() (*model.Plan, error) {
-		return mockPlan, nil
-	}
+	material, err := provider.GetServerTLS("")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key"}, material)
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/config/tls/provides/rest"), 1)
 
-	// Mock GetKind method
-	provider.GetKind = func(ref string) (*model.Kind, error) {
-		if kind, ok := mockBlocks[ref]; ok {
-			return kind, nil
-		}
-		return nil, fmt.Errorf("block not found")
-	}
+	material, err = provider.GetClientTLS("Users")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CA: "ca", ServerName: "users"}, material)
 
-	instances, err := provider.GetInstancesForProvider(resourceName)
+	// Material the cluster service doesn't have is read from the environment
+	t.Setenv(ClientTLSEnvVar("messages"), `{"caFile":"/certs/ca.crt"}`)
+	material, err = provider.GetClientTLS("messages")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CAFile: "/certs/ca.crt"}, material)
+
+	_, err = provider.GetServerTLS("grpc")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	server.Update(func(fixture *clustertest.Fixture) {
+		fixture.Failures = map[string]int{"/config/tls/provides/rest": http.StatusInternalServerError}
+	})
+	_, err = provider.GetServerTLS("rest")
+	assert.ErrorContains(t, err, "failed to get TLS material")
+}
+
+// unreachableClusterService points the local provider at a port nothing listens on
+func unreachableClusterService(t *testing.T) {
+	srv := clustertest.NewServer(nil)
+	host, port := srv.HostPort()
+	srv.Close()
+	t.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
+	t.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", offlineClusterConfig)
+}
+
+func TestLocalOfflineFallback(t *testing.T) {
+	unreachableClusterService(t)
+	t.Setenv(EnvLocalOffline, LocalOfflineFallback)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	provider := NewLocalConfigProvider("sorenmat/super-go-service:local", "", "", map[string]interface{}{}, WithLogger(logger))
+	defer provider.DisableExitHandler()
+
+	assert.Equal(t, "kapeta://sorenmat/go-sample:local", provider.GetSystemId(), "the identity is found by block reference")
+	assert.Equal(t, "b2a05a9a-8bcb-4afd-80b1-0416a8f26557", provider.GetInstanceId())
+	assert.Equal(t, "hello", provider.Get("greeting"))
+
+	port, err := provider.GetServerPort("http")
 	assert.NoError(t, err)
+	assert.Equal(t, "40006", port)
 
-	assert.Len(t, instances, 2)
+	_, err = provider.GetServerPort("grpc")
+	assert.Error(t, err, "ports missing from the file fail")
+	_, err = provider.GetServiceAddress("users", "rest")
+	assert.Error(t, err, "only server ports and configuration are available offline")
 
-	for _, instance := range instances {
-		assert.NotNil(t, instance.Block)
-		assert.Len(t, instance.Connections, 1)
-	}
+	assert.Equal(t, 1, strings.Count(buf.String(), "running in offline mode"), "offline mode is logged once")
 }
 
-func setupLocalTestServer(handler http.HandlerFunc) *httptest.Server {
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/identity") {
-			_, _ = w.Write([]byte("{\"systemId\": \"system-id\", \"instanceId\": \"instance-id\"}"))
-			return
-		}
+func TestLocalOfflineFallbackDisabled(t *testing.T) {
+	unreachableClusterService(t)
 
-		if strings.HasSuffix(r.URL.Path, "/instance") {
-			_, _ = w.Write([]byte("{\"id\": \"instanceID\", \"host\": \"bla\"}"))
-			return
-		}
+	assert.Panics(t, func() {
+		NewLocalConfigProvider("sorenmat/super-go-service:local", "", "", map[string]interface{}{})
+	})
+}
 
-		// Is hit when self-registering with local cluster service
-		if r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/instances") {
-			_, _ = w.Write([]byte("{}"))
-			return
-		}
-		handler(w, r)
-	}))
+func TestLocalOfflineFallbackUnknownBlock(t *testing.T) {
+	unreachableClusterService(t)
+	t.Setenv(EnvLocalOffline, LocalOfflineFallback)
+
+	_, err := NewLocalConfigProviderE("sorenmat/unknown:local", "", "", map[string]interface{}{})
+	assert.EqualError(t, err, "failed to resolve identity: no instance of sorenmat/unknown:local in cluster-service.yml")
+}
+
+func TestLocalInvalidClusterConfig(t *testing.T) {
+	t.Setenv("TEST_KAPETA_CLUSTER_CONFIG_FILE", "cluster: [invalid")
+
+	_, err := NewLocalConfigProviderE("block-ref", "", "", map[string]interface{}{})
+	assert.EqualError(t, err, "failed to load cluster config: TEST_KAPETA_CLUSTER_CONFIG_FILE: error unmarshalling cluster config: yaml: line 1: did not find expected ',' or ']'")
+
+	assert.PanicsWithError(t, err.Error(), func() {
+		NewLocalConfigProvider("block-ref", "", "", map[string]interface{}{})
+	}, "NewLocalConfigProvider panics with the error")
+}
+
+func TestLocalInvalidConfigFile(t *testing.T) {
+	t.Setenv("KAPETA_CONFIG_PATH", filepath.Join(t.TempDir(), "missing.json"))
 
-	host, port := hostAndFromURL(srv.URL)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_HOST", host)
-	os.Setenv("KAPETA_LOCAL_CLUSTER_PORT", port)
-	return srv
+	_, err := NewLocalConfigProviderE("block-ref", "", "", map[string]interface{}{})
+	assert.ErrorContains(t, err, "failed to read environment config file")
 }
@@ -0,0 +1,48 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+type options struct {
+	logger       *slog.Logger
+	transport    http.RoundTripper
+	instrumenter Instrumenter
+}
+
+// Option configures a provider when it is created
+type Option func(*options)
+
+// WithLogger sets the logger used by the provider instead of the SDK-wide logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithTransport sets the HTTP transport used for requests to the local cluster service, instead of the one
+// built from the cluster service URL
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithInstrumenter records the requests to the local cluster service with the instrumenter
+func WithInstrumenter(instrumenter Instrumenter) Option {
+	return func(o *options) {
+		o.instrumenter = instrumenter
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"sync"
+)
+
+// Overrides holds values that take priority over those of the provider, for running a block against
+// some real dependencies while the rest comes from the provider, and for tests.
+// Every override returns a function that restores the previous value, suitable for t.Cleanup.
+// Overrides of the same key stack, so restoring them in any order leaves the remaining latest one in effect.
+// The zero value is ready to use and it is safe for concurrent use.
+type Overrides struct {
+	mu      sync.RWMutex
+	nextID  int
+	entries map[overrideKey][]overrideEntry
+}
+
+type overrideKind int
+
+const (
+	overrideServiceAddress overrideKind = iota
+	overrideResourceInfo
+	overrideValue
+	overrideInstanceHost
+)
+
+type overrideKey struct {
+	kind overrideKind
+	name string
+	// portType is empty for values and instance hosts
+	portType string
+}
+
+type overrideEntry struct {
+	id    int
+	value interface{}
+}
+
+// OverrideServiceAddress makes the service consumed as resourceName on the port type resolve to the address
+func (o *Overrides) OverrideServiceAddress(resourceName, portType, address string) (restore func()) {
+	return o.set(overrideKey{kind: overrideServiceAddress, name: resourceName, portType: portType}, address)
+}
+
+// OverrideResourceInfo makes the resource consumed as resourceName on the port type resolve to a copy of the info,
+// whatever resource type is asked for. A nil info removes the override, so the resource is looked up through
+// the provider until it is restored.
+func (o *Overrides) OverrideResourceInfo(resourceName, portType string, info *ResourceInfo) (restore func()) {
+	key := overrideKey{kind: overrideResourceInfo, name: resourceName, portType: portType}
+	if info == nil {
+		return o.set(key, nil)
+	}
+	return o.set(key, copyResourceInfo(info))
+}
+
+// OverrideValue makes the configuration value at the path resolve to the value. Only lookups of exactly
+// the same path are overridden.
+func (o *Overrides) OverrideValue(path string, value interface{}) (restore func()) {
+	return o.set(overrideKey{kind: overrideValue, name: path}, value)
+}
+
+// OverrideInstanceHost makes the instance resolve to the host
+func (o *Overrides) OverrideInstanceHost(instanceID, host string) (restore func()) {
+	return o.set(overrideKey{kind: overrideInstanceHost, name: instanceID}, host)
+}
+
+// Middleware returns a middleware serving the overrides in effect at the time of every lookup
+func (o *Overrides) Middleware() Middleware {
+	return func(next ConfigProvider) ConfigProvider {
+		return &overridingProvider{Wrapper: Wrapper{ConfigProvider: next}, overrides: o}
+	}
+}
+
+func (o *Overrides) set(key overrideKey, value interface{}) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.entries == nil {
+		o.entries = map[overrideKey][]overrideEntry{}
+	}
+	o.nextID++
+	id := o.nextID
+	o.entries[key] = append(o.entries[key], overrideEntry{id: id, value: value})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { o.remove(key, id) })
+	}
+}
+
+func (o *Overrides) remove(key overrideKey, id int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entries := o.entries[key]
+	for i, entry := range entries {
+		if entry.id == id {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(o.entries, key)
+		return
+	}
+	o.entries[key] = entries
+}
+
+// get returns the latest override of the key
+func (o *Overrides) get(key overrideKey) (interface{}, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	entries := o.entries[key]
+	if len(entries) == 0 {
+		return nil, false
+	}
+	return entries[len(entries)-1].value, true
+}
+
+// overridingProvider serves the overrides and passes every other lookup through
+type overridingProvider struct {
+	Wrapper
+	overrides *Overrides
+}
+
+func (p *overridingProvider) GetServiceAddress(serviceName, portType string) (string, error) {
+	if address, ok := p.overrides.get(overrideKey{kind: overrideServiceAddress, name: serviceName, portType: portType}); ok {
+		return address.(string), nil
+	}
+	return p.ConfigProvider.GetServiceAddress(serviceName, portType)
+}
+
+func (p *overridingProvider) GetServiceEndpoints(serviceName, portType string) ([]Endpoint, error) {
+	if address, ok := p.overrides.get(overrideKey{kind: overrideServiceAddress, name: serviceName, portType: portType}); ok {
+		return []Endpoint{{Address: address.(string)}}, nil
+	}
+	return p.ConfigProvider.GetServiceEndpoints(serviceName, portType)
+}
+
+func (p *overridingProvider) GetResourceInfo(resourceType, portType, resourceName string) (*ResourceInfo, error) {
+	if info, ok := p.overrides.get(overrideKey{kind: overrideResourceInfo, name: resourceName, portType: portType}); ok && info != nil {
+		// A copy, so callers changing the info do not change the override
+		return copyResourceInfo(info.(*ResourceInfo)), nil
+	}
+	return p.ConfigProvider.GetResourceInfo(resourceType, portType, resourceName)
+}
+
+func (p *overridingProvider) GetInstanceHost(instanceID string) (string, error) {
+	if host, ok := p.overrides.get(overrideKey{kind: overrideInstanceHost, name: instanceID}); ok {
+		return host.(string), nil
+	}
+	return p.ConfigProvider.GetInstanceHost(instanceID)
+}
+
+func (p *overridingProvider) Get(path string) interface{} {
+	if value, ok := p.overrides.get(overrideKey{kind: overrideValue, name: path}); ok {
+		return value
+	}
+	return p.ConfigProvider.Get(path)
+}
+
+func (p *overridingProvider) GetOrDefault(path string, defaultValue interface{}) interface{} {
+	if value, ok := p.overrides.get(overrideKey{kind: overrideValue, name: path}); ok {
+		return value
+	}
+	return p.ConfigProvider.GetOrDefault(path, defaultValue)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOverriddenProvider(overrides *Overrides) ConfigProvider {
+	static := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &StaticFixture{
+		Configuration: map[string]interface{}{"greeting": "hello"},
+		Services:      map[string]map[string]string{"users": {"rest": "http://users/"}},
+		InstanceHosts: map[string]string{"instance-1": "users.local"},
+	})
+	return Chain(static, overrides.Middleware())
+}
+
+func TestOverrides(t *testing.T) {
+	overrides := &Overrides{}
+	provider := newOverriddenProvider(overrides)
+
+	restoreAddress := overrides.OverrideServiceAddress("users", "rest", "http://localhost:8080/")
+	restoreValue := overrides.OverrideValue("greeting", "hi")
+	restoreHost := overrides.OverrideInstanceHost("instance-1", "localhost")
+	original := &ResourceInfo{
+		Host:        "localhost",
+		Port:        "27017",
+		Credentials: map[string]string{"password": "secret"},
+		Options:     map[string]interface{}{"replicaSet": map[string]interface{}{"name": "rs0"}},
+	}
+	restoreInfo := overrides.OverrideResourceInfo("messages", "mongodb", original)
+	original.Credentials["password"] = "changed"
+
+	address, err := provider.GetServiceAddress("users", "rest")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/", address)
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{{Address: "http://localhost:8080/"}}, endpoints)
+	assert.Equal(t, "hi", provider.Get("greeting"))
+	assert.Equal(t, "hi", provider.GetOrDefault("greeting", "default"))
+	host, err := provider.GetInstanceHost("instance-1")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", host)
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	require.NoError(t, err, "resources are overridden even if the provider does not know them")
+	assert.Equal(t, "localhost", info.Host)
+	assert.Equal(t, "secret", info.Credentials["password"], "the override is a copy of the info")
+	info.Host = "changed"
+	info.Credentials["password"] = "changed"
+	info.Options["replicaSet"].(map[string]interface{})["name"] = "changed"
+	info, _ = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.Equal(t, "localhost", info.Host, "callers get a copy of the override")
+	assert.Equal(t, "secret", info.Credentials["password"])
+	assert.Equal(t, map[string]interface{}{"name": "rs0"}, info.Options["replicaSet"])
+
+	restoreRemoved := overrides.OverrideResourceInfo("messages", "mongodb", nil)
+	_, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.ErrorIs(t, err, ErrNotFound, "a nil info removes the override")
+	restoreRemoved()
+	info, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	require.NoError(t, err, "restoring the removal brings the override back")
+	assert.Equal(t, "localhost", info.Host)
+
+	restoreAddress()
+	restoreValue()
+	restoreHost()
+	restoreInfo()
+
+	address, _ = provider.GetServiceAddress("users", "rest")
+	assert.Equal(t, "http://users/", address)
+	assert.Equal(t, "hello", provider.Get("greeting"))
+	host, _ = provider.GetInstanceHost("instance-1")
+	assert.Equal(t, "users.local", host)
+	_, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestOverridesStack(t *testing.T) {
+	overrides := &Overrides{}
+	provider := newOverriddenProvider(overrides)
+
+	restoreFirst := overrides.OverrideValue("greeting", "first")
+	restoreSecond := overrides.OverrideValue("greeting", "second")
+	assert.Equal(t, "second", provider.Get("greeting"))
+
+	restoreFirst()
+	assert.Equal(t, "second", provider.Get("greeting"), "restoring an older override keeps the latest")
+	restoreFirst()
+	assert.Equal(t, "second", provider.Get("greeting"), "restoring twice has no effect")
+
+	restoreSecond()
+	assert.Equal(t, "hello", provider.Get("greeting"))
+}
+
+func TestOverridesConcurrency(t *testing.T) {
+	overrides := &Overrides{}
+	provider := newOverriddenProvider(overrides)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			restore := overrides.OverrideValue(fmt.Sprintf("value-%d", i), i)
+			assert.Equal(t, i, provider.Get(fmt.Sprintf("value-%d", i)))
+			_ = provider.Get("greeting")
+			restore()
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, overrides.entries)
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kapetacom/sdk-go-config/plangraph"
+)
+
+const (
+	// EnvPlan holds the JSON encoded plan the instance runs in, see GetPlanGraph
+	EnvPlan = "KAPETA_PLAN"
+	// EnvPlanFile is the path of a mounted file holding the JSON encoded plan, used if EnvPlan is not set
+	EnvPlanFile = "KAPETA_PLAN_FILE"
+)
+
+// GetPlanGraph returns the graph of the plan the instance runs in.
+// The plan is read from KAPETA_PLAN, or from the file KAPETA_PLAN_FILE points at.
+func (a *AbstractConfigProvider) GetPlanGraph() (*plangraph.Graph, error) {
+	if value, exists := a.LookupEnv(EnvPlan); exists {
+		graph, err := plangraph.Parse([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", EnvPlan, err)
+		}
+		return graph, nil
+	}
+
+	path, exists := a.LookupEnv(EnvPlanFile)
+	if !exists {
+		return nil, missingEnvVar(KindPlan, a.SystemID, EnvPlan)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	graph, err := plangraph.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return graph, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbstractConfigProviderPlanGraph(t *testing.T) {
+	provider := &AbstractConfigProvider{SystemID: "kapeta/sample-plan:local", EnvironmentConfiguration: map[string]string{}}
+
+	_, err := provider.GetPlanGraph()
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorContains(t, err, "missing environment variable KAPETA_PLAN")
+
+	// A mounted plan
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(path, testdata.PlanJSON, 0o600))
+	provider.EnvironmentConfiguration[EnvPlanFile] = path
+
+	graph, err := provider.GetPlanGraph()
+	require.NoError(t, err)
+	assert.Equal(t, "kapeta/sample-plan", graph.Name())
+	assert.Len(t, graph.Instances(), 5)
+
+	// The environment takes precedence over the mounted file
+	provider.EnvironmentConfiguration[EnvPlan] = `{"metadata":{"name":"kapeta/other-plan"},"spec":{"blocks":[],"connections":[]}}`
+	graph, err = provider.GetPlanGraph()
+	require.NoError(t, err)
+	assert.Equal(t, "kapeta/other-plan", graph.Name())
+
+	provider.EnvironmentConfiguration[EnvPlan] = `{invalid`
+	_, err = provider.GetPlanGraph()
+	assert.ErrorContains(t, err, "failed to parse KAPETA_PLAN")
+
+	delete(provider.EnvironmentConfiguration, EnvPlan)
+	provider.EnvironmentConfiguration[EnvPlanFile] = filepath.Join(t.TempDir(), "missing.json")
+	_, err = provider.GetPlanGraph()
+	assert.ErrorContains(t, err, "failed to read plan")
+}
+
+func TestStaticPlanGraph(t *testing.T) {
+	fixture, err := LoadStaticFixture("../testdata/static.yml")
+	require.NoError(t, err)
+	provider := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, fixture)
+
+	graph, err := provider.GetPlanGraph()
+	require.NoError(t, err)
+	consumers := graph.ConsumersOf(provider.GetInstanceId(), "messages")
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "gateway", consumers[0].Id)
+
+	provider = NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, nil)
+	_, err = provider.GetPlanGraph()
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalGetPlanGraph(t *testing.T) {
+	startClusterService(t, &clustertest.Fixture{
+		Assets: map[string]any{
+			"system-id": model.Plan{
+				Metadata: model.Metadata{Name: "kapeta/sample-plan"},
+				Spec: model.PlanSpec{
+					Blocks: []model.BlockInstance{{Id: "instance-id"}, {Id: "users-1"}},
+					Connections: []model.Connection{{
+						Consumer: model.Endpoint{BlockId: "instance-id", ResourceName: "users"},
+						Provider: model.Endpoint{BlockId: "users-1", ResourceName: "users"},
+					}},
+				},
+			},
+		},
+	})
+
+	provider := NewLocalConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{})
+
+	var wrapped ConfigProvider = &wrappingProvider{ConfigProvider: provider}
+	graph, err := wrapped.GetPlanGraph()
+	require.NoError(t, err)
+	instances := graph.ProvidersFor(provider.GetInstanceId(), "users")
+	require.Len(t, instances, 1)
+	assert.Equal(t, "users-1", instances[0].Id)
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/plangraph"
+	"gopkg.in/yaml.v3"
+)
+
+// StaticFixture is the declarative configuration served by the StaticConfigProvider.
+// Service, resource and consumer maps are keyed by resource name and then port type.
+type StaticFixture struct {
+	SystemID      string                              `json:"systemId,omitempty"`
+	InstanceID    string                              `json:"instanceId,omitempty"`
+	Configuration map[string]interface{}              `json:"configuration,omitempty"`
+	ServerHost    string                              `json:"serverHost,omitempty"`
+	ServerPorts   map[string]string                   `json:"serverPorts,omitempty"`
+	Services      map[string]map[string]string        `json:"services,omitempty"`
+	Endpoints     map[string]map[string][]Endpoint    `json:"endpoints,omitempty"`
+	Resources     map[string]map[string]*ResourceInfo `json:"resources,omitempty"`
+	InstanceHosts map[string]string                   `json:"instanceHosts,omitempty"`
+	Operators     map[string]*InstanceOperator        `json:"operators,omitempty"`
+	Consumers     map[string]*BlockInstanceDetails    `json:"consumers,omitempty"`
+	Providers     map[string][]*BlockInstanceDetails  `json:"providers,omitempty"`
+	ServerTLS     map[string]*TLS                     `json:"serverTls,omitempty"`
+	ClientTLS     map[string]*TLS                     `json:"clientTls,omitempty"`
+	Plan          *model.Plan                         `json:"plan,omitempty"`
+}
+
+// ParseStaticFixture parses a YAML or JSON fixture.
+// YAML is converted to JSON first so the fixture uses the same field names in both formats.
+func ParseStaticFixture(data []byte) (*StaticFixture, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing static config: %w", err)
+	}
+
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing static config: %w", err)
+	}
+
+	fixture := &StaticFixture{}
+	if err := json.Unmarshal(jsonData, fixture); err != nil {
+		return nil, fmt.Errorf("error parsing static config: %w", err)
+	}
+	return fixture, nil
+}
+
+// LoadStaticFixture reads and parses a YAML or JSON fixture file
+func LoadStaticFixture(path string) (*StaticFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading static config file: %w", err)
+	}
+	return ParseStaticFixture(data)
+}
+
+// StaticConfigProvider implements the ConfigProvider interface from a fixed fixture.
+// It is meant for unit tests and offline runs where neither the cluster service nor Kubernetes is available.
+type StaticConfigProvider struct {
+	AbstractConfigProvider
+	fixture *StaticFixture
+}
+
+// NewStaticConfigProvider creates a new instance of StaticConfigProvider.
+// The identity in the fixture takes precedence over the given system and instance ID.
+func NewStaticConfigProvider(blockRef, systemID, instanceID string, blockDefinition map[string]interface{}, fixture *StaticFixture, opts ...Option) *StaticConfigProvider {
+	o := newOptions(opts)
+	if fixture == nil {
+		fixture = &StaticFixture{}
+	}
+	if fixture.SystemID != "" {
+		systemID = fixture.SystemID
+	}
+	if fixture.InstanceID != "" {
+		instanceID = fixture.InstanceID
+	}
+	return &StaticConfigProvider{
+		AbstractConfigProvider: AbstractConfigProvider{
+			BlockRef:                 blockRef,
+			SystemID:                 systemID,
+			InstanceID:               instanceID,
+			BlockDefinition:          blockDefinition,
+			EnvironmentConfiguration: map[string]string{},
+			logger:                   o.logger,
+		},
+		fixture: fixture,
+	}
+}
+
+// GetProviderId returns the identifier for the config provider
+func (s *StaticConfigProvider) GetProviderId() string {
+	return "static"
+}
+
+// GetServerPort returns the port to listen on for the current instance
+func (s *StaticConfigProvider) GetServerPort(portType string) (string, error) {
+	if portType == "" {
+		portType = DEFAULT_SERVER_PORT_TYPE
+	}
+
+	if port, exists := lookupKey(s.fixture.ServerPorts, portType); exists {
+		return port, nil
+	}
+	return "", &NotFoundError{Kind: KindServerPort, Name: portType}
+}
+
+// GetServerHost returns the host for the current process
+func (s *StaticConfigProvider) GetServerHost() (string, error) {
+	if s.fixture.ServerHost != "" {
+		return s.fixture.ServerHost, nil
+	}
+	return "127.0.0.1", nil
+}
+
+// GetServiceAddress returns the service address for the given resource name and port type
+func (s *StaticConfigProvider) GetServiceAddress(resourceName, portType string) (string, error) {
+	if ports, exists := lookupKey(s.fixture.Services, resourceName); exists {
+		if address, exists := lookupKey(ports, portType); exists {
+			return address, nil
+		}
+	}
+	return "", &NotFoundError{Kind: KindService, Name: resourceName + "/" + portType}
+}
+
+// GetServiceEndpoints returns all endpoints for the given resource name and port type,
+// falling back to the single service address
+func (s *StaticConfigProvider) GetServiceEndpoints(resourceName, portType string) ([]Endpoint, error) {
+	if ports, exists := lookupKey(s.fixture.Endpoints, resourceName); exists {
+		if endpoints, exists := lookupKey(ports, portType); exists {
+			return append([]Endpoint(nil), endpoints...), nil
+		}
+	}
+
+	address, err := s.GetServiceAddress(resourceName, portType)
+	if err != nil {
+		return nil, err
+	}
+	return []Endpoint{{Address: address}}, nil
+}
+
+// GetResourceInfo returns the resource info for the given resource type, port type, and resource name
+func (s *StaticConfigProvider) GetResourceInfo(resourceType, portType, resourceName string) (*ResourceInfo, error) {
+	if ports, exists := lookupKey(s.fixture.Resources, resourceName); exists {
+		if info, exists := lookupKey(ports, portType); exists && info != nil {
+			return copyResourceInfo(info), nil
+		}
+	}
+	return nil, &NotFoundError{Kind: KindResource, Name: resourceName + "/" + portType}
+}
+
+// GetInstanceHost returns the hostname for the given instance ID
+func (s *StaticConfigProvider) GetInstanceHost(instanceID string) (string, error) {
+	if host, exists := lookupKey(s.fixture.InstanceHosts, instanceID); exists {
+		return host, nil
+	}
+	return "", &NotFoundError{Kind: KindInstanceHost, Name: instanceID}
+}
+
+// Get returns the configuration value for the given path
+func (s *StaticConfigProvider) Get(path string) interface{} {
+	return s.GetOrDefault(path, nil)
+}
+
+// GetConfiguration returns a copy of the whole instance configuration
+func (s *StaticConfigProvider) GetConfiguration() map[string]interface{} {
+	return copyConfiguration(s.fixture.Configuration)
+}
+
+// GetOrDefault returns the configuration value for the given path, or the default value if not found
+func (s *StaticConfigProvider) GetOrDefault(path string, defaultValue interface{}) interface{} {
+	if value, exists := s.fixture.Configuration[path]; exists && value != nil {
+		return copyValue(value)
+	}
+	return defaultValue
+}
+
+// GetInstanceForConsumer returns the provider instance connected to the given consumer resource
+func (s *StaticConfigProvider) GetInstanceForConsumer(resourceName string) (*BlockInstanceDetails, error) {
+	if details, exists := lookupKey(s.fixture.Consumers, resourceName); exists && details != nil {
+		return copyBlockInstanceDetails(details), nil
+	}
+	return nil, &NotFoundError{Kind: KindConsumer, Name: resourceName}
+}
+
+// GetInstanceOperator returns the operator details for the given instance ID
+func (s *StaticConfigProvider) GetInstanceOperator(instanceId string) (*InstanceOperator, error) {
+	if operator, exists := lookupKey(s.fixture.Operators, instanceId); exists && operator != nil {
+		return copyInstanceOperator(operator), nil
+	}
+	return nil, &NotFoundError{Kind: KindInstanceOperator, Name: instanceId}
+}
+
+// GetInstancesForProvider returns the consumer instances connected to the given provider resource.
+// An unconnected resource returns an empty list.
+func (s *StaticConfigProvider) GetInstancesForProvider(resourceName string) ([]*BlockInstanceDetails, error) {
+	instances, _ := lookupKey(s.fixture.Providers, resourceName)
+	return copyInstances(instances), nil
+}
+
+// GetServerTLS returns the TLS material to serve the port type with
+func (s *StaticConfigProvider) GetServerTLS(portType string) (*TLS, error) {
+	if portType == "" {
+		portType = DEFAULT_SERVER_PORT_TYPE
+	}
+
+	if material, exists := lookupKey(s.fixture.ServerTLS, portType); exists && material != nil {
+		return copyTLS(material), nil
+	}
+	return nil, &NotFoundError{Kind: KindServerTLS, Name: portType}
+}
+
+// GetClientTLS returns the TLS material configured for the consumed resource
+func (s *StaticConfigProvider) GetClientTLS(resourceName string) (*TLS, error) {
+	if material, exists := lookupKey(s.fixture.ClientTLS, resourceName); exists && material != nil {
+		return copyTLS(material), nil
+	}
+	return nil, &NotFoundError{Kind: KindClientTLS, Name: resourceName}
+}
+
+// GetPlanGraph returns the graph of the plan in the fixture
+func (s *StaticConfigProvider) GetPlanGraph() (*plangraph.Graph, error) {
+	if s.fixture.Plan == nil {
+		return nil, &NotFoundError{Kind: KindPlan, Name: s.SystemID}
+	}
+	return plangraph.New(s.fixture.Plan), nil
+}
+
+// lookupKey finds a value by exact key first, then case-insensitively, matching how the other providers normalise names
+func lookupKey[T any](values map[string]T, key string) (T, bool) {
+	if value, exists := values[key]; exists {
+		return value, true
+	}
+	for k, value := range values {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticConfigProvider(t *testing.T) {
+	fixture, err := LoadStaticFixture("../testdata/static.yml")
+	assert.NoError(t, err)
+
+	provider := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", map[string]interface{}{}, fixture)
+
+	assert.Equal(t, "static", provider.GetProviderId())
+	assert.Equal(t, "kapeta/messages:local", provider.GetBlockReference())
+	assert.Equal(t, "kapeta://kapeta/sample-plan:local", provider.GetSystemId())
+	assert.Equal(t, "messages-service", provider.GetInstanceId())
+
+	port, err := provider.GetServerPort("")
+	assert.NoError(t, err)
+	assert.Equal(t, "40001", port)
+
+	host, err := provider.GetServerHost()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+
+	address, err := provider.GetServiceAddress("users", "REST")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:40002/", address)
+
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("27017"), info.Port)
+	assert.Equal(t, "secret", info.Credentials["password"])
+
+	instanceHost, err := provider.GetInstanceHost("users-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", instanceHost)
+
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	assert.NoError(t, err)
+	assert.Equal(t, 27017, operator.Ports["mongodb"].Port)
+
+	consumer, err := provider.GetInstanceForConsumer("users")
+	assert.NoError(t, err)
+	assert.Equal(t, "users-1", consumer.InstanceId)
+	assert.Equal(t, "kapeta/users-service", consumer.Block.Metadata.Name)
+
+	instances, err := provider.GetInstancesForProvider("messages")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "gateway", instances[0].InstanceId)
+
+	assert.Equal(t, "hello", provider.Get("greeting"))
+	assert.Nil(t, provider.Get("missing"))
+	assert.Equal(t, "default", provider.GetOrDefault("missing", "default"))
+}
+
+func TestStaticConfigProviderMissingEntries(t *testing.T) {
+	provider := NewStaticConfigProvider("block-ref", "system-id", "instance-id", map[string]interface{}{}, nil)
+
+	assert.Equal(t, "system-id", provider.GetSystemId())
+	assert.Equal(t, "instance-id", provider.GetInstanceId())
+
+	_, err := provider.GetServerPort("rest")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetServiceAddress("users", "rest")
+	assert.Equal(t, "service not found: users/rest", err.Error())
+
+	_, err = provider.GetServiceEndpoints("users", "rest")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetResourceInfo("mongodb", "mongodb", "messages")
+	assert.Equal(t, "resource not found: messages/mongodb", err.Error())
+
+	_, err = provider.GetInstanceHost("unknown")
+	assert.Equal(t, "instance host not found: unknown", err.Error())
+
+	_, err = provider.GetInstanceOperator("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = provider.GetInstanceForConsumer("users")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	instances, err := provider.GetInstancesForProvider("messages")
+	assert.NoError(t, err)
+	assert.Empty(t, instances)
+}
+
+func TestParseStaticFixtureJSON(t *testing.T) {
+	fixture, err := ParseStaticFixture([]byte(`{"serverPorts": {"grpc": "9090"}, "services": {"users": {"grpc": "users:9090"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "9090", fixture.ServerPorts["grpc"])
+	assert.Equal(t, "users:9090", fixture.Services["users"]["grpc"])
+
+	_, err = ParseStaticFixture([]byte("serverPorts: ["))
+	assert.Error(t, err)
+}
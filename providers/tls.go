@@ -0,0 +1,178 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kapetacom/sdk-go-config/blockdef"
+)
+
+const (
+	// EnvTLSDir is the directory TLS secrets are mounted in, see GetServerTLS and GetClientTLS
+	EnvTLSDir     = "KAPETA_TLS_DIR"
+	defaultTLSDir = "/var/run/secrets/kapeta/tls"
+
+	// File names used by secrets of type kubernetes.io/tls
+	tlsCAFile   = "ca.crt"
+	tlsCertFile = "tls.crt"
+	tlsKeyFile  = "tls.key"
+)
+
+// TLS describes the TLS material of a connection.
+// The CA bundle, certificate and key are each given either as PEM or as the path to a PEM file.
+type TLS struct {
+	CA         string `json:"ca,omitempty"`
+	CAFile     string `json:"caFile,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	CertFile   string `json:"certFile,omitempty"`
+	Key        string `json:"key,omitempty"`
+	KeyFile    string `json:"keyFile,omitempty"`
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// HasCA returns true if a CA bundle is configured
+func (t *TLS) HasCA() bool {
+	return t.CA != "" || t.CAFile != ""
+}
+
+// HasCertificate returns true if a certificate is configured
+func (t *TLS) HasCertificate() bool {
+	return t.Cert != "" || t.CertFile != ""
+}
+
+// TLSProvider is implemented by providers that can describe TLS material
+// beyond what is attached to resource infos and instance operators
+type TLSProvider interface {
+	GetServerTLS(portType string) (*TLS, error)
+	GetClientTLS(resourceName string) (*TLS, error)
+}
+
+// ServerTLSEnvVar returns the environment variable holding the JSON encoded TLS material to serve the port type with
+func ServerTLSEnvVar(portType string) string {
+	return "KAPETA_PROVIDER_TLS_" + toEnvName(portType)
+}
+
+// ClientTLSEnvVar returns the environment variable holding the JSON encoded TLS material to connect to a consumed resource with
+func ClientTLSEnvVar(resourceName string) string {
+	return "KAPETA_CONSUMER_TLS_" + toEnvName(resourceName)
+}
+
+// GetServerTLS returns the TLS material to serve the port type with.
+// It is read from KAPETA_PROVIDER_TLS_<PORT TYPE>, or from a kubernetes.io/tls secret mounted in
+// $KAPETA_TLS_DIR/provider/<port type>.
+func (a *AbstractConfigProvider) GetServerTLS(portType string) (*TLS, error) {
+	if portType == "" {
+		portType = DEFAULT_SERVER_PORT_TYPE
+	}
+	return a.lookupTLS(KindServerTLS, portType, ServerTLSEnvVar(portType), filepath.Join(a.tlsDir(), "provider", portType))
+}
+
+// GetClientTLS returns the TLS material to connect to the consumed resource with.
+// It is read from KAPETA_CONSUMER_TLS_<RESOURCE NAME>, or from a secret mounted in
+// $KAPETA_TLS_DIR/consumer/<resource name>.
+func (a *AbstractConfigProvider) GetClientTLS(resourceName string) (*TLS, error) {
+	return a.lookupTLS(KindClientTLS, resourceName, ClientTLSEnvVar(resourceName), filepath.Join(a.tlsDir(), "consumer", resourceName))
+}
+
+func (a *AbstractConfigProvider) tlsDir() string {
+	if dir, exists := a.LookupEnv(EnvTLSDir); exists {
+		return dir
+	}
+	return defaultTLSDir
+}
+
+func (a *AbstractConfigProvider) lookupTLS(kind, name, envVar, dir string) (*TLS, error) {
+	if value, exists := a.LookupEnv(envVar); exists {
+		material := &TLS{}
+		if err := json.Unmarshal([]byte(value), material); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", envVar, err)
+		}
+		return material, nil
+	}
+
+	material := &TLS{
+		CAFile:   existingFile(filepath.Join(dir, tlsCAFile)),
+		CertFile: existingFile(filepath.Join(dir, tlsCertFile)),
+		KeyFile:  existingFile(filepath.Join(dir, tlsKeyFile)),
+	}
+	if !material.HasCA() && !material.HasCertificate() {
+		return nil, &NotFoundError{Kind: kind, Name: name, Detail: fmt.Sprintf("missing environment variable %s and no certificates in %s", envVar, dir)}
+	}
+	return material, nil
+}
+
+func existingFile(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// ResolveServerTLS returns the TLS material to serve the port type with, if the provider supports TLS
+func ResolveServerTLS(provider ConfigProvider, portType string) (*TLS, error) {
+	if tlsProvider, ok := As[TLSProvider](provider); ok {
+		return tlsProvider.GetServerTLS(portType)
+	}
+	return nil, &NotFoundError{Kind: KindServerTLS, Name: portType, Detail: "provider does not support TLS"}
+}
+
+// ResolveClientTLS returns the TLS material to connect to the consumed resource with.
+// Material configured for the consumer by the provider takes precedence. Resources fall back to the TLS
+// of the resource info, then of the port and then of the operator of the instance the consumer is connected to.
+func ResolveClientTLS(provider ConfigProvider, resourceName string) (*TLS, error) {
+	if tlsProvider, ok := As[TLSProvider](provider); ok {
+		material, err := tlsProvider.GetClientTLS(resourceName)
+		if !errors.Is(err, ErrNotFound) {
+			return material, err
+		}
+	}
+
+	definition, _ := provider.GetBlockDefinition().(map[string]interface{})
+	block, err := blockdef.Parse(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, consumer := range block.Consumers {
+		if consumer.Name != resourceName || consumer.IsService() {
+			continue
+		}
+
+		info, err := provider.GetResourceInfo(consumer.ResourceType(), consumer.PortType, resourceName)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if info != nil && info.TLS != nil {
+			return info.TLS, nil
+		}
+
+		instance, err := provider.GetInstanceForConsumer(resourceName)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		operator, err := provider.GetInstanceOperator(instance.InstanceId)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if port, exists := operator.Ports[consumer.PortType]; exists && port.TLS != nil {
+			return port.TLS, nil
+		}
+		if operator.TLS != nil {
+			return operator.TLS, nil
+		}
+	}
+
+	return nil, &NotFoundError{Kind: KindClientTLS, Name: resourceName}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tlsBlockDefinition() map[string]interface{} {
+	return map[string]interface{}{
+		"kind":     "kapeta://kapeta/block-type-service:0.0.2",
+		"metadata": map[string]interface{}{"name": "kapeta/messages"},
+		"spec": map[string]interface{}{
+			"consumers": []interface{}{
+				map[string]interface{}{
+					"kind":     "kapeta://kapeta/resource-type-mongodb:0.0.1",
+					"metadata": map[string]interface{}{"name": "messages"},
+					"spec":     map[string]interface{}{"port": map[string]interface{}{"type": "mongodb"}},
+				},
+				map[string]interface{}{
+					"kind":     "kapeta://kapeta/resource-type-rest-client:0.0.1",
+					"metadata": map[string]interface{}{"name": "users"},
+					"spec":     map[string]interface{}{"port": map[string]interface{}{"type": "rest"}},
+				},
+			},
+		},
+	}
+}
+
+func TestAbstractConfigProviderTLS(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvTLSDir, dir)
+	provider := &AbstractConfigProvider{EnvironmentConfiguration: map[string]string{}}
+
+	_, err := provider.GetServerTLS("rest")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A mounted kubernetes.io/tls secret
+	serverDir := filepath.Join(dir, "provider", "rest")
+	require.NoError(t, os.MkdirAll(serverDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(serverDir, "tls.crt"), []byte("cert"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(serverDir, "tls.key"), []byte("key"), 0o600))
+
+	material, err := provider.GetServerTLS("")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CertFile: filepath.Join(serverDir, "tls.crt"), KeyFile: filepath.Join(serverDir, "tls.key")}, material)
+
+	// A mounted CA bundle is enough for a client
+	clientDir := filepath.Join(dir, "consumer", "messages")
+	require.NoError(t, os.MkdirAll(clientDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(clientDir, "ca.crt"), []byte("ca"), 0o600))
+
+	material, err = provider.GetClientTLS("messages")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CAFile: filepath.Join(clientDir, "ca.crt")}, material)
+
+	// The environment takes precedence over mounted files
+	provider.EnvironmentConfiguration[ServerTLSEnvVar("rest")] = `{"certFile":"/certs/tls.crt","keyFile":"/certs/tls.key","serverName":"messages"}`
+	material, err = provider.GetServerTLS("rest")
+	require.NoError(t, err)
+	assert.Equal(t, &TLS{CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key", ServerName: "messages"}, material)
+
+	provider.EnvironmentConfiguration[ClientTLSEnvVar("users")] = `{invalid`
+	_, err = provider.GetClientTLS("users")
+	assert.ErrorContains(t, err, "failed to parse KAPETA_CONSUMER_TLS_USERS")
+}
+
+func TestResolveClientTLS(t *testing.T) {
+	t.Setenv(EnvTLSDir, t.TempDir())
+
+	fixture := &StaticFixture{
+		Resources: map[string]map[string]*ResourceInfo{
+			"messages": {"mongodb": {Host: "mongo", Port: "27017"}},
+		},
+		Consumers: map[string]*BlockInstanceDetails{
+			"messages": {InstanceId: "mongo-operator"},
+		},
+		Operators: map[string]*InstanceOperator{
+			"mongo-operator": {Hostname: "mongo", TLS: &TLS{CAFile: "operator-ca.crt"}},
+		},
+	}
+	provider := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", tlsBlockDefinition(), fixture)
+
+	material, err := ResolveClientTLS(provider, "messages")
+	require.NoError(t, err)
+	assert.Equal(t, "operator-ca.crt", material.CAFile, "the operator is the last fallback")
+
+	fixture.Operators["mongo-operator"].Ports = map[string]InstanceOperatorPort{
+		"mongodb": {Protocol: "tcp", Port: 27017, TLS: &TLS{CAFile: "port-ca.crt"}},
+	}
+	material, err = ResolveClientTLS(provider, "messages")
+	require.NoError(t, err)
+	assert.Equal(t, "port-ca.crt", material.CAFile)
+
+	fixture.Resources["messages"]["mongodb"].TLS = &TLS{CAFile: "resource-ca.crt"}
+	material, err = ResolveClientTLS(provider, "messages")
+	require.NoError(t, err)
+	assert.Equal(t, "resource-ca.crt", material.CAFile)
+
+	fixture.ClientTLS = map[string]*TLS{"messages": {CAFile: "client-ca.crt"}}
+	material, err = ResolveClientTLS(provider, "messages")
+	require.NoError(t, err)
+	assert.Equal(t, "client-ca.crt", material.CAFile, "material configured for the consumer takes precedence")
+
+	_, err = ResolveClientTLS(provider, "users")
+	assert.ErrorIs(t, err, ErrNotFound, "services only use material configured for the consumer")
+
+	_, err = ResolveClientTLS(provider, "unknown")
+	assert.EqualError(t, err, "client TLS not found: unknown")
+}
+
+func TestResolveServerTLS(t *testing.T) {
+	provider := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &StaticFixture{
+		ServerTLS: map[string]*TLS{"rest": {CertFile: "tls.crt", KeyFile: "tls.key"}},
+	})
+
+	material, err := ResolveServerTLS(provider, "")
+	require.NoError(t, err)
+	assert.Equal(t, "tls.crt", material.CertFile)
+
+	_, err = ResolveServerTLS(provider, "grpc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
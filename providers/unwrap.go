@@ -0,0 +1,26 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+// Unwrapper is implemented by providers that wrap another provider
+type Unwrapper interface {
+	Unwrap() ConfigProvider
+}
+
+// As returns the first provider in the chain of wrapped providers that implements T.
+// Use it to find optional capabilities, such as InstanceStopped, behind wrappers.
+func As[T any](provider ConfigProvider) (T, bool) {
+	for provider != nil {
+		if capability, ok := provider.(T); ok {
+			return capability, true
+		}
+		wrapper, ok := provider.(Unwrapper)
+		if !ok {
+			break
+		}
+		provider = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
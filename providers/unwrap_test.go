@@ -0,0 +1,37 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type wrappingProvider struct {
+	ConfigProvider
+}
+
+func (w *wrappingProvider) Unwrap() ConfigProvider {
+	return w.ConfigProvider
+}
+
+func TestAs(t *testing.T) {
+	static := NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, nil)
+	wrapped := &wrappingProvider{ConfigProvider: &wrappingProvider{ConfigProvider: static}}
+
+	found, ok := As[*StaticConfigProvider](wrapped)
+	assert.True(t, ok)
+	assert.Same(t, static, found)
+
+	tlsProvider, ok := As[TLSProvider](wrapped)
+	assert.True(t, ok, "capabilities are found behind wrappers")
+	assert.NotNil(t, tlsProvider)
+
+	_, ok = As[interface{ InstanceStopped() }](wrapped)
+	assert.False(t, ok)
+
+	_, ok = As[TLSProvider](nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providertest
+
+import (
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory creates a provider that serves exactly what is described by the fixture.
+// It is called once per scenario and should register any cleanup with t.
+type Factory func(t *testing.T, fixture *providers.StaticFixture) providers.ConfigProvider
+
+const (
+	SystemID   = "kapeta://kapeta/conformance-plan:local"
+	InstanceID = "conformance-instance"
+)
+
+// Fixture returns the fixture every conformance scenario is run against
+func Fixture() *providers.StaticFixture {
+	return &providers.StaticFixture{
+		SystemID:   SystemID,
+		InstanceID: InstanceID,
+		Configuration: map[string]interface{}{
+			"greeting": "hello",
+			"database": map[string]interface{}{
+				"hosts": []interface{}{"db-1", "db-2"},
+			},
+		},
+		ServerPorts: map[string]string{
+			"rest": "40001",
+			"grpc": "40002",
+		},
+		Services: map[string]map[string]string{
+			"users": {"rest": "http://users:80/"},
+		},
+		Resources: map[string]map[string]*providers.ResourceInfo{
+			"messages": {
+				"mongodb": {
+					Host:     "mongo",
+					Port:     "27017",
+					Type:     "mongodb",
+					Protocol: "mongodb",
+				},
+			},
+		},
+		InstanceHosts: map[string]string{
+			"users-instance": "10.0.0.2",
+		},
+		Operators: map[string]*providers.InstanceOperator{
+			"mongo-operator": {
+				Hostname: "mongo",
+				Ports: map[string]providers.InstanceOperatorPort{
+					"mongodb": {Protocol: "tcp", Port: 27017},
+				},
+			},
+		},
+		Consumers: map[string]*providers.BlockInstanceDetails{
+			"users": {
+				InstanceId: "users-instance",
+				Block:      kind("kapeta/users-service"),
+				Connections: []*model.Connection{{
+					Consumer: model.Endpoint{BlockId: InstanceID, ResourceName: "users"},
+					Provider: model.Endpoint{BlockId: "users-instance", ResourceName: "users"},
+				}},
+			},
+		},
+		Providers: map[string][]*providers.BlockInstanceDetails{
+			"messages-api": {
+				{
+					InstanceId: "gateway-instance",
+					Block:      kind("kapeta/gateway"),
+					Connections: []*model.Connection{{
+						Consumer: model.Endpoint{BlockId: "gateway-instance", ResourceName: "messages"},
+						Provider: model.Endpoint{BlockId: InstanceID, ResourceName: "messages-api"},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func kind(name string) *model.Kind {
+	return &model.Kind{
+		Kind:     "kapeta://kapeta/block-type-service:0.0.1",
+		Metadata: model.Metadata{Name: name},
+	}
+}
+
+// RunConformance runs the same scenarios against any ConfigProvider and asserts consistent results and error semantics.
+// Lookups of anything that is not configured must fail with a *providers.NotFoundError.
+func RunConformance(t *testing.T, factory Factory) {
+	scenarios := []struct {
+		name string
+		run  func(t *testing.T, provider providers.ConfigProvider)
+	}{
+		{"Identity", testIdentity},
+		{"ServerPort", testServerPort},
+		{"ServiceAddress", testServiceAddress},
+		{"ServiceEndpoints", testServiceEndpoints},
+		{"ResourceInfo", testResourceInfo},
+		{"InstanceHost", testInstanceHost},
+		{"InstanceOperator", testInstanceOperator},
+		{"InstanceForConsumer", testInstanceForConsumer},
+		{"InstancesForProvider", testInstancesForProvider},
+		{"Configuration", testConfiguration},
+		{"Copies", testCopies},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			provider := factory(t, Fixture())
+			require.NotNil(t, provider)
+			scenario.run(t, provider)
+		})
+	}
+}
+
+func assertNotFound(t *testing.T, err error, msg string) {
+	if assert.ErrorIs(t, err, providers.ErrNotFound, msg) {
+		var notFound *providers.NotFoundError
+		assert.ErrorAs(t, err, &notFound, "not found errors must be a *providers.NotFoundError")
+	}
+}
+
+func testIdentity(t *testing.T, provider providers.ConfigProvider) {
+	assert.Equal(t, SystemID, provider.GetSystemId())
+	assert.Equal(t, InstanceID, provider.GetInstanceId())
+	assert.NotEmpty(t, provider.GetProviderId())
+}
+
+func testServerPort(t *testing.T, provider providers.ConfigProvider) {
+	port, err := provider.GetServerPort("rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "40001", port)
+
+	port, err = provider.GetServerPort("grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, "40002", port)
+
+	port, err = provider.GetServerPort("")
+	assert.NoError(t, err)
+	assert.Equal(t, "40001", port, "an empty port type should default to rest")
+}
+
+func testServiceAddress(t *testing.T, provider providers.ConfigProvider) {
+	address, err := provider.GetServiceAddress("users", "rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://users:80/", address)
+
+	address, err = provider.GetServiceAddress("unknown", "rest")
+	assertNotFound(t, err, "an unknown service must return an error")
+	assert.Empty(t, address)
+}
+
+func testServiceEndpoints(t *testing.T, provider providers.ConfigProvider) {
+	endpoints, err := provider.GetServiceEndpoints("users", "rest")
+	assert.NoError(t, err)
+	if assert.Len(t, endpoints, 1) {
+		assert.Equal(t, "http://users:80/", endpoints[0].Address)
+	}
+
+	endpoints, err = provider.GetServiceEndpoints("unknown", "rest")
+	assertNotFound(t, err, "an unknown service must return an error")
+	assert.Empty(t, endpoints)
+}
+
+func testResourceInfo(t *testing.T, provider providers.ConfigProvider) {
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mongo", info.Host)
+		assert.Equal(t, "27017", info.Port.String())
+		assert.Equal(t, "mongodb", info.Type)
+	}
+
+	info, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "unknown")
+	assertNotFound(t, err, "an unknown resource must return an error")
+	assert.Nil(t, info)
+}
+
+func testInstanceHost(t *testing.T, provider providers.ConfigProvider) {
+	host, err := provider.GetInstanceHost("users-instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", host)
+
+	host, err = provider.GetInstanceHost("unknown-instance")
+	assertNotFound(t, err, "an unknown instance must return an error")
+	assert.Empty(t, host)
+}
+
+func testInstanceOperator(t *testing.T, provider providers.ConfigProvider) {
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mongo", operator.Hostname)
+		assert.Equal(t, 27017, operator.Ports["mongodb"].Port)
+	}
+
+	operator, err = provider.GetInstanceOperator("unknown-operator")
+	assertNotFound(t, err, "an unknown operator must return an error")
+	assert.Nil(t, operator)
+}
+
+func testInstanceForConsumer(t *testing.T, provider providers.ConfigProvider) {
+	details, err := provider.GetInstanceForConsumer("users")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "users-instance", details.InstanceId)
+		if assert.NotNil(t, details.Block) {
+			assert.Equal(t, "kapeta/users-service", details.Block.Metadata.Name)
+		}
+		if assert.Len(t, details.Connections, 1) {
+			assert.Equal(t, "users", details.Connections[0].Consumer.ResourceName)
+		}
+	}
+
+	details, err = provider.GetInstanceForConsumer("unknown")
+	assertNotFound(t, err, "an unconnected consumer must return an error")
+	assert.Nil(t, details)
+}
+
+func testInstancesForProvider(t *testing.T, provider providers.ConfigProvider) {
+	instances, err := provider.GetInstancesForProvider("messages-api")
+	if assert.NoError(t, err) && assert.Len(t, instances, 1) {
+		assert.Equal(t, "gateway-instance", instances[0].InstanceId)
+		if assert.NotNil(t, instances[0].Block) {
+			assert.Equal(t, "kapeta/gateway", instances[0].Block.Metadata.Name)
+		}
+		if assert.Len(t, instances[0].Connections, 1) {
+			assert.Equal(t, "messages-api", instances[0].Connections[0].Provider.ResourceName)
+		}
+	}
+
+	instances, err = provider.GetInstancesForProvider("unknown")
+	assert.NoError(t, err, "an unconnected provider is not an error")
+	assert.NotNil(t, instances)
+	assert.Empty(t, instances)
+}
+
+func testConfiguration(t *testing.T, provider providers.ConfigProvider) {
+	assert.Equal(t, "hello", provider.Get("greeting"))
+	assert.Equal(t, "hello", provider.GetOrDefault("greeting", "default"))
+	assert.Nil(t, provider.Get("unknown"))
+	assert.Equal(t, "default", provider.GetOrDefault("unknown", "default"))
+}
+
+func testCopies(t *testing.T, provider providers.ConfigProvider) {
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	require.NoError(t, err)
+	info.Host = "changed"
+	info.Credentials = map[string]string{"password": "changed"}
+	info, _ = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	assert.Equal(t, "mongo", info.Host, "changing a resource info must not change later lookups")
+	assert.Empty(t, info.Credentials)
+
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	require.NoError(t, err)
+	operator.Hostname = "changed"
+	operator.Ports["mongodb"] = providers.InstanceOperatorPort{Port: 1}
+	operator, _ = provider.GetInstanceOperator("mongo-operator")
+	assert.Equal(t, "mongo", operator.Hostname, "changing an operator must not change later lookups")
+	assert.Equal(t, 27017, operator.Ports["mongodb"].Port)
+
+	details, err := provider.GetInstanceForConsumer("users")
+	require.NoError(t, err)
+	details.Block.Metadata.Name = "changed"
+	details.Connections[0].Consumer.ResourceName = "changed"
+	details, _ = provider.GetInstanceForConsumer("users")
+	assert.Equal(t, "kapeta/users-service", details.Block.Metadata.Name, "changing instance details must not change later lookups")
+	assert.Equal(t, "users", details.Connections[0].Consumer.ResourceName)
+
+	instances, err := provider.GetInstancesForProvider("messages-api")
+	require.NoError(t, err)
+	instances[0].InstanceId = "changed"
+	instances, _ = provider.GetInstancesForProvider("messages-api")
+	assert.Equal(t, "gateway-instance", instances[0].InstanceId)
+
+	database := provider.GetOrDefault("database", nil).(map[string]interface{})
+	database["hosts"].([]interface{})[0] = "changed"
+	database["port"] = 1
+	assert.Equal(t, map[string]interface{}{"hosts": []interface{}{"db-1", "db-2"}}, provider.Get("database"),
+		"changing a configuration value must not change later lookups")
+
+	if all, ok := providers.As[interface{ GetConfiguration() map[string]interface{} }](provider); ok {
+		configuration := all.GetConfiguration()
+		configuration["database"].(map[string]interface{})["hosts"].([]interface{})[1] = "changed"
+		assert.Equal(t, []interface{}{"db-1", "db-2"}, all.GetConfiguration()["database"].(map[string]interface{})["hosts"],
+			"changing the configuration must not change later lookups")
+	}
+}
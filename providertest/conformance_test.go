@@ -0,0 +1,145 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package providertest
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/kapetacom/schemas/packages/go/model"
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+func TestStaticConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, fixture *providers.StaticFixture) providers.ConfigProvider {
+		return providers.NewStaticConfigProvider("kapeta/conformance:local", "", "", map[string]interface{}{}, fixture)
+	})
+}
+
+func TestKubernetesConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, fixture *providers.StaticFixture) providers.ConfigProvider {
+		setJSONEnv := func(name string, value any) {
+			data, err := json.Marshal(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(name, string(data))
+		}
+
+		setJSONEnv(providers.EnvInstanceConfig, fixture.Configuration)
+		setJSONEnv(providers.EnvBlockHosts, fixture.InstanceHosts)
+		for portType, port := range fixture.ServerPorts {
+			t.Setenv(providers.ServerPortEnvVar(portType), port)
+		}
+		for resourceName, ports := range fixture.Services {
+			for portType, address := range ports {
+				t.Setenv(providers.ServiceEnvVar(resourceName, portType), address)
+			}
+		}
+		for resourceName, ports := range fixture.Resources {
+			for portType, info := range ports {
+				setJSONEnv(providers.ResourceEnvVar(resourceName, portType), info)
+			}
+		}
+		for instanceID, operator := range fixture.Operators {
+			setJSONEnv(providers.InstanceOperatorEnvVar(instanceID), operator)
+		}
+		for resourceName, details := range fixture.Consumers {
+			setJSONEnv(providers.InstanceForConsumerEnvVar(resourceName), details)
+		}
+		for resourceName, instances := range fixture.Providers {
+			setJSONEnv(providers.InstancesForProviderEnvVar(resourceName), instances)
+		}
+
+		return providers.NewKubernetesConfigProvider("kapeta/conformance:local", fixture.SystemID, fixture.InstanceID, map[string]interface{}{})
+	})
+}
+
+func TestDockerComposeConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, fixture *providers.StaticFixture) providers.ConfigProvider {
+		env := &providers.DockerComposeEnv{
+			SystemID:      fixture.SystemID,
+			InstanceID:    fixture.InstanceID,
+			Configuration: fixture.Configuration,
+			Ports:         fixture.ServerPorts,
+			Services:      map[string]string{},
+			Resources:     fixture.Resources,
+			InstanceHosts: fixture.InstanceHosts,
+			Operators:     fixture.Operators,
+			Consumers:     fixture.Consumers,
+			Providers:     fixture.Providers,
+		}
+		// The compose service and port are taken from the address of the fixture
+		for resourceName, ports := range fixture.Services {
+			for _, address := range ports {
+				u, err := url.Parse(address)
+				if err != nil {
+					t.Fatal(err)
+				}
+				env.Services[resourceName] = u.Host
+			}
+		}
+
+		return providers.NewDockerComposeConfigProvider("kapeta/conformance:local", "", "", map[string]interface{}{}, env)
+	})
+}
+
+func TestLocalConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T, fixture *providers.StaticFixture) providers.ConfigProvider {
+		clustertest.Start(t, toClusterFixture(fixture))
+		return providers.NewLocalConfigProvider("kapeta/conformance:local", "", "", map[string]interface{}{})
+	})
+}
+
+// toClusterFixture describes the static fixture as the cluster service would serve it, including the plan
+func toClusterFixture(fixture *providers.StaticFixture) *clustertest.Fixture {
+	plan := model.Plan{}
+	assets := map[string]any{}
+
+	addBlock := func(details *providers.BlockInstanceDetails) {
+		ref := details.Block.Metadata.Name + ":local"
+		assets[ref] = details.Block
+		plan.Spec.Blocks = append(plan.Spec.Blocks, model.BlockInstance{
+			Id:    details.InstanceId,
+			Block: model.AssetReference{Ref: ref},
+		})
+		for _, connection := range details.Connections {
+			plan.Spec.Connections = append(plan.Spec.Connections, *connection)
+		}
+	}
+	for _, details := range fixture.Consumers {
+		addBlock(details)
+	}
+	for _, instances := range fixture.Providers {
+		for _, details := range instances {
+			addBlock(details)
+		}
+	}
+	assets[fixture.SystemID] = plan
+
+	out := &clustertest.Fixture{
+		Identity:       clustertest.Identity{SystemID: fixture.SystemID, InstanceID: fixture.InstanceID},
+		InstanceConfig: fixture.Configuration,
+		ProviderPorts:  fixture.ServerPorts,
+		InstanceHosts:  fixture.InstanceHosts,
+		Operators:      map[string]any{},
+		Assets:         assets,
+	}
+	for resourceName, ports := range fixture.Services {
+		for portType, address := range ports {
+			out.Services = append(out.Services, clustertest.Service{Name: resourceName, PortType: portType, Address: address})
+		}
+	}
+	for resourceName, ports := range fixture.Resources {
+		for portType, info := range ports {
+			out.Resources = append(out.Resources, clustertest.Resource{PortType: portType, Name: resourceName, Info: info})
+		}
+	}
+	for instanceID, operator := range fixture.Operators {
+		out.Operators[instanceID] = operator
+	}
+	return out
+}
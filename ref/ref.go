@@ -0,0 +1,161 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package ref
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Scheme is the scheme of Kapeta references
+	Scheme = "kapeta"
+	// VersionLocal is the version of blocks built from a local directory rather than a registry
+	VersionLocal = "local"
+	// VersionLatest is the version of the latest release
+	VersionLatest = "latest"
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Ref is a reference to a block, plan or resource type, such as kapeta://handle/name:1.2.3
+type Ref struct {
+	// Scheme is empty if the reference was written without one
+	Scheme string
+	Handle string
+	Name   string
+	// Version is local, latest, a semantic version or empty if the reference was written without one
+	Version string
+}
+
+// Parse parses a reference of the form [kapeta://]handle/name[:version]
+func Parse(s string) (Ref, error) {
+	r := Ref{}
+	rest := s
+	if scheme, path, found := strings.Cut(s, "://"); found {
+		if !strings.EqualFold(scheme, Scheme) {
+			return Ref{}, fmt.Errorf("invalid reference %q: scheme must be %s", s, Scheme)
+		}
+		r.Scheme = scheme
+		rest = path
+	}
+
+	if path, version, found := strings.Cut(rest, ":"); found {
+		if version != VersionLocal && version != VersionLatest {
+			if _, err := parseVersion(version); err != nil {
+				return Ref{}, fmt.Errorf("invalid reference %q: version must be %s, %s or a semantic version", s, VersionLocal, VersionLatest)
+			}
+		}
+		r.Version = version
+		rest = path
+	}
+
+	handle, name, found := strings.Cut(rest, "/")
+	if !found || !namePattern.MatchString(handle) || !namePattern.MatchString(name) {
+		return Ref{}, fmt.Errorf("invalid reference %q: must be handle/name, optionally followed by :version", s)
+	}
+	r.Handle = handle
+	r.Name = name
+	return r, nil
+}
+
+// MustParse parses the reference and panics if it is invalid
+func MustParse(s string) Ref {
+	r, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String returns the reference as it was written
+func (r Ref) String() string {
+	s := r.FullName()
+	if r.Scheme != "" {
+		s = r.Scheme + "://" + s
+	}
+	if r.Version != "" {
+		s += ":" + r.Version
+	}
+	return s
+}
+
+// FullName returns handle/name, without scheme and version
+func (r Ref) FullName() string {
+	return r.Handle + "/" + r.Name
+}
+
+// IsZero returns true for the zero Ref, e.g. when a reference could not be parsed
+func (r Ref) IsZero() bool {
+	return r == Ref{}
+}
+
+// IsLocal returns true if the reference is to a block built from a local directory
+func (r Ref) IsLocal() bool {
+	return r.Version == VersionLocal
+}
+
+// IsSemver returns true if the version is a semantic version
+func (r Ref) IsSemver() bool {
+	_, err := parseVersion(r.Version)
+	return err == nil
+}
+
+// Normalize returns the reference with the kapeta scheme, a lowercase handle and name, and the latest version
+// if none was given, so equal references have equal strings
+func (r Ref) Normalize() Ref {
+	r.Scheme = Scheme
+	r.Handle = strings.ToLower(r.Handle)
+	r.Name = strings.ToLower(r.Name)
+	if r.Version == "" {
+		r.Version = VersionLatest
+	}
+	return r
+}
+
+// Equal returns true if both references are to the same version of the same asset
+func (r Ref) Equal(other Ref) bool {
+	return r.Normalize() == other.Normalize()
+}
+
+// SameAsset returns true if both references are to the same asset, whatever the versions
+func (r Ref) SameAsset(other Ref) bool {
+	return strings.EqualFold(r.FullName(), other.FullName())
+}
+
+// Compare compares the versions of the references, returning -1, 0 or +1.
+// Semantic versions are ordered by precedence and come before latest, which comes before local.
+func (r Ref) Compare(other Ref) int {
+	return CompareVersions(r.Version, other.Version)
+}
+
+// CompareVersions compares two versions the same way Ref.Compare does. An empty version is latest.
+func CompareVersions(a, b string) int {
+	rankA, rankB := versionRank(a), versionRank(b)
+	if rankA != rankB {
+		return compareInts(rankA, rankB)
+	}
+	if rankA != 0 {
+		return 0
+	}
+	versionA, _ := parseVersion(a)
+	versionB, _ := parseVersion(b)
+	return versionA.compare(versionB)
+}
+
+// versionRank orders the kinds of versions: semantic versions, then latest, then local.
+// Versions that are neither are ordered with latest.
+func versionRank(version string) int {
+	switch version {
+	case VersionLocal:
+		return 2
+	case VersionLatest, "":
+		return 1
+	}
+	if _, err := parseVersion(version); err != nil {
+		return 1
+	}
+	return 0
+}
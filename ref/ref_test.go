@@ -0,0 +1,88 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package ref
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("kapeta://sorenmat/super-go-service:1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Scheme: "kapeta", Handle: "sorenmat", Name: "super-go-service", Version: "1.2.3"}, r)
+	assert.Equal(t, "kapeta://sorenmat/super-go-service:1.2.3", r.String())
+	assert.Equal(t, "sorenmat/super-go-service", r.FullName())
+	assert.True(t, r.IsSemver())
+	assert.False(t, r.IsLocal())
+
+	r, err = Parse("soren_mathiasen/go-backend:local")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Handle: "soren_mathiasen", Name: "go-backend", Version: "local"}, r)
+	assert.Equal(t, "soren_mathiasen/go-backend:local", r.String(), "references are written back as they were parsed")
+	assert.True(t, r.IsLocal())
+	assert.False(t, r.IsSemver())
+
+	r, err = Parse("kapeta/resource-type-mongodb")
+	require.NoError(t, err)
+	assert.Equal(t, "", r.Version)
+	assert.False(t, r.IsZero())
+	assert.True(t, Ref{}.IsZero())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"block",
+		"handle/",
+		"/name",
+		"handle/name/extra",
+		"handle/name:",
+		"handle/name:1.2",
+		"handle/name:01.2.3",
+		"handle/name:1.2.3-",
+		"https://handle/name:1.2.3",
+		"handle/na me",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+	assert.EqualError(t, func() error { _, err := Parse("block:local"); return err }(),
+		`invalid reference "block:local": must be handle/name, optionally followed by :version`)
+	assert.Panics(t, func() { MustParse("block") })
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "kapeta://sorenmat/go-sample:latest", MustParse("SorenMat/Go-Sample").Normalize().String())
+	assert.True(t, MustParse("kapeta://sorenmat/go-sample:local").Equal(MustParse("sorenmat/go-sample:local")))
+	assert.True(t, MustParse("KAPETA://sorenmat/go-sample").Equal(MustParse("sorenmat/go-sample:latest")))
+	assert.False(t, MustParse("sorenmat/go-sample:1.0.0").Equal(MustParse("sorenmat/go-sample:local")))
+	assert.True(t, MustParse("sorenmat/go-sample:1.0.0").SameAsset(MustParse("kapeta://SorenMat/go-sample:local")))
+	assert.False(t, MustParse("sorenmat/go-sample:1.0.0").SameAsset(MustParse("sorenmat/other:1.0.0")))
+}
+
+func TestCompare(t *testing.T) {
+	// Ordered by precedence, see https://semver.org/#spec-item-11
+	versions := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.2.0", "1.10.0", "2.0.0", "latest", "local",
+	}
+	for i := range versions {
+		for j := range versions {
+			a := MustParse("kapeta/block:" + versions[i])
+			b := MustParse("kapeta/block:" + versions[j])
+			assert.Equal(t, compareInts(i, j), a.Compare(b), "%s <=> %s", versions[i], versions[j])
+		}
+	}
+
+	assert.Equal(t, 0, CompareVersions("1.0.0+build.1", "1.0.0+build.2"), "build metadata is ignored")
+	assert.Equal(t, 0, CompareVersions("", "latest"))
+
+	shuffled := []string{"local", "2.0.0", "1.0.0-rc.1", "latest", "1.10.0", "1.2.0"}
+	sort.Slice(shuffled, func(i, j int) bool { return CompareVersions(shuffled[i], shuffled[j]) < 0 })
+	assert.Equal(t, []string{"1.0.0-rc.1", "1.2.0", "1.10.0", "2.0.0", "latest", "local"}, shuffled)
+}
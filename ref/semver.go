@@ -0,0 +1,114 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package ref
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version, see https://semver.org
+type version struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+func parseVersion(s string) (version, error) {
+	invalid := errors.New("invalid semantic version")
+
+	// Build metadata does not affect precedence
+	s, _, _ = strings.Cut(s, "+")
+	core, prerelease, hasPrerelease := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return version{}, invalid
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := parseNumber(part)
+		if err != nil {
+			return version{}, invalid
+		}
+		numbers[i] = number
+	}
+
+	v := version{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPrerelease {
+		v.prerelease = strings.Split(prerelease, ".")
+		for _, identifier := range v.prerelease {
+			if identifier == "" {
+				return version{}, invalid
+			}
+		}
+	}
+	return v, nil
+}
+
+// parseNumber parses a numeric identifier, which must not have leading zeros
+func parseNumber(s string) (int, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, errors.New("invalid number")
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, errors.New("invalid number")
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+func (v version) compare(other version) int {
+	if v.major != other.major {
+		return compareInts(v.major, other.major)
+	}
+	if v.minor != other.minor {
+		return compareInts(v.minor, other.minor)
+	}
+	if v.patch != other.patch {
+		return compareInts(v.patch, other.patch)
+	}
+
+	// A version without prerelease has higher precedence than one with
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := compareIdentifiers(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(v.prerelease), len(other.prerelease))
+}
+
+// compareIdentifiers compares prerelease identifiers: numeric ones numerically and before alphanumeric ones
+func compareIdentifiers(a, b string) int {
+	numberA, errA := parseNumber(a)
+	numberB, errB := parseNumber(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(numberA, numberB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables that configure the secret backend
+const (
+	EnvSecretsBackend = "KAPETA_SECRETS_BACKEND"
+	EnvSecretsFile    = "KAPETA_SECRETS_FILE"
+	EnvSecretsKey     = "KAPETA_SECRETS_KEY"
+	EnvVaultMount     = "KAPETA_VAULT_MOUNT"
+	EnvVaultAddress   = "VAULT_ADDR"
+	EnvVaultToken     = "VAULT_TOKEN"
+	EnvVaultNamespace = "VAULT_NAMESPACE"
+)
+
+// NewResolverFromEnv creates a resolver for the backend selected with KAPETA_SECRETS_BACKEND.
+// Returns nil if no backend is selected.
+func NewResolverFromEnv(opts ...ResolverOption) (*Resolver, error) {
+	backend := strings.ToLower(os.Getenv(EnvSecretsBackend))
+	switch backend {
+	case "":
+		return nil, nil
+
+	case "file":
+		path := os.Getenv(EnvSecretsFile)
+		if path == "" {
+			return nil, fmt.Errorf("%s must be set for the file secrets backend", EnvSecretsFile)
+		}
+		key, err := ParseKey(os.Getenv(EnvSecretsKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvSecretsKey, err)
+		}
+		return NewResolver(NewFileBackend(path, key), opts...), nil
+
+	case "vault":
+		address := os.Getenv(EnvVaultAddress)
+		token := os.Getenv(EnvVaultToken)
+		if address == "" || token == "" {
+			return nil, errors.New(EnvVaultAddress + " and " + EnvVaultToken + " must be set for the vault secrets backend")
+		}
+		vault := NewVaultBackend(address, token)
+		vault.Namespace = os.Getenv(EnvVaultNamespace)
+		if mount := os.Getenv(EnvVaultMount); mount != "" {
+			vault.Mount = mount
+		}
+		return NewResolver(vault, opts...), nil
+
+	default:
+		return nil, fmt.Errorf("unknown secrets backend: %s", backend)
+	}
+}
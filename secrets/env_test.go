@@ -0,0 +1,56 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolverFromEnv(t *testing.T) {
+	t.Setenv(EnvSecretsBackend, "")
+	resolver, err := NewResolverFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, resolver)
+
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	require.NoError(t, WriteEncryptedFile(path, key, map[string]map[string]string{"db": {"password": "from-file"}}))
+
+	t.Setenv(EnvSecretsBackend, "file")
+	t.Setenv(EnvSecretsFile, path)
+	t.Setenv(EnvSecretsKey, key.String())
+	resolver, err = NewResolverFromEnv()
+	require.NoError(t, err)
+	value, err := resolver.Resolve(context.Background(), "secret://db/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-file", value)
+
+	t.Setenv(EnvSecretsKey, "")
+	_, err = NewResolverFromEnv()
+	assert.ErrorContains(t, err, "invalid KAPETA_SECRETS_KEY")
+
+	server := startVault(t)
+	t.Setenv(EnvSecretsBackend, "vault")
+	t.Setenv(EnvVaultAddress, server.URL)
+	t.Setenv(EnvVaultToken, "token")
+	resolver, err = NewResolverFromEnv()
+	require.NoError(t, err)
+	value, err = resolver.Resolve(context.Background(), "secret://mongodb/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	t.Setenv(EnvVaultToken, "")
+	_, err = NewResolverFromEnv()
+	assert.EqualError(t, err, "VAULT_ADDR and VAULT_TOKEN must be set for the vault secrets backend")
+
+	t.Setenv(EnvSecretsBackend, "keychain")
+	_, err = NewResolverFromEnv()
+	assert.EqualError(t, err, "unknown secrets backend: keychain")
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const nonceSize = 24
+
+// Key is a 32 byte NaCl secretbox key
+type Key [32]byte
+
+// ParseKey parses a base64 or hex encoded 32 byte key
+func ParseKey(encoded string) (*Key, error) {
+	encoded = strings.TrimSpace(encoded)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(decoded) != len(Key{}) {
+		decoded, err = hex.DecodeString(encoded)
+	}
+	if err != nil || len(decoded) != len(Key{}) {
+		return nil, errors.New("invalid secret key, must be 32 bytes encoded as base64 or hex")
+	}
+	key := &Key{}
+	copy(key[:], decoded)
+	return key, nil
+}
+
+// GenerateKey creates a new random key
+func GenerateKey() (*Key, error) {
+	key := &Key{}
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// String returns the key encoded as base64
+func (k *Key) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// FileBackend reads secrets from a local file encrypted with NaCl secretbox.
+// The file holds a random nonce followed by the sealed JSON object of secret names to key/value pairs.
+// The file is read on every lookup, so changes are picked up when the resolver cache expires.
+type FileBackend struct {
+	path string
+	key  *Key
+}
+
+// NewFileBackend creates a backend reading the encrypted file at path
+func NewFileBackend(path string, key *Key) *FileBackend {
+	return &FileBackend{path: path, key: key}
+}
+
+// ReadSecret decrypts the file and returns the named secret
+func (f *FileBackend) ReadSecret(_ context.Context, name string) (*Secret, error) {
+	data, err := ReadEncryptedFile(f.path, f.key)
+	if err != nil {
+		return nil, err
+	}
+	values, exists := data[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return &Secret{Data: values}, nil
+}
+
+// ReadEncryptedFile decrypts a secrets file
+func ReadEncryptedFile(path string, key *Key) (map[string]map[string]string, error) {
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secrets file: %w", err)
+	}
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("invalid secrets file: %s", path)
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], sealed[:nonceSize])
+	plain, ok := secretbox.Open(nil, sealed[nonceSize:], &nonce, (*[32]byte)(key))
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s, the key does not match", path)
+	}
+
+	data := map[string]map[string]string{}
+	if err := json.Unmarshal(plain, &data); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// WriteEncryptedFile encrypts the secrets and writes them to path, readable only by the current user
+func WriteEncryptedFile(path string, key *Key, data map[string]map[string]string) error {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := secretbox.Seal(nonce[:], plain, &nonce, (*[32]byte)(key))
+
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		return fmt.Errorf("error writing secrets file: %w", err)
+	}
+	return nil
+}
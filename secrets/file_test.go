@@ -0,0 +1,65 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBackend(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	require.NoError(t, WriteEncryptedFile(path, key, map[string]map[string]string{
+		"mongodb": {"username": "kapeta", "password": "secret"},
+	}))
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), stat.Mode().Perm())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "secret", "the file must be encrypted")
+
+	backend := NewFileBackend(path, key)
+	secret, err := backend.ReadSecret(context.Background(), "mongodb")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "kapeta", "password": "secret"}, secret.Data)
+
+	_, err = backend.ReadSecret(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	otherKey, err := GenerateKey()
+	require.NoError(t, err)
+	_, err = NewFileBackend(path, otherKey).ReadSecret(context.Background(), "mongodb")
+	assert.ErrorContains(t, err, "the key does not match")
+
+	_, err = NewFileBackend(filepath.Join(t.TempDir(), "missing"), key).ReadSecret(context.Background(), "mongodb")
+	assert.ErrorContains(t, err, "error reading secrets file")
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+
+	parsed, err := ParseKey(key.String())
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = ParseKey(hex.EncodeToString(key[:]) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseKey("too-short")
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// Provider resolves secret references in the configuration values, resource credentials and options,
+// and operator credentials and options of the wrapped provider
+type Provider struct {
+	providers.Wrapper
+	resolver SecretResolver
+}
+
+// NewProvider wraps the provider so secret references are resolved with the resolver
+func NewProvider(provider providers.ConfigProvider, resolver SecretResolver) *Provider {
+	return &Provider{Wrapper: providers.Wrapper{ConfigProvider: provider}, resolver: resolver}
+}
+
+// Middleware returns a middleware resolving secret references with the resolver
+func Middleware(resolver SecretResolver) providers.Middleware {
+	return func(next providers.ConfigProvider) providers.ConfigProvider {
+		return NewProvider(next, resolver)
+	}
+}
+
+// Get returns the configuration value for the path with secret references resolved.
+// A reference that cannot be resolved is logged and returned as nil.
+func (p *Provider) Get(path string) interface{} {
+	return p.GetOrDefault(path, nil)
+}
+
+// GetOrDefault returns the configuration value for the path with secret references resolved.
+// A reference that cannot be resolved is logged and the default value is returned.
+func (p *Provider) GetOrDefault(path string, defaultValue interface{}) interface{} {
+	value := p.ConfigProvider.GetOrDefault(path, defaultValue)
+	resolved, err := ResolveValue(context.Background(), p.resolver, value)
+	if err != nil {
+		p.getLogger().Error("failed to resolve secret in configuration", "path", path, "error", err)
+		return defaultValue
+	}
+	return resolved
+}
+
+// GetConfiguration returns the configuration of the wrapped provider without resolving secret references,
+// so exported configuration never contains secrets
+func (p *Provider) GetConfiguration() map[string]interface{} {
+	if configProvider, ok := providers.As[interface {
+		GetConfiguration() map[string]interface{}
+	}](p.ConfigProvider); ok {
+		return configProvider.GetConfiguration()
+	}
+	return map[string]interface{}{}
+}
+
+// GetResourceInfo returns the resource info with secret references in the credentials and options resolved
+func (p *Provider) GetResourceInfo(resourceType, portType, resourceName string) (*providers.ResourceInfo, error) {
+	info, err := p.ConfigProvider.GetResourceInfo(resourceType, portType, resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy, since providers may return the same instance on every call
+	resolved := *info
+	ctx := context.Background()
+	if resolved.Credentials, err = ResolveStrings(ctx, p.resolver, info.Credentials); err != nil {
+		return nil, fmt.Errorf("failed to resolve credentials of resource %s: %w", resourceName, err)
+	}
+	if resolved.Options, err = resolveMap(ctx, p.resolver, info.Options); err != nil {
+		return nil, fmt.Errorf("failed to resolve options of resource %s: %w", resourceName, err)
+	}
+	if resolved.TLS, err = resolveTLS(ctx, p.resolver, info.TLS); err != nil {
+		return nil, fmt.Errorf("failed to resolve TLS of resource %s: %w", resourceName, err)
+	}
+	return &resolved, nil
+}
+
+// GetInstanceOperator returns the operator with secret references in the credentials and options resolved
+func (p *Provider) GetInstanceOperator(instanceId string) (*providers.InstanceOperator, error) {
+	operator, err := p.ConfigProvider.GetInstanceOperator(instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *operator
+	ctx := context.Background()
+	if resolved.Credentials, err = resolveMap(ctx, p.resolver, operator.Credentials); err != nil {
+		return nil, fmt.Errorf("failed to resolve credentials of operator %s: %w", instanceId, err)
+	}
+	if resolved.Options, err = resolveMap(ctx, p.resolver, operator.Options); err != nil {
+		return nil, fmt.Errorf("failed to resolve options of operator %s: %w", instanceId, err)
+	}
+	if resolved.TLS, err = resolveTLS(ctx, p.resolver, operator.TLS); err != nil {
+		return nil, fmt.Errorf("failed to resolve TLS of operator %s: %w", instanceId, err)
+	}
+	if operator.Ports != nil {
+		resolved.Ports = make(map[string]providers.InstanceOperatorPort, len(operator.Ports))
+		for portType, port := range operator.Ports {
+			if port.TLS, err = resolveTLS(ctx, p.resolver, port.TLS); err != nil {
+				return nil, fmt.Errorf("failed to resolve TLS of operator %s: %w", instanceId, err)
+			}
+			resolved.Ports[portType] = port
+		}
+	}
+	return &resolved, nil
+}
+
+// GetServerTLS returns the TLS material of the wrapped provider with secret references in the PEM fields resolved
+func (p *Provider) GetServerTLS(portType string) (*providers.TLS, error) {
+	material, err := providers.ResolveServerTLS(p.ConfigProvider, portType)
+	if err != nil {
+		return nil, err
+	}
+	return resolveTLS(context.Background(), p.resolver, material)
+}
+
+// GetClientTLS returns the TLS material the wrapped provider has for connecting to the consumed resource,
+// see providers.ResolveClientTLS, with secret references in the PEM fields resolved
+func (p *Provider) GetClientTLS(resourceName string) (*providers.TLS, error) {
+	material, err := providers.ResolveClientTLS(p.ConfigProvider, resourceName)
+	if err != nil {
+		return nil, err
+	}
+	return resolveTLS(context.Background(), p.resolver, material)
+}
+
+func (p *Provider) getLogger() *slog.Logger {
+	if loggerProvider, ok := providers.As[interface{ GetLogger() *slog.Logger }](p.ConfigProvider); ok {
+		return loggerProvider.GetLogger()
+	}
+	return cfg.Logger()
+}
+
+// resolveTLS returns a copy of the material with secret references in the inline PEM fields resolved,
+// so keys do not have to be stored in the configuration
+func resolveTLS(ctx context.Context, resolver SecretResolver, material *providers.TLS) (*providers.TLS, error) {
+	if material == nil {
+		return nil, nil
+	}
+
+	resolved := *material
+	for _, field := range []*string{&resolved.CA, &resolved.Cert, &resolved.Key} {
+		value, err := resolver.Resolve(ctx, *field)
+		if err != nil {
+			return nil, err
+		}
+		*field = value
+	}
+	return &resolved, nil
+}
+
+func resolveMap(ctx context.Context, resolver SecretResolver, values map[string]any) (map[string]any, error) {
+	if values == nil {
+		return nil, nil
+	}
+	resolved, err := ResolveValue(ctx, resolver, values)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]any), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fixture := &providers.StaticFixture{
+		Configuration: map[string]interface{}{
+			"apiKey":  "secret://api/key",
+			"nested":  map[string]interface{}{"token": "secret://api/token"},
+			"missing": "secret://api/missing",
+			"plain":   "value",
+		},
+		Resources: map[string]map[string]*providers.ResourceInfo{
+			"messages": {"mongodb": {
+				Host:        "mongo",
+				Credentials: map[string]string{"username": "kapeta", "password": "secret://mongodb/password"},
+				Options:     map[string]interface{}{"authSource": "secret://mongodb/authSource"},
+			}},
+		},
+		Operators: map[string]*providers.InstanceOperator{
+			"mongo-operator": {Hostname: "mongo", Credentials: map[string]any{"password": "secret://mongodb/password"}},
+		},
+	}
+	static := providers.NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, fixture,
+		providers.WithLogger(slog.New(slog.NewTextHandler(buf, nil))))
+	provider := NewProvider(static, NewResolver(memoryBackend{
+		"api":     {"key": "api-key", "token": "api-token"},
+		"mongodb": {"password": "mongo-password", "authSource": "admin"},
+	}))
+
+	assert.Equal(t, "api-key", provider.Get("apiKey"))
+	assert.Equal(t, map[string]any{"token": "api-token"}, provider.Get("nested"))
+	assert.Equal(t, "value", provider.Get("plain"))
+	assert.Equal(t, "default", provider.GetOrDefault("unknown", "default"))
+
+	assert.Equal(t, "default", provider.GetOrDefault("missing", "default"))
+	assert.Contains(t, buf.String(), "failed to resolve secret in configuration")
+	assert.NotContains(t, buf.String(), "api-key", "secret values must never be logged")
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	require.NoError(t, err)
+	assert.Equal(t, "kapeta", info.Credentials["username"])
+	assert.Equal(t, "mongo-password", info.Credentials["password"])
+	assert.Equal(t, "admin", info.Options["authSource"])
+	assert.Equal(t, "secret://mongodb/password", fixture.Resources["messages"]["mongodb"].Credentials["password"], "the wrapped provider must not be modified")
+
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	require.NoError(t, err)
+	assert.Equal(t, "mongo-password", operator.Credentials["password"])
+
+	_, err = provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "unknown")
+	assert.ErrorIs(t, err, providers.ErrNotFound)
+
+	assert.Equal(t, "secret://api/key", provider.GetConfiguration()["apiKey"], "exported configuration keeps the references")
+	assert.Equal(t, "static", provider.GetProviderId())
+}
+
+func TestProviderTLS(t *testing.T) {
+	fixture := &providers.StaticFixture{
+		Resources: map[string]map[string]*providers.ResourceInfo{
+			"messages": {"mongodb": {Host: "mongo", TLS: &providers.TLS{CAFile: "ca.crt", Key: "secret://mongodb/key"}}},
+		},
+		Operators: map[string]*providers.InstanceOperator{
+			"mongo-operator": {Ports: map[string]providers.InstanceOperatorPort{
+				"mongodb": {Port: 27017, TLS: &providers.TLS{Key: "secret://mongodb/key"}},
+			}},
+		},
+		ServerTLS: map[string]*providers.TLS{"rest": {Cert: "cert", Key: "secret://server/key"}},
+		ClientTLS: map[string]*providers.TLS{"users": {CAFile: "users-ca.crt", Key: "secret://users/key"}},
+	}
+	static := providers.NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, fixture)
+	// The TLS support of the static provider must be found behind middlewares
+	wrapped := providers.Chain(static, (&providers.Overrides{}).Middleware())
+	provider := NewProvider(wrapped, NewResolver(memoryBackend{
+		"mongodb": {"key": "mongo-key"},
+		"server":  {"key": "server-key"},
+		"users":   {"key": "users-key"},
+	}))
+
+	info, err := provider.GetResourceInfo("kapeta/resource-type-mongodb", "mongodb", "messages")
+	require.NoError(t, err)
+	assert.Equal(t, &providers.TLS{CAFile: "ca.crt", Key: "mongo-key"}, info.TLS)
+	assert.Equal(t, "secret://mongodb/key", fixture.Resources["messages"]["mongodb"].TLS.Key, "the wrapped provider must not be modified")
+
+	operator, err := provider.GetInstanceOperator("mongo-operator")
+	require.NoError(t, err)
+	assert.Equal(t, "mongo-key", operator.Ports["mongodb"].TLS.Key)
+	assert.Equal(t, "secret://mongodb/key", fixture.Operators["mongo-operator"].Ports["mongodb"].TLS.Key)
+
+	material, err := providers.ResolveServerTLS(provider, "rest")
+	require.NoError(t, err)
+	assert.Equal(t, &providers.TLS{Cert: "cert", Key: "server-key"}, material)
+
+	material, err = provider.GetClientTLS("users")
+	require.NoError(t, err)
+	assert.Equal(t, &providers.TLS{CAFile: "users-ca.crt", Key: "users-key"}, material)
+	assert.Equal(t, "secret://users/key", fixture.ClientTLS["users"].Key)
+
+	_, err = provider.GetClientTLS("unknown")
+	assert.ErrorIs(t, err, providers.ErrNotFound)
+}
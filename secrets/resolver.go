@@ -0,0 +1,208 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+)
+
+const (
+	defaultTTL           = 5 * time.Minute
+	defaultRetryInterval = 30 * time.Second
+	defaultFetchTimeout  = 30 * time.Second
+)
+
+// cachedSecret is the last read of a secret. A failed read is recorded with its error, or with the secret
+// read before it, so the backend is not asked again before retryAfter.
+type cachedSecret struct {
+	secret     *Secret
+	err        error
+	expires    time.Time
+	retryAfter time.Time
+}
+
+// fetch is a read of a secret from the backend, shared by every lookup of the secret while it is in flight
+type fetch struct {
+	done   chan struct{}
+	secret *Secret
+	err    error
+}
+
+// Resolver resolves secret references from a backend and caches every secret until its lease expires
+type Resolver struct {
+	backend       Backend
+	defaultTTL    time.Duration
+	retryInterval time.Duration
+	fetchTimeout  time.Duration
+	logger        *slog.Logger
+	now           func() time.Time
+	observe       func(hit bool)
+
+	mu      sync.Mutex
+	cache   map[string]cachedSecret
+	fetches map[string]*fetch
+}
+
+// ResolverOption configures a Resolver
+type ResolverOption func(*Resolver)
+
+// WithDefaultTTL sets how long secrets without a lease are cached. Defaults to 5 minutes.
+func WithDefaultTTL(ttl time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.defaultTTL = ttl
+	}
+}
+
+// WithRetryInterval sets how long a failed read is remembered before the backend is asked again.
+// Defaults to 30 seconds.
+func WithRetryInterval(interval time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.retryInterval = interval
+	}
+}
+
+// WithFetchTimeout sets how long a read from the backend may take. Defaults to 30 seconds.
+func WithFetchTimeout(timeout time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.fetchTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger used to report failed refreshes, instead of the SDK-wide logger
+func WithLogger(logger *slog.Logger) ResolverOption {
+	return func(r *Resolver) {
+		r.logger = logger
+	}
+}
+
+// WithCacheObserver calls observe on every lookup of a secret, with whether it was served from the cache
+func WithCacheObserver(observe func(hit bool)) ResolverOption {
+	return func(r *Resolver) {
+		r.observe = observe
+	}
+}
+
+// NewResolver creates a resolver reading secrets from the backend
+func NewResolver(backend Backend, opts ...ResolverOption) *Resolver {
+	r := &Resolver{
+		backend:       backend,
+		defaultTTL:    defaultTTL,
+		retryInterval: defaultRetryInterval,
+		fetchTimeout:  defaultFetchTimeout,
+		now:           time.Now,
+		cache:         map[string]cachedSecret{},
+		fetches:       map[string]*fetch{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the secret value if value is a reference, and value itself otherwise
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := r.secret(ctx, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	result, exists := secret.Data[ref.Key]
+	if !exists {
+		return "", fmt.Errorf("%w: key %s in %s", ErrNotFound, ref.Key, ref.Name)
+	}
+	return result, nil
+}
+
+// Invalidate removes a secret from the cache, so it is read from the backend the next time it is resolved
+func (r *Resolver) Invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, name)
+}
+
+// secret returns the cached secret, or reads it from the backend without holding the lock.
+// Concurrent lookups of a secret that is not cached share a single read, which is not cancelled with the
+// context of any of them. A failed read is returned again until the retry interval has passed.
+func (r *Resolver) secret(ctx context.Context, name string) (*Secret, error) {
+	r.mu.Lock()
+	cached, exists := r.cache[name]
+	now := r.now()
+	hit := exists && (now.Before(cached.expires) || now.Before(cached.retryAfter))
+	if r.observe != nil {
+		r.observe(hit)
+	}
+	if hit {
+		r.mu.Unlock()
+		return cached.secret, cached.err
+	}
+	current, inFlight := r.fetches[name]
+	if !inFlight {
+		current = &fetch{done: make(chan struct{})}
+		r.fetches[name] = current
+		go r.fetch(name, current)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.secret, current.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to read secret %s: %w", name, ctx.Err())
+	}
+}
+
+// fetch reads the secret from the backend and records the result for the lookups waiting on it
+func (r *Resolver) fetch(name string, current *fetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.fetchTimeout)
+	defer cancel()
+	secret, err := r.backend.ReadSecret(ctx, name)
+
+	r.mu.Lock()
+	defer close(current.done)
+	defer r.mu.Unlock()
+	delete(r.fetches, name)
+
+	cached, exists := r.cache[name]
+	now := r.now()
+	switch {
+	case err == nil:
+		ttl := secret.LeaseDuration
+		if ttl <= 0 {
+			ttl = r.defaultTTL
+		}
+		r.cache[name] = cachedSecret{secret: secret, expires: now.Add(ttl)}
+		current.secret = secret
+	case exists && cached.secret != nil && !errors.Is(err, ErrNotFound):
+		// Keep serving the expired secret rather than failing every lookup while the backend is unavailable.
+		// A secret that no longer exists has been deleted or revoked, so it is not served any longer.
+		r.getLogger().Warn("failed to refresh secret, using cached value", "name", name, "error", err, "retryIn", r.retryInterval)
+		cached.retryAfter = now.Add(r.retryInterval)
+		r.cache[name] = cached
+		current.secret = cached.secret
+	default:
+		current.err = fmt.Errorf("failed to read secret %s: %w", name, err)
+		r.cache[name] = cachedSecret{err: current.err, retryAfter: now.Add(r.retryInterval)}
+	}
+}
+
+func (r *Resolver) getLogger() *slog.Logger {
+	if r.logger != nil {
+		return r.logger
+	}
+	return cfg.Logger()
+}
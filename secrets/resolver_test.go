@@ -0,0 +1,258 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryBackend serves secrets from a map
+type memoryBackend map[string]map[string]string
+
+func (m memoryBackend) ReadSecret(_ context.Context, name string) (*Secret, error) {
+	data, exists := m[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return &Secret{Data: data}, nil
+}
+
+// countingBackend returns a new value on every read and can be made to fail
+type countingBackend struct {
+	reads    int
+	failures int
+	lease    time.Duration
+	err      error
+}
+
+func (c *countingBackend) ReadSecret(_ context.Context, _ string) (*Secret, error) {
+	if c.err != nil {
+		c.failures++
+		return nil, c.err
+	}
+	c.reads++
+	return &Secret{Data: map[string]string{"value": fmt.Sprintf("v%d", c.reads)}, LeaseDuration: c.lease}, nil
+}
+
+func TestResolverPassesThroughPlainValues(t *testing.T) {
+	backend := &countingBackend{}
+	resolver := NewResolver(backend)
+
+	value, err := resolver.Resolve(context.Background(), "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", value)
+	assert.Equal(t, 0, backend.reads)
+
+	_, err = resolver.Resolve(context.Background(), "secret://invalid")
+	assert.Error(t, err)
+}
+
+func TestResolverCachesUntilLeaseExpires(t *testing.T) {
+	now := time.Now()
+	backend := &countingBackend{lease: time.Minute}
+	resolver := NewResolver(backend, WithDefaultTTL(time.Hour))
+	resolver.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		value, err := resolver.Resolve(context.Background(), "secret://db/value")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", value)
+	}
+	assert.Equal(t, 1, backend.reads)
+
+	now = now.Add(time.Minute)
+	value, err := resolver.Resolve(context.Background(), "secret://db/value")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", value, "the secret is read again when the lease expires")
+
+	resolver.Invalidate("db")
+	value, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	assert.Equal(t, "v3", value)
+}
+
+func TestResolverDefaultTTL(t *testing.T) {
+	now := time.Now()
+	backend := &countingBackend{}
+	resolver := NewResolver(backend, WithDefaultTTL(time.Second))
+	resolver.now = func() time.Time { return now }
+
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	now = now.Add(500 * time.Millisecond)
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	assert.Equal(t, 1, backend.reads)
+
+	now = now.Add(time.Second)
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	assert.Equal(t, 2, backend.reads)
+}
+
+func TestResolverServesExpiredSecretWhenRefreshFails(t *testing.T) {
+	now := time.Now()
+	backend := &countingBackend{lease: time.Minute}
+	resolver := NewResolver(backend)
+	resolver.now = func() time.Time { return now }
+
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+
+	now = now.Add(2 * time.Minute)
+	backend.err = errors.New("backend down")
+	value, err := resolver.Resolve(context.Background(), "secret://db/value")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", value)
+
+	_, err = resolver.Resolve(context.Background(), "secret://other/value")
+	assert.EqualError(t, err, "failed to read secret other: backend down")
+}
+
+func TestResolverBacksOffAfterFailedRefresh(t *testing.T) {
+	now := time.Now()
+	backend := &countingBackend{lease: time.Minute}
+	resolver := NewResolver(backend, WithRetryInterval(10*time.Second))
+	resolver.now = func() time.Time { return now }
+
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	_, _ = resolver.Resolve(context.Background(), "secret://other/value")
+
+	now = now.Add(2 * time.Minute)
+	backend.err = errors.New("backend down")
+	for i := 0; i < 3; i++ {
+		value, err := resolver.Resolve(context.Background(), "secret://db/value")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", value)
+		_, err = resolver.Resolve(context.Background(), "secret://unknown/value")
+		assert.EqualError(t, err, "failed to read secret unknown: backend down")
+	}
+	assert.Equal(t, 2, backend.failures, "failed reads are not retried before the retry interval")
+
+	now = now.Add(10 * time.Second)
+	backend.err = nil
+	value, err := resolver.Resolve(context.Background(), "secret://db/value")
+	assert.NoError(t, err)
+	assert.Equal(t, "v3", value, "the secret is read again after the retry interval")
+}
+
+func TestResolverStopsServingDeletedSecrets(t *testing.T) {
+	now := time.Now()
+	backend := memoryBackend{"db": {"password": "secret"}}
+	resolver := NewResolver(backend, WithDefaultTTL(time.Minute))
+	resolver.now = func() time.Time { return now }
+
+	value, err := resolver.Resolve(context.Background(), "secret://db/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	delete(backend, "db")
+	now = now.Add(2 * time.Minute)
+	_, err = resolver.Resolve(context.Background(), "secret://db/password")
+	assert.ErrorIs(t, err, ErrNotFound, "a revoked secret is not served from the cache")
+}
+
+func TestResolverCacheObserver(t *testing.T) {
+	var hits []bool
+	resolver := NewResolver(&countingBackend{}, WithCacheObserver(func(hit bool) {
+		hits = append(hits, hit)
+	}))
+
+	_, _ = resolver.Resolve(context.Background(), "plain")
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	_, _ = resolver.Resolve(context.Background(), "secret://db/value")
+	assert.Equal(t, []bool{false, true}, hits, "plain values are not looked up")
+}
+
+func TestResolverMissingKey(t *testing.T) {
+	resolver := NewResolver(memoryBackend{"db": {"password": "secret"}})
+
+	_, err := resolver.Resolve(context.Background(), "secret://db/username")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.EqualError(t, err, "secret not found: key username in db")
+
+	_, err = resolver.Resolve(context.Background(), "secret://unknown/password")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// blockingBackend blocks reads of a secret until it is released
+type blockingBackend struct {
+	mu      sync.Mutex
+	reads   map[string]int
+	started chan string
+	release chan struct{}
+	blocked string
+}
+
+func (b *blockingBackend) ReadSecret(ctx context.Context, name string) (*Secret, error) {
+	b.mu.Lock()
+	b.reads[name]++
+	b.mu.Unlock()
+	if name == b.blocked {
+		b.started <- name
+		<-b.release
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &Secret{Data: map[string]string{"value": name}}, nil
+}
+
+func TestResolverReadsOutsideTheLock(t *testing.T) {
+	backend := &blockingBackend{reads: map[string]int{}, started: make(chan string), release: make(chan struct{}), blocked: "slow"}
+	resolver := NewResolver(backend)
+
+	var wg sync.WaitGroup
+	values := make([]string, 5)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = resolver.Resolve(context.Background(), "secret://slow/value")
+		}(i)
+	}
+	<-backend.started
+
+	value, err := resolver.Resolve(context.Background(), "secret://fast/value")
+	assert.NoError(t, err, "other secrets can be read while the backend is busy")
+	assert.Equal(t, "fast", value)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = resolver.Resolve(ctx, "secret://slow/value")
+	assert.ErrorIs(t, err, context.Canceled, "waiting for a read in flight stops with the context")
+
+	close(backend.release)
+	wg.Wait()
+	assert.Equal(t, []string{"slow", "slow", "slow", "slow", "slow"}, values)
+	assert.Equal(t, 1, backend.reads["slow"], "concurrent lookups share a single read")
+}
+
+func TestResolverReadIsNotCancelledWithTheFirstLookup(t *testing.T) {
+	backend := &blockingBackend{reads: map[string]int{}, started: make(chan string), release: make(chan struct{}), blocked: "slow"}
+	resolver := NewResolver(backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := resolver.Resolve(ctx, "secret://slow/value")
+		first <- err
+	}()
+	<-backend.started
+
+	second := make(chan string, 1)
+	go func() {
+		value, _ := resolver.Resolve(context.Background(), "secret://slow/value")
+		second <- value
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	close(backend.release)
+	assert.Equal(t, "slow", <-second, "the other lookups get the secret when the first one is cancelled")
+	assert.Equal(t, 1, backend.reads["slow"])
+}
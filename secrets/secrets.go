@@ -0,0 +1,111 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RefPrefix starts every secret reference, e.g. secret://mongodb/password
+const RefPrefix = "secret://"
+
+// ErrNotFound is returned when a secret or a key within it does not exist
+var ErrNotFound = errors.New("secret not found")
+
+// Ref is a parsed secret reference
+type Ref struct {
+	Name string
+	Key  string
+}
+
+func (r Ref) String() string {
+	return RefPrefix + r.Name + "/" + r.Key
+}
+
+// ParseRef parses a reference of the form secret://name/key. The name may contain slashes, the key is the last segment.
+func ParseRef(value string) (Ref, error) {
+	if !IsRef(value) {
+		return Ref{}, fmt.Errorf("not a secret reference: %s", value)
+	}
+	path := strings.TrimPrefix(value, RefPrefix)
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return Ref{}, fmt.Errorf("invalid secret reference %s, must be %sname/key", value, RefPrefix)
+	}
+	return Ref{Name: path[:i], Key: path[i+1:]}, nil
+}
+
+// IsRef returns true if the value is a secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, RefPrefix)
+}
+
+// Secret is a named set of key/value pairs read from a backend
+type Secret struct {
+	Data map[string]string
+	// LeaseDuration is how long the secret may be cached. Zero means the resolver default is used.
+	LeaseDuration time.Duration
+}
+
+// Backend reads secrets from where they are stored
+type Backend interface {
+	ReadSecret(ctx context.Context, name string) (*Secret, error)
+}
+
+// SecretResolver resolves values that may contain secret references
+type SecretResolver interface {
+	// Resolve returns the secret value if value is a reference, and value itself otherwise
+	Resolve(ctx context.Context, value string) (string, error)
+}
+
+// ResolveValue resolves every string in a value decoded from JSON or YAML, descending into maps and slices.
+// The value is not modified, a resolved copy is returned.
+func ResolveValue(ctx context.Context, resolver SecretResolver, value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return resolver.Resolve(ctx, v)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			resolved, err := ResolveValue(ctx, resolver, item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := ResolveValue(ctx, resolver, item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// ResolveStrings resolves every value of the map and returns a resolved copy
+func ResolveStrings(ctx context.Context, resolver SecretResolver, values map[string]string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		resolved, err := resolver.Resolve(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = resolved
+	}
+	return result, nil
+}
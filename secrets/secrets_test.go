@@ -0,0 +1,61 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRef(t *testing.T) {
+	ref, err := ParseRef("secret://mongodb/password")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Name: "mongodb", Key: "password"}, ref)
+	assert.Equal(t, "secret://mongodb/password", ref.String())
+
+	ref, err = ParseRef("secret://team/databases/mongodb/password")
+	require.NoError(t, err)
+	assert.Equal(t, Ref{Name: "team/databases/mongodb", Key: "password"}, ref)
+
+	for _, invalid := range []string{"password", "secret://mongodb", "secret://mongodb/", "secret:///password"} {
+		_, err := ParseRef(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResolveValue(t *testing.T) {
+	resolver := NewResolver(memoryBackend{"db": {"password": "secret"}})
+
+	value, err := ResolveValue(context.Background(), resolver, map[string]any{
+		"plain":  "value",
+		"number": 42,
+		"nested": map[string]any{"password": "secret://db/password"},
+		"list":   []any{"secret://db/password", "plain"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"plain":  "value",
+		"number": 42,
+		"nested": map[string]any{"password": "secret"},
+		"list":   []any{"secret", "plain"},
+	}, value)
+
+	_, err = ResolveValue(context.Background(), resolver, []any{"secret://db/unknown"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolveStrings(t *testing.T) {
+	resolver := NewResolver(memoryBackend{"db": {"password": "secret"}})
+
+	values, err := ResolveStrings(context.Background(), resolver, map[string]string{"username": "kapeta", "password": "secret://db/password"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "kapeta", "password": "secret"}, values)
+
+	values, err = ResolveStrings(context.Background(), resolver, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, values)
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultVaultMount = "secret"
+
+// VaultBackend reads secrets from a HashiCorp Vault compatible KV version 2 secrets engine
+type VaultBackend struct {
+	Address   string
+	Token     string
+	Namespace string
+	// Mount is the path the KV engine is mounted at. Defaults to "secret".
+	Mount  string
+	Client *http.Client
+}
+
+// NewVaultBackend creates a backend for the Vault server at address, authenticating with the token
+func NewVaultBackend(address, token string) *VaultBackend {
+	return &VaultBackend{
+		Address: address,
+		Token:   token,
+		Mount:   defaultVaultMount,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type vaultResponse struct {
+	LeaseDuration int `json:"lease_duration"`
+	Data          struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+// ReadSecret reads the latest version of the named secret
+func (v *VaultBackend) ReadSecret(ctx context.Context, name string) (*Secret, error) {
+	mount := v.Mount
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	requestURL := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(v.Address, "/"), strings.Trim(mount, "/"), strings.Join(segments, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if resp.StatusCode > 399 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault request failed - Status: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	response := &vaultResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	data := make(map[string]string, len(response.Data.Data))
+	for key, value := range response.Data.Data {
+		if s, ok := value.(string); ok {
+			data[key] = s
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		data[key] = string(encoded)
+	}
+	return &Secret{Data: data, LeaseDuration: time.Duration(response.LeaseDuration) * time.Second}, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startVault starts a stand-in for the KV version 2 read API
+func startVault(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/mongodb":
+			_, _ = w.Write([]byte(`{"lease_duration":60,"data":{"data":{"username":"kapeta","password":"secret","port":27017},"metadata":{"version":3}}}`))
+		case "/v1/kv/data/team/mongodb":
+			assert.Equal(t, "team-a", r.Header.Get("X-Vault-Namespace"))
+			_, _ = w.Write([]byte(`{"lease_duration":0,"data":{"data":{"password":"team-secret"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultBackend(t *testing.T) {
+	server := startVault(t)
+	backend := NewVaultBackend(server.URL+"/", "token")
+
+	secret, err := backend.ReadSecret(context.Background(), "mongodb")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "kapeta", "password": "secret", "port": "27017"}, secret.Data)
+	assert.Equal(t, time.Minute, secret.LeaseDuration)
+
+	_, err = backend.ReadSecret(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	backend.Mount = "kv"
+	backend.Namespace = "team-a"
+	secret, err = backend.ReadSecret(context.Background(), "team/mongodb")
+	require.NoError(t, err)
+	assert.Equal(t, "team-secret", secret.Data["password"])
+
+	backend.Token = "wrong"
+	_, err = backend.ReadSecret(context.Background(), "mongodb")
+	assert.EqualError(t, err, `vault request failed - Status: 403 {"errors":["permission denied"]}`)
+}
+
+func TestVaultBackendWithResolver(t *testing.T) {
+	server := startVault(t)
+	resolver := NewResolver(NewVaultBackend(server.URL, "token"))
+
+	value, err := resolver.Resolve(context.Background(), "secret://mongodb/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package serve
+
+import (
+	"log/slog"
+	"time"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+type options struct {
+	shutdownTimeout time.Duration
+	handleSignals   bool
+	tls             bool
+	onListening     func(portType, address string)
+	logger          *slog.Logger
+}
+
+// Option configures Serve and ListenAndServe
+type Option func(*options)
+
+// WithShutdownTimeout sets how long servers are given to drain connections after the context is cancelled.
+// Defaults to 10 seconds.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+// WithoutSignalHandling only shuts down when the context is cancelled.
+// By default SIGINT and SIGTERM also shut down the servers.
+func WithoutSignalHandling() Option {
+	return func(o *options) {
+		o.handleSignals = false
+	}
+}
+
+// WithTLS serves every port with TLS, using the certificate the provider has for the port type
+func WithTLS() Option {
+	return func(o *options) {
+		o.tls = true
+	}
+}
+
+// OnListening is called with the actual address once a port type is accepting connections
+func OnListening(callback func(portType, address string)) Option {
+	return func(o *options) {
+		o.onListening = callback
+	}
+}
+
+// WithLogger sets the logger used instead of the SDK-wide logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		shutdownTimeout: defaultShutdownTimeout,
+		handleSignals:   true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = cfg.Logger()
+	}
+	return o
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package serve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/kapetacom/sdk-go-config/tlsconfig"
+)
+
+// Server serves connections from a listener. *http.Server and *grpc.Server both satisfy it.
+// Servers are stopped with Shutdown(ctx) if they have it, otherwise with GracefulStop, falling back
+// to Stop when the shutdown timeout expires, or Close.
+type Server interface {
+	Serve(listener net.Listener) error
+}
+
+// Port is a server for a port type declared by the block
+type Port struct {
+	PortType string
+	Server   Server
+}
+
+// HTTP returns a port serving the handler over HTTP
+func HTTP(portType string, handler http.Handler) Port {
+	return Port{PortType: portType, Server: &http.Server{Handler: handler}}
+}
+
+// instanceListening is implemented by providers that tell the cluster service when a port is ready
+type instanceListening interface {
+	InstanceListening(portType, address string) error
+}
+
+// exitHandler is implemented by providers that exit the process on SIGINT and SIGTERM by themselves
+type exitHandler interface {
+	DisableExitHandler()
+}
+
+// instanceStopped is implemented by providers that must be told when the instance has stopped
+type instanceStopped interface {
+	InstanceStopped()
+}
+
+// ListenAndServe serves the handler over HTTP on the address assigned to the port type until the context is
+// cancelled or the process is interrupted, see Serve
+func ListenAndServe(ctx context.Context, provider providers.ConfigProvider, portType string, handler http.Handler, opts ...Option) error {
+	return Serve(ctx, provider, []Port{HTTP(portType, handler)}, opts...)
+}
+
+// Serve binds every port to the host and port the provider assigns to its port type and serves them
+// until the context is cancelled, SIGINT or SIGTERM is received, or a server fails.
+// Once all ports are bound the cluster service is notified and OnListening is called. On shutdown, the servers are given the
+// shutdown timeout to drain connections before the provider is told the instance has stopped.
+// It returns nil after a requested shutdown, and the error of the server otherwise.
+func Serve(ctx context.Context, provider providers.ConfigProvider, ports []Port, opts ...Option) error {
+	o := newOptions(opts)
+	if len(ports) == 0 {
+		return errors.New("no ports to serve")
+	}
+
+	listeners, err := listen(provider, ports, o)
+	if err != nil {
+		return err
+	}
+
+	if o.handleSignals {
+		// The application shuts down by itself, so the exit handler of the provider must not exit first
+		if handler, ok := providers.As[exitHandler](provider); ok {
+			handler.DisableExitHandler()
+		}
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+	}
+
+	errCh := make(chan error, len(ports))
+	for i, port := range ports {
+		go func(port Port, listener net.Listener) {
+			errCh <- port.Server.Serve(listener)
+		}(port, listeners[i])
+	}
+
+	notifier, notify := providers.As[instanceListening](provider)
+	for i, port := range ports {
+		address := listeners[i].Addr().String()
+		o.logger.Info("listening", "portType", port.PortType, "address", address)
+		if notify {
+			if err := notifier.InstanceListening(port.PortType, address); err != nil {
+				o.logger.Warn("failed to notify the cluster service", "portType", port.PortType, "error", err)
+			}
+		}
+		if o.onListening != nil {
+			o.onListening(port.PortType, address)
+		}
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		o.logger.Info("shutting down", "timeout", o.shutdownTimeout)
+	case serveErr = <-errCh:
+		o.logger.Error("server failed, shutting down", "error", serveErr)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+	defer cancel()
+	for _, port := range ports {
+		if err := shutdown(shutdownCtx, port.Server); err != nil {
+			o.logger.Warn("failed to shut down gracefully", "portType", port.PortType, "error", err)
+		}
+	}
+
+	if stopped, ok := providers.As[instanceStopped](provider); ok {
+		stopped.InstanceStopped()
+	}
+	return serveErr
+}
+
+// listen binds a listener for every port, closing those already bound if one fails
+func listen(provider providers.ConfigProvider, ports []Port, o *options) ([]net.Listener, error) {
+	host, err := provider.GetServerHost()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve server host: %w", err)
+	}
+
+	listeners := make([]net.Listener, 0, len(ports))
+	closeAll := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+
+	portTypes := map[string]string{}
+	for _, port := range ports {
+		portNumber, err := provider.GetServerPort(port.PortType)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to resolve server port for type %s: %w", port.PortType, err)
+		}
+
+		address := net.JoinHostPort(host, portNumber)
+		if other, exists := portTypes[address]; exists && portNumber != "0" {
+			closeAll()
+			return nil, fmt.Errorf("port types %s and %s are both assigned to %s", other, port.PortType, address)
+		}
+		portTypes[address] = port.PortType
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to listen on %s for port type %s: %w", address, port.PortType, err)
+		}
+
+		if o.tls {
+			config, err := tlsconfig.Server(provider, port.PortType)
+			if err != nil {
+				_ = listener.Close()
+				closeAll()
+				return nil, fmt.Errorf("failed to configure TLS for port type %s: %w", port.PortType, err)
+			}
+			listener = tls.NewListener(listener, config)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// shutdown stops the server with the most graceful method it has
+func shutdown(ctx context.Context, server Server) error {
+	switch s := server.(type) {
+	case interface{ Shutdown(context.Context) error }:
+		return s.Shutdown(ctx)
+	case interface{ GracefulStop() }:
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			if stopper, ok := server.(interface{ Stop() }); ok {
+				stopper.Stop()
+			}
+			return ctx.Err()
+		}
+	case interface{ Close() error }:
+		return s.Close()
+	}
+	return nil
+}
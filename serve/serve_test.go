@@ -0,0 +1,210 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kapetacom/sdk-go-config/clustertest"
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// start runs Serve in the background and returns the addresses once every port is listening
+func start(t *testing.T, ctx context.Context, provider providers.ConfigProvider, ports []Port, opts ...Option) (map[string]string, chan error) {
+	var mu sync.Mutex
+	addresses := map[string]string{}
+	listening := make(chan struct{})
+	opts = append(opts, OnListening(func(portType, address string) {
+		mu.Lock()
+		defer mu.Unlock()
+		addresses[portType] = address
+		if len(addresses) == len(ports) {
+			close(listening)
+		}
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, provider, ports, opts...)
+	}()
+
+	select {
+	case <-listening:
+	case err := <-done:
+		t.Fatalf("Serve() returned before listening: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the servers to listen")
+	}
+	return addresses, done
+}
+
+func get(t *testing.T, address string) string {
+	response, err := http.Get("http://" + address)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func text(value string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(value))
+	})
+}
+
+func staticProvider(ports map[string]string) providers.ConfigProvider {
+	return providers.NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &providers.StaticFixture{ServerPorts: ports})
+}
+
+func TestServeSeveralPortTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := staticProvider(map[string]string{"rest": "0", "web": "0"})
+
+	addresses, done := start(t, ctx, provider, []Port{HTTP("rest", text("rest")), HTTP("web", text("web"))}, WithoutSignalHandling())
+	assert.NotEqual(t, addresses["rest"], addresses["web"])
+	assert.Equal(t, "rest", get(t, addresses["rest"]))
+	assert.Equal(t, "web", get(t, addresses["web"]))
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	_, err := net.Dial("tcp", addresses["rest"])
+	assert.Error(t, err, "the listener is closed after shutdown")
+}
+
+func TestServeDrainsConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := staticProvider(map[string]string{"rest": "0"})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("done"))
+	})
+
+	addresses, done := start(t, ctx, provider, []Port{HTTP("rest", handler)}, WithoutSignalHandling())
+
+	result := make(chan string, 1)
+	go func() {
+		result <- get(t, addresses["rest"])
+	}()
+	<-started
+
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Serve() returned before the request was drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, "done", <-result)
+	assert.NoError(t, <-done)
+}
+
+func TestServeNotifiesClusterService(t *testing.T) {
+	srv := clustertest.Start(t, &clustertest.Fixture{
+		Identity:      clustertest.Identity{SystemID: "system-id", InstanceID: "instance-id"},
+		ProviderPorts: map[string]string{"rest": "0"},
+	})
+	provider := providers.NewLocalConfigProvider("kapeta/messages:local", "", "", map[string]interface{}{})
+	defer provider.DisableExitHandler()
+	srv.ResetRequests()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addresses, done := start(t, ctx, provider, []Port{HTTP("rest", text("rest"))})
+	assert.Empty(t, srv.RequestsTo(http.MethodPut, "/instances"), "the instance is registered once, by the provider")
+
+	notifications := srv.RequestsTo(http.MethodPatch, "/instances")
+	require.Len(t, notifications, 1)
+	body := map[string]any{}
+	require.NoError(t, json.Unmarshal(notifications[0].Body, &body))
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, "rest", body["portType"])
+	assert.Equal(t, addresses["rest"], body["address"])
+	assert.Empty(t, srv.RequestsTo(http.MethodDelete, "/instances"))
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Len(t, srv.RequestsTo(http.MethodDelete, "/instances"), 1, "the instance is stopped after shutdown")
+}
+
+// failingServer stands in for a server that fails to serve
+type failingServer struct{}
+
+func (failingServer) Serve(net.Listener) error {
+	return errors.New("serve failed")
+}
+
+// gracefulServer stands in for a gRPC server, which may be stopped before it has started serving
+type gracefulServer struct {
+	mu       sync.Mutex
+	listener net.Listener
+	stopped  chan struct{}
+}
+
+func (g *gracefulServer) Serve(listener net.Listener) error {
+	g.mu.Lock()
+	select {
+	case <-g.stopped:
+		g.mu.Unlock()
+		return listener.Close()
+	default:
+	}
+	g.listener = listener
+	g.mu.Unlock()
+
+	<-g.stopped
+	return nil
+}
+
+func (g *gracefulServer) GracefulStop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.listener != nil {
+		_ = g.listener.Close()
+	}
+	close(g.stopped)
+}
+
+func TestServeServerFailure(t *testing.T) {
+	provider := staticProvider(map[string]string{"rest": "0", "grpc": "0"})
+	grpc := &gracefulServer{stopped: make(chan struct{})}
+
+	err := Serve(context.Background(), provider, []Port{{PortType: "rest", Server: failingServer{}}, {PortType: "grpc", Server: grpc}}, WithoutSignalHandling())
+	assert.EqualError(t, err, "serve failed")
+
+	select {
+	case <-grpc.stopped:
+	default:
+		t.Error("the other servers are stopped when one fails")
+	}
+}
+
+func TestServeInvalidPorts(t *testing.T) {
+	err := Serve(context.Background(), staticProvider(nil), nil)
+	assert.EqualError(t, err, "no ports to serve")
+
+	err = ListenAndServe(context.Background(), staticProvider(nil), "rest", text("rest"))
+	assert.ErrorIs(t, err, providers.ErrNotFound)
+
+	err = Serve(context.Background(), staticProvider(map[string]string{"rest": "40123", "web": "40123"}), []Port{HTTP("rest", text("rest")), HTTP("web", text("web"))})
+	assert.EqualError(t, err, "port types rest and web are both assigned to 127.0.0.1:40123")
+
+	err = ListenAndServe(context.Background(), staticProvider(map[string]string{"rest": "0"}), "rest", text("rest"), WithTLS())
+	assert.ErrorContains(t, err, "failed to configure TLS for port type rest")
+}
@@ -10,3 +10,6 @@ var ClusterServiceYml []byte
 
 //go:embed block.yml
 var BlockYml []byte
+
+//go:embed plan.json
+var PlanJSON []byte
@@ -0,0 +1,22 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"crypto/tls"
+
+	"github.com/kapetacom/sdk-go-config/tlsconfig"
+)
+
+// ClientTLSConfig returns a TLS configuration for connecting to the consumed resource.
+// The client certificate, if any, is reloaded when its files change.
+func ClientTLSConfig(resourceName string) (*tls.Config, error) {
+	return tlsconfig.Client(GetProvider(), resourceName)
+}
+
+// ServerTLSConfig returns a TLS configuration for serving the port type.
+// The certificate is reloaded when its files change.
+func ServerTLSConfig(portType string) (*tls.Config, error) {
+	return tlsconfig.Server(GetProvider(), portType)
+}
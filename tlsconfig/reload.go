@@ -0,0 +1,183 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	cfg "github.com/kapetacom/sdk-go-config/config"
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// keyPair holds a certificate and reloads it when the certificate or key file is modified,
+// so rotated certificates are picked up by new connections without a restart
+type keyPair struct {
+	material *providers.TLS
+
+	mu      sync.Mutex
+	current *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newKeyPair(material *providers.TLS) (*keyPair, error) {
+	if material.Key == "" && material.KeyFile == "" {
+		return nil, errors.New("a key is required with the certificate")
+	}
+
+	pair := &keyPair{material: material}
+	if _, err := pair.certificate(); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// certificate returns the current certificate, reloading it first if a file changed.
+// If reloading fails the previous certificate is kept, since the files may be in the middle of being replaced.
+func (k *keyPair) certificate() (*tls.Certificate, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	certMod, err := modTime(k.material.CertFile)
+	if err != nil {
+		return k.fallback(err)
+	}
+	keyMod, err := modTime(k.material.KeyFile)
+	if err != nil {
+		return k.fallback(err)
+	}
+	if k.current != nil && certMod.Equal(k.certMod) && keyMod.Equal(k.keyMod) {
+		return k.current, nil
+	}
+
+	certPEM, err := readPEM(k.material.Cert, k.material.CertFile)
+	if err != nil {
+		return k.fallback(fmt.Errorf("failed to read certificate: %w", err))
+	}
+	keyPEM, err := readPEM(k.material.Key, k.material.KeyFile)
+	if err != nil {
+		return k.fallback(fmt.Errorf("failed to read key: %w", err))
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return k.fallback(fmt.Errorf("invalid certificate or key: %w", err))
+	}
+
+	if k.current != nil {
+		cfg.Logger().Info("reloaded TLS certificate", "certFile", k.material.CertFile)
+	}
+	k.current = &certificate
+	k.certMod = certMod
+	k.keyMod = keyMod
+	return k.current, nil
+}
+
+func (k *keyPair) fallback(err error) (*tls.Certificate, error) {
+	if k.current == nil {
+		return nil, err
+	}
+	cfg.Logger().Warn("failed to reload TLS certificate, using the previous one", "certFile", k.material.CertFile, "error", err)
+	return k.current, nil
+}
+
+// certPool holds a CA bundle and reloads it when the CA file is modified, so rotated CAs are trusted by new
+// connections without a restart
+type certPool struct {
+	material *providers.TLS
+
+	mu      sync.Mutex
+	current *x509.CertPool
+	mod     time.Time
+}
+
+func newCertPool(material *providers.TLS) (*certPool, error) {
+	pool := &certPool{material: material}
+	if _, err := pool.pool(); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// pool returns the current CA bundle, reloading it first if the file changed.
+// If reloading fails the previous bundle is kept, as with certificates.
+func (p *certPool) pool() (*x509.CertPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mod, err := modTime(p.material.CAFile)
+	if err != nil {
+		return p.fallback(fmt.Errorf("failed to read CA bundle: %w", err))
+	}
+	if p.current != nil && mod.Equal(p.mod) {
+		return p.current, nil
+	}
+
+	pool, err := loadCertPool(p.material)
+	if err != nil {
+		return p.fallback(err)
+	}
+
+	if p.current != nil {
+		cfg.Logger().Info("reloaded TLS CA bundle", "caFile", p.material.CAFile)
+	}
+	p.current = pool
+	p.mod = mod
+	return p.current, nil
+}
+
+func (p *certPool) fallback(err error) (*x509.CertPool, error) {
+	if p.current == nil {
+		return nil, err
+	}
+	cfg.Logger().Warn("failed to reload TLS CA bundle, using the previous one", "caFile", p.material.CAFile, "error", err)
+	return p.current, nil
+}
+
+// verifyServer verifies the certificate chain of the server against the current CA bundle and the server name,
+// which is the configured server name or else the name the client asked for
+func (p *certPool) verifyServer(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the server presented no certificate")
+	}
+	serverName := p.material.ServerName
+	if serverName == "" {
+		serverName = state.ServerName
+	}
+	if serverName == "" {
+		return errors.New("a server name is required to verify the server with a CA file")
+	}
+
+	roots, err := p.pool()
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+	})
+	return err
+}
+
+// modTime returns the modification time of the file, or the zero time for inline PEM
+func modTime(path string) (time.Time, error) {
+	if path == "" {
+		return time.Time{}, nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
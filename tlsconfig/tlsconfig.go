@@ -0,0 +1,135 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+)
+
+// Client returns a TLS configuration for connecting to the consumed resource, see providers.ResolveClientTLS
+func Client(provider providers.ConfigProvider, resourceName string) (*tls.Config, error) {
+	material, err := providers.ResolveClientTLS(provider, resourceName)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientConfig(material)
+}
+
+// Server returns a TLS configuration for serving the port type, see providers.ResolveServerTLS
+func Server(provider providers.ConfigProvider, portType string) (*tls.Config, error) {
+	material, err := providers.ResolveServerTLS(provider, portType)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerConfig(material)
+}
+
+// NewClientConfig creates a client TLS configuration from the material.
+// Servers are verified against the CA bundle, or the system roots if there is none. A CA file is reloaded when
+// it changes, which requires the server name to be configured or known from the address connected to.
+// If a certificate is configured it is presented to the server, and reloaded when its files change.
+func NewClientConfig(material *providers.TLS) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: material.ServerName,
+	}
+
+	if material.HasCA() {
+		pool, err := newCertPool(material)
+		if err != nil {
+			return nil, err
+		}
+		if material.CA != "" {
+			config.RootCAs = pool.current
+		} else {
+			// The roots of a configuration are fixed, so the server is verified against the reloaded CA bundle instead
+			config.InsecureSkipVerify = true
+			config.VerifyConnection = pool.verifyServer
+		}
+	}
+
+	if material.HasCertificate() {
+		pair, err := newKeyPair(material)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return pair.certificate()
+		}
+	}
+
+	return config, nil
+}
+
+// NewServerConfig creates a server TLS configuration from the material.
+// The certificate and CA file are reloaded when they change. If a CA bundle is configured,
+// clients must present a certificate signed by it.
+func NewServerConfig(material *providers.TLS) (*tls.Config, error) {
+	if !material.HasCertificate() {
+		return nil, errors.New("a certificate is required to serve TLS")
+	}
+
+	pair, err := newKeyPair(material)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return pair.certificate()
+		},
+	}
+
+	if material.HasCA() {
+		pool, err := newCertPool(material)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool.current
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if material.CA == "" {
+			// Clients are verified against the CA bundle as it is when they connect
+			base := config.Clone()
+			config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				clientCAs, err := pool.pool()
+				if err != nil {
+					return nil, err
+				}
+				current := base.Clone()
+				current.ClientCAs = clientCAs
+				return current, nil
+			}
+		}
+	}
+
+	return config, nil
+}
+
+func loadCertPool(material *providers.TLS) (*x509.CertPool, error) {
+	data, err := readPEM(material.CA, material.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("CA bundle contains no certificates")
+	}
+	return pool, nil
+}
+
+// readPEM returns the inline PEM, or the content of the file if there is none
+func readPEM(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	return os.ReadFile(path)
+}
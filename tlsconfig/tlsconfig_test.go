@@ -0,0 +1,263 @@
+// Copyright 2023 Kapeta Inc.
+// SPDX-License-Identifier: MIT
+
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kapetacom/sdk-go-config/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type authority struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	pem    string
+	serial int64
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kapeta-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &authority{cert: cert, key: key, pem: encodePEM("CERTIFICATE", der), serial: 1}
+}
+
+// issue returns a PEM encoded certificate and key for the common name, valid for localhost
+func (a *authority) issue(t *testing.T, commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	a.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(a.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return encodePEM("CERTIFICATE", der), encodePEM("EC PRIVATE KEY", keyDER)
+}
+
+func encodePEM(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newAuthority(t)
+	serverCert, serverKey := ca.issue(t, "server")
+	clientCert, clientKey := ca.issue(t, "client")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	writeFile(t, filepath.Join(dir, "tls.crt"), serverCert)
+	writeFile(t, filepath.Join(dir, "tls.key"), serverKey)
+
+	serverConfig, err := NewServerConfig(&providers.TLS{
+		CAFile:   filepath.Join(dir, "ca.crt"),
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverConfig.ClientAuth)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	clientConfig, err := NewClientConfig(&providers.TLS{CA: ca.pem, Cert: clientCert, Key: clientKey, ServerName: "localhost"})
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+	response, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "server", response.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// Without a client certificate the server rejects the connection
+	anonymous, err := NewClientConfig(&providers.TLS{CA: ca.pem, ServerName: "localhost"})
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: anonymous}}).Get(server.URL)
+	assert.Error(t, err)
+
+	// The server is not trusted without the CA
+	untrusted, err := NewClientConfig(&providers.TLS{CA: newAuthority(t).pem, Cert: clientCert, Key: clientKey})
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: untrusted}}).Get(server.URL)
+	assert.Error(t, err)
+}
+
+func TestCertificateReload(t *testing.T) {
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, "first")
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	config, err := NewServerConfig(&providers.TLS{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+
+	commonName := func() string {
+		certificate, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "first", commonName())
+
+	cert, key = ca.issue(t, "second")
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	assert.Equal(t, "second", commonName())
+
+	// A half-written rotation keeps the previous certificate
+	writeFile(t, keyFile, "invalid")
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	assert.Equal(t, "second", commonName())
+}
+
+func TestCAReload(t *testing.T) {
+	first, second := newAuthority(t), newAuthority(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, first.pem)
+	rotate := func(ca *authority) {
+		writeFile(t, caFile, ca.pem)
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(caFile, later, later))
+	}
+
+	// The server trusts clients of the current CA bundle
+	serverCert, serverKey := first.issue(t, "server")
+	serverConfig, err := NewServerConfig(&providers.TLS{CAFile: caFile, Cert: serverCert, Key: serverKey})
+	require.NoError(t, err)
+	clientCAs := func() *x509.CertPool {
+		config, err := serverConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+		return config.ClientCAs
+	}
+	_, err = first.cert.Verify(x509.VerifyOptions{Roots: clientCAs()})
+	assert.NoError(t, err)
+
+	// The client trusts servers of the current CA bundle
+	clientConfig, err := NewClientConfig(&providers.TLS{CAFile: caFile, ServerName: "localhost"})
+	require.NoError(t, err)
+	connect := func(ca *authority) error {
+		cert, key := ca.issue(t, "server")
+		certificate, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		require.NoError(t, err)
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+		server.StartTLS()
+		defer server.Close()
+
+		response, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}).Get(server.URL)
+		if err == nil {
+			response.Body.Close()
+		}
+		return err
+	}
+	assert.NoError(t, connect(first))
+	assert.Error(t, connect(second))
+
+	rotate(second)
+	_, err = second.cert.Verify(x509.VerifyOptions{Roots: clientCAs()})
+	assert.NoError(t, err, "the server trusts the rotated CA")
+	_, err = first.cert.Verify(x509.VerifyOptions{Roots: clientCAs()})
+	assert.Error(t, err)
+	assert.NoError(t, connect(second), "the client trusts the rotated CA")
+	assert.Error(t, connect(first))
+
+	// A half-written rotation keeps the previous CA bundle
+	writeFile(t, caFile, "invalid")
+	later := time.Now().Add(2 * time.Minute)
+	require.NoError(t, os.Chtimes(caFile, later, later))
+	assert.NoError(t, connect(second))
+}
+
+func TestInvalidMaterial(t *testing.T) {
+	_, err := NewServerConfig(&providers.TLS{CA: "ca"})
+	assert.EqualError(t, err, "a certificate is required to serve TLS")
+
+	_, err = NewServerConfig(&providers.TLS{CertFile: "tls.crt"})
+	assert.EqualError(t, err, "a key is required with the certificate")
+
+	_, err = NewClientConfig(&providers.TLS{CA: "not a certificate"})
+	assert.EqualError(t, err, "CA bundle contains no certificates")
+
+	_, err = NewClientConfig(&providers.TLS{CAFile: filepath.Join(t.TempDir(), "missing.crt")})
+	assert.ErrorContains(t, err, "failed to read CA bundle")
+}
+
+func TestFromProvider(t *testing.T) {
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, "server")
+	provider := providers.NewStaticConfigProvider("kapeta/messages:local", "system-id", "instance-id", nil, &providers.StaticFixture{
+		ServerTLS: map[string]*providers.TLS{"rest": {Cert: cert, Key: key}},
+		ClientTLS: map[string]*providers.TLS{"users": {CA: ca.pem, ServerName: "users"}},
+	})
+
+	serverConfig, err := Server(provider, "rest")
+	require.NoError(t, err)
+	assert.NotNil(t, serverConfig.GetCertificate)
+
+	clientConfig, err := Client(provider, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users", clientConfig.ServerName)
+	assert.NotNil(t, clientConfig.RootCAs)
+
+	_, err = Server(provider, "grpc")
+	assert.ErrorIs(t, err, providers.ErrNotFound)
+}